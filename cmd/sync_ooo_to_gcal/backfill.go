@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/corbaltcode/ooo-calendar-sync/core"
+)
+
+// BackfillEvent asks for a backfill, from Lambda as
+// {"command": "backfill", ...} or from the CLI's backfill subcommand.
+// Invoking it again with the same start and end resumes an interrupted run.
+type BackfillEvent struct {
+	// Start and End bound the time-off period to backfill (RFC3339).
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Chunk is a Go duration, e.g. "720h"; empty means 30 days.
+	Chunk    string `json:"chunk"`
+	PageSize int    `json:"pageSize"`
+
+	PendingPlaceholders     bool    `json:"pendingPlaceholders"`
+	Concurrency             int     `json:"concurrency"`
+	GoogleRequestsPerSecond float64 `json:"googleRequestsPerSecond"`
+	DryRun                  bool    `json:"dryRun"`
+}
+
+// Run backfills the period, logging progress after each chunk.
+func (e *BackfillEvent) Run(ctx context.Context) (core.BackfillReport, error) {
+	env, err := loadSyncEnv()
+	if err != nil {
+		return core.BackfillReport{}, err
+	}
+
+	if e.Start == "" || e.End == "" {
+		return core.BackfillReport{}, configErrorf("backfill needs both start and end")
+	}
+	start, err := core.ParseTimeAny(e.Start)
+	if err != nil {
+		return core.BackfillReport{}, configErrorf("invalid start time: %w", err)
+	}
+	end, err := core.ParseTimeAny(e.End)
+	if err != nil {
+		return core.BackfillReport{}, configErrorf("invalid end time: %w", err)
+	}
+	if !start.Before(end) {
+		return core.BackfillReport{}, configErrorf("start must be before end")
+	}
+
+	var chunk time.Duration
+	if e.Chunk != "" {
+		chunk, err = time.ParseDuration(e.Chunk)
+		if err != nil || chunk <= 0 {
+			return core.BackfillReport{}, configErrorf("invalid chunk %q: must be a positive duration", e.Chunk)
+		}
+	}
+
+	pageSize := e.PageSize
+	if pageSize == 0 {
+		pageSize = 50
+	}
+	concurrency := e.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+
+	eventCfg, err := loadEventConfig()
+	if err != nil {
+		return core.BackfillReport{}, configErrorf("%w", err)
+	}
+
+	store, err := newDynamoStore(ctx, env.tableName)
+	if err != nil {
+		return core.BackfillReport{}, err
+	}
+
+	backend, err := newCalendarBackend(env.credB64, e.GoogleRequestsPerSecond)
+	if err != nil {
+		return core.BackfillReport{}, err
+	}
+	calendars, err := loadCalendarRouting()
+	if err != nil {
+		return core.BackfillReport{}, configErrorf("%w", err)
+	}
+
+	resolver, err := newUserResolver(env.credB64)
+	if err != nil {
+		return core.BackfillReport{}, err
+	}
+
+	// Only requests that end up on calendars are worth fetching.
+	statuses := []string{core.ClockifyStatusApproved}
+	if e.PendingPlaceholders {
+		statuses = append(statuses, core.ClockifyStatusPending)
+	}
+
+	syncerOpts := []func(*core.Syncer){
+		core.WithPageSize(pageSize),
+		core.WithConcurrency(concurrency),
+		core.WithStatuses(statuses...),
+		core.WithUserResolver(resolver),
+		core.WithSyncOptions(core.SyncOptions{
+			PendingPlaceholders: e.PendingPlaceholders,
+			Events:              eventCfg,
+		}),
+	}
+
+	var sink core.CalendarSink = &core.BackendCalendarSink{
+		Backend:   backend,
+		Calendars: calendars,
+	}
+	if e.DryRun {
+		sink = &core.DryRunSink{
+			Backend:   backend,
+			Calendars: calendars,
+		}
+		syncerOpts = append(syncerOpts, core.WithDryRun())
+	}
+
+	syncer := core.NewSyncer(env.workspaceID, core.NewClockifyClient(env.apiKey), sink, store, syncerOpts...)
+
+	return syncer.Backfill(ctx, start, end, core.BackfillOptions{
+		Chunk:    chunk,
+		Progress: logBackfillProgress,
+	})
+}
+
+func logBackfillProgress(p core.BackfillProgress) {
+	status := "ok"
+	if p.Err != nil {
+		status = p.Err.Error()
+	}
+
+	log.Printf(
+		"Backfill chunk %d/%d [%s, %s): fetched %d, synced %d, skipped %d, failed %d: %s",
+		p.Chunk,
+		p.Chunks,
+		p.PeriodStart.Format(time.DateOnly),
+		p.PeriodEnd.Format(time.DateOnly),
+		p.Report.Fetched,
+		p.Report.Synced,
+		p.Report.Skipped,
+		p.Report.Failed,
+		status,
+	)
+}
+
+// runBackfillCLI runs the backfill subcommand with its own flags and prints a
+// summary.
+func runBackfillCLI(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	var (
+		start               = fs.String("start", "", "Period start (RFC3339), required")
+		end                 = fs.String("end", "", "Period end (RFC3339), required")
+		chunk               = fs.String("chunk", "", "Period synced per step (default 720h)")
+		pageSize            = fs.Int("pageSize", 50, "Page size (1–200)")
+		pendingPlaceholders = fs.Bool("pendingPlaceholders", false, "Create tentative events for pending requests")
+		concurrency         = fs.Int("concurrency", defaultConcurrency, "How many users to sync at once")
+		googleRPS           = fs.Float64("googleRequestsPerSecond", defaultGoogleRequestsPerSecond, "Maximum Google Calendar API requests per second")
+		dryRun              = fs.Bool("dry-run", false, "Plan the backfill without writing anything")
+	)
+	_ = fs.Parse(args)
+
+	ev := BackfillEvent{
+		Start:                   *start,
+		End:                     *end,
+		Chunk:                   *chunk,
+		PageSize:                *pageSize,
+		PendingPlaceholders:     *pendingPlaceholders,
+		Concurrency:             *concurrency,
+		GoogleRequestsPerSecond: *googleRPS,
+		DryRun:                  *dryRun,
+	}
+
+	report, err := ev.Run(context.Background())
+
+	if b, jsonErr := json.MarshalIndent(report, "", "  "); jsonErr == nil {
+		fmt.Println(string(b))
+	}
+
+	fmt.Fprintf(
+		os.Stdout,
+		"Backfill processed %d of %d chunks: %d synced (%d inserted, %d updated, %d deleted), %d skipped, %d failed; checkpoint %s\n",
+		report.ChunksProcessed,
+		report.Chunks,
+		report.Synced,
+		report.Inserted,
+		report.Updated,
+		report.Deleted,
+		report.Skipped,
+		report.Failed,
+		report.Checkpoint.Format(time.RFC3339),
+	)
+
+	if err != nil {
+		core.Die("%v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/corbaltcode/ooo-calendar-sync/core"
+)
+
+// newFeedHandler builds a core.FeedHandler over the state table from
+// FEED_TOKENS, the comma-separated tokens subscription URLs may carry, and
+// optionally the groups in FEED_GROUPS_FILE or FEED_GROUPS_JSON and the
+// calendar name in FEED_NAME. Events are worded by the event config. The
+// table needs the Status-PeriodEnd-index described at loadSyncEnv.
+func newFeedHandler(ctx context.Context) (*core.FeedHandler, error) {
+	tokens := splitList(os.Getenv("FEED_TOKENS"))
+	if len(tokens) == 0 {
+		return nil, configErrorf("missing env FEED_TOKENS")
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE_NAME")
+	if tableName == "" {
+		return nil, configErrorf("missing env DYNAMODB_TABLE_NAME")
+	}
+
+	eventCfg, err := loadEventConfig()
+	if err != nil {
+		return nil, configErrorf("%w", err)
+	}
+
+	opts := []func(*core.FeedHandler){
+		core.WithFeedEventConfig(eventCfg),
+	}
+	if path := os.Getenv("FEED_GROUPS_FILE"); path != "" {
+		groups, err := core.LoadFeedGroups(path)
+		if err != nil {
+			return nil, configErrorf("%w", err)
+		}
+		opts = append(opts, core.WithFeedGroups(groups))
+	} else if raw := os.Getenv("FEED_GROUPS_JSON"); raw != "" {
+		groups, err := core.ParseFeedGroups([]byte(raw))
+		if err != nil {
+			return nil, configErrorf("%w", err)
+		}
+		opts = append(opts, core.WithFeedGroups(groups))
+	}
+	if name := os.Getenv("FEED_NAME"); name != "" {
+		opts = append(opts, core.WithFeedName(name))
+	}
+
+	store, err := newDynamoStore(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewFeedHandler(store, tokens, opts...), nil
+}
+
+// splitList splits a comma-separated list, dropping blanks.
+func splitList(s string) []string {
+	var items []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
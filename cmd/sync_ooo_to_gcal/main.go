@@ -4,19 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/corbaltcode/ooo-calendar-sync/core"
 	"github.com/joho/godotenv"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/calendar/v3"
 )
 
@@ -27,82 +29,89 @@ type Event struct {
 	ActivityEnd   string `json:"activityEnd"`
 	FilterBy      string `json:"by"`
 	PageSize      int    `json:"pageSize"`
+
+	PendingPlaceholders bool `json:"pendingPlaceholders"`
+
+	// WatermarkOverlap is a Go duration, e.g. "15m", used when the activity
+	// window is taken from the stored watermark.
+	WatermarkOverlap string `json:"watermarkOverlap"`
+
+	// MaxAttempts is how many times a failing request is tried before it
+	// is dead-lettered, and RetryBackoff a Go duration for the wait after
+	// its first failure, doubling after each further one. Zero values use
+	// the syncer's defaults.
+	MaxAttempts  int    `json:"maxAttempts"`
+	RetryBackoff string `json:"retryBackoff"`
+
+	// Concurrency is how many users are synced at once; zero uses
+	// defaultConcurrency.
+	Concurrency int `json:"concurrency"`
+	// GoogleRequestsPerSecond caps Calendar API calls across all workers;
+	// zero uses defaultGoogleRequestsPerSecond.
+	GoogleRequestsPerSecond float64 `json:"googleRequestsPerSecond"`
+
+	// DryRun looks up calendars and sync records but writes nothing, and
+	// prints the plan instead.
+	DryRun bool `json:"dryRun"`
 }
 
-func (e *Event) Run(ctx context.Context) {
-	apiKey := os.Getenv("CLOCKIFY_API_KEY")
-	if apiKey == "" {
-		core.Die("missing env CLOCKIFY_API_KEY")
-	}
-	workspaceID := os.Getenv("WORKSPACE_ID")
-	if workspaceID == "" {
-		core.Die("missing env WORKSPACE_ID")
-	}
-	credB64 := os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON_B64")
-	if credB64 == "" {
-		core.Die("missing env GOOGLE_SERVICE_ACCOUNT_JSON_B64")
-	}
+// With -by=activity and no -start/-end, Clockify is asked for time off within
+// this long of now on either side.
+const defaultPeriodRange = 365 * 24 * time.Hour
+
+const (
+	defaultConcurrency = 8
+	// Well under the Calendar API's default per-project quota.
+	defaultGoogleRequestsPerSecond = 10
+)
 
-	tableName := os.Getenv("DYNAMODB_TABLE_NAME")
-	if tableName == "" {
-		core.Die("missing env DYNAMODB_TABLE_NAME")
+// Run performs the sync described by the event. Invalid configuration or
+// input is reported as a *ConfigError, and requests that failed to sync as a
+// *core.PartialSyncError alongside a report covering the whole run.
+func (e *Event) Run(ctx context.Context) (core.Report, error) {
+	env, err := loadSyncEnv()
+	if err != nil {
+		return core.Report{}, err
 	}
+	workspaceID := env.workspaceID
 
 	if e.PageSize <= 0 {
-		core.Die("invalid pageSize: must be > 0")
+		return core.Report{}, configErrorf("invalid pageSize: must be > 0")
 	}
 
 	if e.FilterBy == "" {
-		core.Die("missing required parameter: by")
+		return core.Report{}, configErrorf("missing required parameter: by")
 	}
 
 	validFilterBys := map[string]bool{"period": true, "activity": true}
 	if !validFilterBys[e.FilterBy] {
-		core.Die("invalid -by: must be 'period' or 'activity'")
+		return core.Report{}, configErrorf("invalid -by: must be 'period' or 'activity'")
 	}
 
-	var startPtr, endPtr *string
+	var periodStart, periodEnd time.Time
 	if e.PeriodStart != "" {
-		ts, err := core.ParseAndFormatClockifyTime(e.PeriodStart)
+		t, err := core.ParseTimeAny(e.PeriodStart)
 		if err != nil {
-			core.Die("invalid start time: %v", err)
+			return core.Report{}, configErrorf("invalid start time: %w", err)
 		}
-		startPtr = &ts
+		periodStart = t
 	}
 	if e.PeriodEnd != "" {
-		ts, err := core.ParseAndFormatClockifyTime(e.PeriodEnd)
+		t, err := core.ParseTimeAny(e.PeriodEnd)
 		if err != nil {
-			core.Die("invalid end time: %v", err)
+			return core.Report{}, configErrorf("invalid end time: %w", err)
 		}
-		endPtr = &ts
-	}
-
-	payload := core.ClockifyRequestPayload{
-		Start:    startPtr,
-		End:      endPtr,
-		Page:     1,
-		PageSize: e.PageSize,
-		Statuses: []string{
-			core.ClockifyStatusApproved,
-			core.ClockifyStatusRejected,
-		},
+		periodEnd = t
 	}
 
-	// Development safety: force a single user via env var, if set.
-	if forcedSingleUser := os.Getenv("CLOCKIFY_FORCE_USER_ID"); forcedSingleUser != "" {
-		fmt.Printf("CLOCKIFY_FORCE_USER_ID active: only syncing user %s\n", forcedSingleUser)
-		payload.Users = []string{forcedSingleUser}
-	}
-
-	if e.FilterBy == "activity" && (payload.Start == nil || payload.End == nil) {
-		core.Die("when -by=activity is used, both -start and -end must be provided")
-	}
-
-	client := core.NewClockifyClient(apiKey)
-
-	respBytes, err := core.FetchClockifyRequests(client, workspaceID, payload)
-	if err != nil {
-		core.Die("fetch clockify: %v", err)
+	if e.FilterBy == "activity" {
+		now := time.Now().UTC()
+		if periodStart.IsZero() {
+			periodStart = now.Add(-defaultPeriodRange)
+		}
+		if periodEnd.IsZero() {
+			periodEnd = now.Add(defaultPeriodRange)
+		}
 	}
 
 	var activityStartT, activityEndT time.Time
@@ -111,7 +120,7 @@ func (e *Event) Run(ctx context.Context) {
 	if e.ActivityStart != "" {
 		t, err := core.ParseFlexibleRFC3339(e.ActivityStart)
 		if err != nil {
-			core.Die("invalid activityStart: %v", err)
+			return core.Report{}, configErrorf("invalid activityStart: %w", err)
 		}
 		activityStartT, activityStartOK = t.UTC(), true
 	}
@@ -119,185 +128,501 @@ func (e *Event) Run(ctx context.Context) {
 	if e.ActivityEnd != "" {
 		t, err := core.ParseFlexibleRFC3339(e.ActivityEnd)
 		if err != nil {
-			core.Die("invalid activityEnd: %v", err)
+			return core.Report{}, configErrorf("invalid activityEnd: %w", err)
 		}
 		activityEndT, activityEndOK = t.UTC(), true
 	}
 
+	// TODO: Revisit the naming of the time window variables now that filtering
+	// includes both request creation and status changes.
+	window := core.SyncWindow{
+		PeriodStart:   periodStart,
+		PeriodEnd:     periodEnd,
+		ActivityStart: activityStartT,
+		ActivityEnd:   activityEndT,
+	}
+
+	eventCfg, err := loadEventConfig()
+	if err != nil {
+		return core.Report{}, configErrorf("%w", err)
+	}
+
+	var syncerOpts []func(*core.Syncer)
+	syncerOpts = append(syncerOpts,
+		core.WithPageSize(e.PageSize),
+		core.WithSyncOptions(core.SyncOptions{
+			PendingPlaceholders: e.PendingPlaceholders,
+			Events:              eventCfg,
+		}),
+	)
+
+	if e.Concurrency < 0 || e.GoogleRequestsPerSecond < 0 {
+		return core.Report{}, configErrorf("invalid concurrency or googleRequestsPerSecond: must be >= 0")
+	}
+	concurrency := e.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+	syncerOpts = append(syncerOpts, core.WithConcurrency(concurrency))
+
+	if e.WatermarkOverlap != "" {
+		overlap, err := time.ParseDuration(e.WatermarkOverlap)
+		if err != nil || overlap < 0 {
+			return core.Report{}, configErrorf("invalid watermarkOverlap %q: must be a non-negative duration", e.WatermarkOverlap)
+		}
+		syncerOpts = append(syncerOpts, core.WithWatermarkOverlap(overlap))
+	}
+
+	if e.MaxAttempts < 0 {
+		return core.Report{}, configErrorf("invalid maxAttempts: must be >= 0")
+	}
+	retry := core.RetryPolicy{MaxAttempts: e.MaxAttempts}
+	if e.RetryBackoff != "" {
+		retry.Backoff, err = time.ParseDuration(e.RetryBackoff)
+		if err != nil || retry.Backoff <= 0 {
+			return core.Report{}, configErrorf("invalid retryBackoff %q: must be a positive duration", e.RetryBackoff)
+		}
+	}
+	syncerOpts = append(syncerOpts, core.WithRetryPolicy(retry))
+
+	// Development safety: force a single user via env var, if set.
+	if forcedSingleUser := os.Getenv("CLOCKIFY_FORCE_USER_ID"); forcedSingleUser != "" {
+		fmt.Printf("CLOCKIFY_FORCE_USER_ID active: only syncing user %s\n", forcedSingleUser)
+		syncerOpts = append(syncerOpts, core.WithUsers(forcedSingleUser))
+	}
+
+	client := core.NewClockifyClient(env.apiKey)
+
 	// Print results and early return if not filtering by activity.
-	if e.FilterBy != "activity" || (!activityStartOK && !activityEndOK) {
-		if pretty, err := core.PrettyJSON(respBytes); err == nil {
-			fmt.Println(pretty)
-		} else {
-			fmt.Println(string(respBytes))
+	if e.FilterBy != "activity" {
+		syncer := core.NewSyncer(workspaceID, client, nil, nil, syncerOpts...)
+
+		fetched, err := syncer.Fetch(ctx, window)
+		if err != nil {
+			return core.Report{}, err
 		}
-		return
+
+		pretty, err := json.MarshalIndent(fetched, "", "  ")
+		if err != nil {
+			return core.Report{}, fmt.Errorf("encode clockify requests: %w", err)
+		}
+		fmt.Println(string(pretty))
+		return core.Report{Fetched: len(fetched.Requests)}, nil
 	}
 
-	// TODO: Revisit the naming of the time window variables now that filtering
-	// includes both request creation and status changes.
-	env, err := core.FilterByActivity(respBytes, activityStartT, activityEndT)
+	store, err := newDynamoStore(ctx, env.tableName)
+	if err != nil {
+		return core.Report{}, err
+	}
+
+	backend, err := newCalendarBackend(env.credB64, e.GoogleRequestsPerSecond)
+	if err != nil {
+		return core.Report{}, err
+	}
+	calendars, err := loadCalendarRouting()
+	if err != nil {
+		return core.Report{}, configErrorf("%w", err)
+	}
+
+	resolver, err := newUserResolver(env.credB64)
+	if err != nil {
+		return core.Report{}, err
+	}
+	syncerOpts = append(syncerOpts, core.WithUserResolver(resolver))
+	if slack := newSlackStatusUpdater(); slack != nil {
+		syncerOpts = append(syncerOpts, core.WithSlackStatuses(slack))
+	}
+
+	var sink core.CalendarSink = &core.BackendCalendarSink{
+		Backend:   backend,
+		Calendars: calendars,
+	}
+	if e.DryRun {
+		sink = &core.DryRunSink{
+			Backend:   backend,
+			Calendars: calendars,
+		}
+		syncerOpts = append(syncerOpts, core.WithDryRun())
+	}
+
+	syncer := core.NewSyncer(workspaceID, client, sink, store, syncerOpts...)
+
+	// Without an explicit activity window, pick up where the last
+	// successful run left off.
+	var report core.Report
+	if activityStartOK || activityEndOK {
+		report, err = syncer.Sync(ctx, window)
+	} else {
+		report, err = syncer.SyncSinceWatermark(ctx, window)
+	}
+	if e.DryRun {
+		if planErr := report.WritePlan(os.Stdout); planErr != nil {
+			log.Printf("write plan: %v", planErr)
+		}
+	}
+	if report.Unmapped > 0 {
+		fmt.Printf("Skipped %d requests from users with no Google account: %s\n", report.Unmapped, strings.Join(report.UnmappedUsers, ", "))
+	}
+	if len(report.DeadLetters) > 0 {
+		fmt.Printf("%d requests are dead-lettered after failing too often: %s\n", len(report.DeadLetters), strings.Join(report.DeadLetters, ", "))
+	}
 	if err != nil {
-		core.Die("filter: %v", err)
+		return report, err
+	}
+
+	if e.DryRun {
+		fmt.Println("Dry run complete; nothing was written.")
+	} else if report.Queued == 0 {
+		fmt.Println("No requests queued for processing.")
+	} else {
+		fmt.Println("Sync complete!")
+	}
+
+	return report, nil
+}
+
+// ConfigError reports configuration or input that retrying cannot fix.
+// Lambda surfaces it as errorType "ConfigError", distinct from the
+// "PartialSyncError" of a run where individual requests failed.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// syncEnv is the configuration every sync needs from the environment.
+type syncEnv struct {
+	apiKey      string
+	workspaceID string
+	credB64     string
+	tableName   string
+}
+
+// loadSyncEnv reads CLOCKIFY_API_KEY, WORKSPACE_ID,
+// GOOGLE_SERVICE_ACCOUNT_JSON_B64 and DYNAMODB_TABLE_NAME.
+//
+// The table's partition key is the string ClockifyRequestId. Syncs also
+// query these global secondary indexes, projecting all attributes; without
+// them, failed requests are only retried when they are next in the window,
+// and Slack statuses are only set when a request syncs:
+//
+//   - RetryState-index: partition key RetryState (string)
+//   - Status-PeriodEnd-index: partition key Status (string), sort key
+//     PeriodEnd (string)
+func loadSyncEnv() (syncEnv, error) {
+	env := syncEnv{
+		apiKey:      os.Getenv("CLOCKIFY_API_KEY"),
+		workspaceID: os.Getenv("WORKSPACE_ID"),
+		credB64:     os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON_B64"),
+		tableName:   os.Getenv("DYNAMODB_TABLE_NAME"),
+	}
+
+	switch {
+	case env.apiKey == "":
+		return env, configErrorf("missing env CLOCKIFY_API_KEY")
+	case env.workspaceID == "":
+		return env, configErrorf("missing env WORKSPACE_ID")
+	case env.credB64 == "":
+		return env, configErrorf("missing env GOOGLE_SERVICE_ACCOUNT_JSON_B64")
+	case env.tableName == "":
+		return env, configErrorf("missing env DYNAMODB_TABLE_NAME")
 	}
 
+	return env, nil
+}
+
+func newDynamoStore(ctx context.Context, tableName string) (*core.DynamoStore, error) {
 	awsCfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		core.Die("load AWS config: %v", err)
+		return nil, fmt.Errorf("load AWS config: %w", err)
 	}
 
-	store := core.NewDynamoStore(
+	return core.NewDynamoStore(
 		dynamodb.NewFromConfig(awsCfg),
 		tableName,
-	)
+	), nil
+}
 
-	// TODO: Move request filtering into the core package once the persistence layer is fully implemented.
-	var requestsToProcess []core.RequestToProcess
+// newGoogleCalendarBackend impersonates users with the base64 service account
+// credentials, making at most requestsPerSecond API calls (zero for the
+// default).
+func newGoogleCalendarBackend(credB64 string, requestsPerSecond float64) (*core.GoogleCalendarBackend, error) {
+	jwtCfg, err := googleJWTConfig(credB64)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, req := range env.Requests {
-		existing, err := store.GetSyncedRequest(ctx, req.ID)
-		if err != nil {
-			core.Die("get synced request %s: %v", req.ID, err)
-		}
+	if requestsPerSecond == 0 {
+		requestsPerSecond = defaultGoogleRequestsPerSecond
+	}
+	limiter := core.NewRateLimiter(requestsPerSecond, int(requestsPerSecond))
 
-		currentStatus := req.Status.StatusType
+	return core.NewGoogleCalendarBackend(*jwtCfg, core.WithGoogleRateLimiter(limiter)), nil
+}
 
-		if existing == nil {
-			log.Printf(
-				"Queueing new Clockify request %s with status %s",
-				req.ID,
-				currentStatus,
-			)
+// newCalendarBackend returns the Google backend, or, when OUTLOOK_DOMAINS or
+// CALDAV_DOMAINS list comma-separated email domains, one that sends users in
+// those domains to Microsoft 365 (with OUTLOOK_TENANT_ID, OUTLOOK_CLIENT_ID
+// and OUTLOOK_CLIENT_SECRET) or to a CalDAV server (with CALDAV_URL,
+// CALDAV_USERNAME, CALDAV_PASSWORD and optionally CALDAV_PRIMARY_CALENDAR)
+// instead.
+func newCalendarBackend(credB64 string, requestsPerSecond float64) (core.CalendarBackend, error) {
+	google, err := newGoogleCalendarBackend(credB64, requestsPerSecond)
+	if err != nil {
+		return nil, err
+	}
 
-			requestsToProcess = append(requestsToProcess, core.RequestToProcess{
-				Request: req,
-			})
-			continue
+	outlookDomains := os.Getenv("OUTLOOK_DOMAINS")
+	caldavDomains := os.Getenv("CALDAV_DOMAINS")
+	if outlookDomains == "" && caldavDomains == "" {
+		return google, nil
+	}
+
+	if requestsPerSecond == 0 {
+		requestsPerSecond = defaultGoogleRequestsPerSecond
+	}
+	backends := &core.CalendarBackends{
+		Default: google,
+		Domains: make(map[string]core.CalendarBackend),
+	}
+
+	if outlookDomains != "" {
+		tenantID := os.Getenv("OUTLOOK_TENANT_ID")
+		clientID := os.Getenv("OUTLOOK_CLIENT_ID")
+		clientSecret := os.Getenv("OUTLOOK_CLIENT_SECRET")
+		if tenantID == "" || clientID == "" || clientSecret == "" {
+			return nil, configErrorf("OUTLOOK_DOMAINS requires OUTLOOK_TENANT_ID, OUTLOOK_CLIENT_ID and OUTLOOK_CLIENT_SECRET")
 		}
 
-		if existing.Status != currentStatus {
-			log.Printf(
-				"Queueing Clockify request %s because status changed from %s to %s",
-				req.ID,
-				existing.Status,
-				currentStatus,
-			)
-
-			requestsToProcess = append(requestsToProcess, core.RequestToProcess{
-				Request:        req,
-				ExistingRecord: existing,
-			})
-			continue
+		outlook := core.NewOutlookCalendarBackend(
+			tenantID, clientID, clientSecret,
+			core.WithOutlookRateLimiter(core.NewRateLimiter(requestsPerSecond, int(requestsPerSecond))),
+		)
+		addBackendDomains(backends, outlookDomains, outlook)
+	}
+
+	if caldavDomains != "" {
+		calendarURL := os.Getenv("CALDAV_URL")
+		if calendarURL == "" {
+			return nil, configErrorf("CALDAV_DOMAINS requires CALDAV_URL")
 		}
 
-		log.Printf(
-			"Skipping Clockify request %s because status %s has already been processed",
-			req.ID,
-			currentStatus,
+		opts := []func(*core.CalDAVCalendarBackend){
+			core.WithCalDAVRateLimiter(core.NewRateLimiter(requestsPerSecond, int(requestsPerSecond))),
+		}
+		if primary := os.Getenv("CALDAV_PRIMARY_CALENDAR"); primary != "" {
+			opts = append(opts, core.WithCalDAVPrimaryCalendar(primary))
+		}
+		caldav := core.NewCalDAVCalendarBackend(
+			calendarURL, os.Getenv("CALDAV_USERNAME"), os.Getenv("CALDAV_PASSWORD"),
+			opts...,
 		)
+		addBackendDomains(backends, caldavDomains, caldav)
 	}
 
-	if len(requestsToProcess) == 0 {
-		fmt.Println("No requests queued for processing.")
-		return
+	return backends, nil
+}
+
+// addBackendDomains sends users in the comma-separated domains to backend.
+func addBackendDomains(backends *core.CalendarBackends, domains string, backend core.CalendarBackend) {
+	for domain := range strings.SplitSeq(domains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			backends.Domains[domain] = backend
+		}
 	}
+}
 
+// googleJWTConfig decodes the base64 service account credentials.
+func googleJWTConfig(credB64 string) (*jwt.Config, error) {
 	b, err := base64.StdEncoding.DecodeString(credB64)
 	if err != nil {
-		core.Die("invalid base64 GOOGLE_SERVICE_ACCOUNT_JSON_B64: %v", err)
+		return nil, configErrorf("invalid base64 GOOGLE_SERVICE_ACCOUNT_JSON_B64: %w", err)
 	}
 
 	jwtCfg, err := google.JWTConfigFromJSON(b, calendar.CalendarScope)
 	if err != nil {
-		core.Die("JWT config: %v", err)
+		return nil, configErrorf("JWT config: %w", err)
 	}
+	return jwtCfg, nil
+}
 
-	calendarIDs := []string{"primary"}
-	var syncErrs []error
+// newUserResolver maps Clockify users to Google accounts with the map in
+// USER_MAP_FILE, if set, then the Workspace directory, read as
+// GOOGLE_DIRECTORY_ADMIN_EMAIL, if set. Users in OUTLOOK_DOMAINS and
+// CALDAV_DOMAINS, who the directory doesn't know, and, without a directory,
+// users the map doesn't name keep their Clockify email.
+func newUserResolver(credB64 string) (core.UserResolver, error) {
+	var chain core.ChainUserResolver
 
-	for _, req := range requestsToProcess {
-		calendarEvents, err := core.SyncOOORequest(
-			ctx,
-			*jwtCfg,
-			req,
-			calendarIDs,
-		)
+	if path := os.Getenv("USER_MAP_FILE"); path != "" {
+		users, err := core.LoadUserMap(path)
 		if err != nil {
-			log.Printf(
-				"Failed to sync Clockify request %s: %v",
-				req.Request.ID,
-				err,
-			)
-
-			syncErrs = append(
-				syncErrs,
-				fmt.Errorf("sync request %s: %w", req.Request.ID, err),
-			)
-
-			continue
+			return nil, configErrorf("%w", err)
 		}
+		chain = append(chain, users)
+	}
 
-		log.Printf(
-			"Successfully synced Clockify request %s to Google Calendar",
-			req.Request.ID,
-		)
-
-		dynamoItem, err := req.Request.ToDynamoItem()
-
+	if adminEmail := os.Getenv("GOOGLE_DIRECTORY_ADMIN_EMAIL"); adminEmail != "" {
+		jwtCfg, err := googleJWTConfig(credB64)
 		if err != nil {
-			log.Printf(
-				"Failed to convert Clockify request %s to a DynamoDB item: %v",
-				req.Request.ID,
-				err,
-			)
-
-			syncErrs = append(
-				syncErrs,
-				fmt.Errorf("convert request %s to DynamoDB item: %w", req.Request.ID, err),
-			)
-			continue
+			return nil, err
+		}
+		if domains := nonGoogleDomains(); len(domains) > 0 {
+			chain = append(chain, &core.DomainUserResolver{
+				Domains:  domains,
+				Resolver: core.SameEmailResolver{},
+			})
 		}
+		chain = append(chain, core.NewDirectoryUserResolver(*jwtCfg, adminEmail))
+	} else {
+		chain = append(chain, core.SameEmailResolver{})
+	}
+
+	return chain, nil
+}
 
-		dynamoItem.SyncState = "synced"
-		dynamoItem.GoogleCalendarEvents = calendarEvents
-
-		if err := store.PutSyncedRequest(ctx, dynamoItem); err != nil {
-			log.Printf(
-				"Failed to store Clockify request %s in DynamoDB: %v",
-				req.Request.ID,
-				err,
-			)
-
-			syncErrs = append(
-				syncErrs,
-				fmt.Errorf("store request %s in DynamoDB: %w", req.Request.ID, err),
-			)
-			continue
+// nonGoogleDomains returns the lowercased domains in OUTLOOK_DOMAINS and
+// CALDAV_DOMAINS, whose users newCalendarBackend sends elsewhere than Google.
+func nonGoogleDomains() map[string]bool {
+	domains := make(map[string]bool)
+	for _, list := range []string{os.Getenv("OUTLOOK_DOMAINS"), os.Getenv("CALDAV_DOMAINS")} {
+		for _, domain := range splitList(list) {
+			domains[strings.ToLower(domain)] = true
 		}
+	}
+	return domains
+}
 
-		log.Printf(
-			"Successfully stored Clockify request %s in DynamoDB",
-			req.Request.ID,
-		)
+// newSlackStatusUpdater sets Slack statuses during time off with the admin
+// user token in SLACK_TOKEN, if set, and the text and emoji in
+// SLACK_STATUS_TEXT and SLACK_STATUS_EMOJI, if set.
+func newSlackStatusUpdater() *core.SlackStatusUpdater {
+	token := os.Getenv("SLACK_TOKEN")
+	if token == "" {
+		return nil
 	}
 
-	if err := errors.Join(syncErrs...); err != nil {
-		core.Die("sync completed with errors: %v", err)
+	slack := core.NewSlackStatusUpdater(token)
+	if text := os.Getenv("SLACK_STATUS_TEXT"); text != "" {
+		slack.Text = text
 	}
+	if emoji := os.Getenv("SLACK_STATUS_EMOJI"); emoji != "" {
+		slack.Emoji = emoji
+	}
+	return slack
+}
 
-	fmt.Println("Sync complete!")
+// loadCalendarRouting reads the calendar routing from the file named by
+// CALENDAR_ROUTING_FILE or inline from CALENDAR_ROUTING_JSON. With neither
+// set, events go to each user's primary calendar.
+func loadCalendarRouting() (core.CalendarRouting, error) {
+	if path := os.Getenv("CALENDAR_ROUTING_FILE"); path != "" {
+		return core.LoadCalendarRouting(path)
+	}
+	if raw := os.Getenv("CALENDAR_ROUTING_JSON"); raw != "" {
+		return core.ParseCalendarRouting([]byte(raw))
+	}
+	return core.CalendarRouting{}, nil
+}
+
+// loadEventConfig reads the event config from the file named by
+// EVENT_CONFIG_FILE or inline from EVENT_CONFIG_JSON. With neither set the
+// defaults are used.
+func loadEventConfig() (core.EventConfig, error) {
+	if path := os.Getenv("EVENT_CONFIG_FILE"); path != "" {
+		return core.LoadEventConfig(path)
+	}
+	if raw := os.Getenv("EVENT_CONFIG_JSON"); raw != "" {
+		return core.ParseEventConfig([]byte(raw))
+	}
+	return core.EventConfig{}, nil
 }
 
-func handler(ctx context.Context, e json.RawMessage) error {
-	var ev Event
+func configErrorf(format string, args ...any) error {
+	return &ConfigError{Err: fmt.Errorf(format, args...)}
+}
+
+// handler logs the run's report as JSON, so CloudWatch metric filters can key
+// off its counts, and returns it. Any error fails the invocation. Events with
+// "command": "backfill" or "reconcile" run those instead of a sync, and HTTP
+// requests from a function URL are served as Clockify webhooks or the feed.
+func handler(ctx context.Context, e json.RawMessage) (any, error) {
+	var command struct {
+		Command        string `json:"command"`
+		RequestContext struct {
+			HTTP struct {
+				Method string `json:"method"`
+			} `json:"http"`
+		} `json:"requestContext"`
+	}
 	if len(e) > 0 {
-		if err := json.Unmarshal(e, &ev); err != nil {
-			core.Die("invalid JSON event: %v", err)
+		if err := json.Unmarshal(e, &command); err != nil {
+			return nil, configErrorf("invalid JSON event: %w", err)
 		}
 	}
 
-	ev.Run(ctx)
-	return nil
+	if command.RequestContext.HTTP.Method != "" {
+		var req events.LambdaFunctionURLRequest
+		if err := json.Unmarshal(e, &req); err != nil {
+			return nil, configErrorf("invalid function URL event: %w", err)
+		}
+		return handleFunctionURL(ctx, req)
+	}
+
+	switch command.Command {
+	case "", "sync":
+		var ev Event
+		if len(e) > 0 {
+			if err := json.Unmarshal(e, &ev); err != nil {
+				return core.Report{}, configErrorf("invalid JSON event: %w", err)
+			}
+		}
+
+		report, err := ev.Run(ctx)
+
+		if b, jsonErr := json.Marshal(report); jsonErr == nil {
+			log.Printf("sync report: %s", b)
+		}
+
+		return report, err
+
+	case "backfill":
+		var ev BackfillEvent
+		if err := json.Unmarshal(e, &ev); err != nil {
+			return core.BackfillReport{}, configErrorf("invalid JSON event: %w", err)
+		}
+
+		report, err := ev.Run(ctx)
+
+		if b, jsonErr := json.Marshal(report); jsonErr == nil {
+			log.Printf("backfill report: %s", b)
+		}
+
+		return report, err
+
+	case "reconcile":
+		var ev ReconcileEvent
+		if err := json.Unmarshal(e, &ev); err != nil {
+			return core.ReconcileReport{}, configErrorf("invalid JSON event: %w", err)
+		}
+
+		report, err := ev.Run(ctx)
+
+		if b, jsonErr := json.Marshal(report); jsonErr == nil {
+			log.Printf("reconcile report: %s", b)
+		}
+
+		return report, err
+
+	default:
+		return nil, configErrorf("unknown command %q", command.Command)
+	}
 }
 
 func main() {
@@ -308,13 +633,35 @@ func main() {
 	}
 
 	// CLI mode
+	if len(os.Args) > 1 {
+		subcommands := map[string]func([]string){
+			"backfill":  runBackfillCLI,
+			"reconcile": runReconcileCLI,
+			"serve":     runServeCLI,
+		}
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := godotenv.Load(); err != nil {
+				fmt.Println("Warning: no .env file found, relying on environment vars")
+			}
+			run(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		periodStartStr   = flag.String("start", "", "Period start (RFC3339)")
-		periodEndStr     = flag.String("end", "", "Period end (RFC3339)")
-		filterBy         = flag.String("by", "activity", "Filter mode: period|activity")
-		activityStartStr = flag.String("activityStart", "", "Created or updated >= (RFC3339)")
-		activityEndStr   = flag.String("activityEnd", "", "Created or updated < (RFC3339)")
-		pageSize         = flag.Int("pageSize", 50, "Page size (1–200)")
+		periodStartStr      = flag.String("start", "", "Period start (RFC3339)")
+		periodEndStr        = flag.String("end", "", "Period end (RFC3339)")
+		filterBy            = flag.String("by", "activity", "Filter mode: period|activity")
+		activityStartStr    = flag.String("activityStart", "", "Created or updated >= (RFC3339); defaults to the stored watermark")
+		activityEndStr      = flag.String("activityEnd", "", "Created or updated < (RFC3339)")
+		concurrency         = flag.Int("concurrency", defaultConcurrency, "How many users to sync at once")
+		googleRPS           = flag.Float64("googleRequestsPerSecond", defaultGoogleRequestsPerSecond, "Maximum Google Calendar API requests per second")
+		dryRun              = flag.Bool("dry-run", false, "Print the planned calendar changes without making them")
+		watermarkOverlap    = flag.String("watermarkOverlap", "", "How far before the watermark to start (default 15m)")
+		maxAttempts         = flag.Int("maxAttempts", 0, "How many times a failing request is tried before it is dead-lettered (default 5)")
+		retryBackoff        = flag.String("retryBackoff", "", "Wait after a request first fails, doubling after each failure (default 5m)")
+		pageSize            = flag.Int("pageSize", 50, "Page size (1–200)")
+		pendingPlaceholders = flag.Bool("pendingPlaceholders", false, "Create tentative events for pending requests")
 	)
 
 	flag.Parse()
@@ -329,7 +676,24 @@ func main() {
 		ActivityEnd:   *activityEndStr,
 		FilterBy:      *filterBy,
 		PageSize:      *pageSize,
+
+		PendingPlaceholders: *pendingPlaceholders,
+		WatermarkOverlap:    *watermarkOverlap,
+		MaxAttempts:         *maxAttempts,
+		RetryBackoff:        *retryBackoff,
+		DryRun:              *dryRun,
+
+		Concurrency:             *concurrency,
+		GoogleRequestsPerSecond: *googleRPS,
 	}
 
-	ev.Run(context.Background())
+	report, err := ev.Run(context.Background())
+
+	if b, jsonErr := json.MarshalIndent(report, "", "  "); jsonErr == nil {
+		fmt.Println(string(b))
+	}
+
+	if err != nil {
+		core.Die("%v", err)
+	}
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/corbaltcode/ooo-calendar-sync/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCredB64 is a service account key that parses; nothing signs with it.
+var testCredB64 = base64.StdEncoding.EncodeToString([]byte(`{
+	"type": "service_account",
+	"client_email": "sync@project.iam.gserviceaccount.com",
+	"private_key": "unused"
+}`))
+
+func TestNewUserResolver_KeepsOtherBackendsUsersOutOfTheDirectory(t *testing.T) {
+	t.Setenv("USER_MAP_FILE", "")
+	t.Setenv("GOOGLE_DIRECTORY_ADMIN_EMAIL", "admin@example.com")
+	t.Setenv("OUTLOOK_DOMAINS", "contractor.example")
+	t.Setenv("CALDAV_DOMAINS", " Fastmail.example ,")
+
+	resolver, err := newUserResolver(testCredB64)
+	require.NoError(t, err)
+
+	// Neither lookup reaches the directory, which would need the network.
+	for _, email := range []string{"bob@contractor.example", "eve@fastmail.example"} {
+		got, err := resolver.ResolveUser(context.Background(), "clockify-user", email)
+		require.NoError(t, err)
+		assert.Equal(t, email, got)
+	}
+
+	chain, ok := resolver.(core.ChainUserResolver)
+	require.True(t, ok)
+	require.Len(t, chain, 2)
+	assert.IsType(t, &core.DirectoryUserResolver{}, chain[1], "everyone else is looked up in the directory")
+}
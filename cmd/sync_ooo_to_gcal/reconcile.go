@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corbaltcode/ooo-calendar-sync/core"
+)
+
+// ReconcileEvent asks for a reconcile run, from Lambda as
+// {"command": "reconcile", ...} or from the CLI's reconcile subcommand.
+type ReconcileEvent struct {
+	// Start and End bound the search for orphaned events (RFC3339). They
+	// default to defaultPeriodRange either side of now.
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// Users are searched for orphaned events besides every user with a sync
+	// record.
+	Users []string `json:"users"`
+
+	// Repair fixes the drift found instead of only reporting it. Orphaned
+	// events are only repaired when CLOCKIFY_API_KEY and WORKSPACE_ID are
+	// set, to check whether their requests still want them.
+	Repair bool `json:"repair"`
+}
+
+// Run reconciles the sync records with the users' calendars.
+func (e *ReconcileEvent) Run(ctx context.Context) (core.ReconcileReport, error) {
+	credB64 := os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON_B64")
+	if credB64 == "" {
+		return core.ReconcileReport{}, configErrorf("missing env GOOGLE_SERVICE_ACCOUNT_JSON_B64")
+	}
+	tableName := os.Getenv("DYNAMODB_TABLE_NAME")
+	if tableName == "" {
+		return core.ReconcileReport{}, configErrorf("missing env DYNAMODB_TABLE_NAME")
+	}
+
+	now := time.Now().UTC()
+	timeMin, timeMax := now.Add(-defaultPeriodRange), now.Add(defaultPeriodRange)
+	if e.Start != "" {
+		t, err := core.ParseTimeAny(e.Start)
+		if err != nil {
+			return core.ReconcileReport{}, configErrorf("invalid start time: %w", err)
+		}
+		timeMin = t
+	}
+	if e.End != "" {
+		t, err := core.ParseTimeAny(e.End)
+		if err != nil {
+			return core.ReconcileReport{}, configErrorf("invalid end time: %w", err)
+		}
+		timeMax = t
+	}
+
+	eventCfg, err := loadEventConfig()
+	if err != nil {
+		return core.ReconcileReport{}, configErrorf("%w", err)
+	}
+	calendars, err := loadCalendarRouting()
+	if err != nil {
+		return core.ReconcileReport{}, configErrorf("%w", err)
+	}
+
+	store, err := newDynamoStore(ctx, tableName)
+	if err != nil {
+		return core.ReconcileReport{}, err
+	}
+
+	backend, err := newCalendarBackend(credB64, 0)
+	if err != nil {
+		return core.ReconcileReport{}, err
+	}
+
+	opts := []func(*core.Reconciler){
+		core.WithReconcileOptions(core.SyncOptions{Events: eventCfg}),
+		core.WithReconcileUsers(e.Users...),
+	}
+	if e.Repair {
+		opts = append(opts, core.WithRepair())
+	}
+	if apiKey, workspaceID := os.Getenv("CLOCKIFY_API_KEY"), os.Getenv("WORKSPACE_ID"); apiKey != "" && workspaceID != "" {
+		opts = append(opts, core.WithReconcileSource(workspaceID, core.NewClockifyClient(apiKey)))
+	}
+
+	reconciler := core.NewReconciler(backend, store, calendars, opts...)
+
+	return reconciler.Reconcile(ctx, timeMin, timeMax)
+}
+
+// runReconcileCLI runs the reconcile subcommand with its own flags.
+func runReconcileCLI(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	var (
+		start  = fs.String("start", "", "Search for orphaned events from (RFC3339)")
+		end    = fs.String("end", "", "Search for orphaned events until (RFC3339)")
+		users  = fs.String("users", "", "Comma-separated user emails to also search for orphaned events")
+		repair = fs.Bool("repair", false, "Repair the drift found instead of only reporting it")
+	)
+	_ = fs.Parse(args)
+
+	ev := ReconcileEvent{
+		Start:  *start,
+		End:    *end,
+		Repair: *repair,
+	}
+	if *users != "" {
+		ev.Users = strings.Split(*users, ",")
+	}
+
+	report, err := ev.Run(context.Background())
+
+	if b, jsonErr := json.MarshalIndent(report, "", "  "); jsonErr == nil {
+		fmt.Println(string(b))
+	}
+
+	if err != nil {
+		core.Die("%v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/corbaltcode/ooo-calendar-sync/core"
+)
+
+// newWebhookHandler builds a core.WebhookHandler from the same environment as
+// a sync, plus CLOCKIFY_WEBHOOK_TOKENS, the comma-separated signing tokens of
+// the Clockify webhooks pointed at it. PENDING_PLACEHOLDERS=true writes
+// placeholders for pending requests.
+func newWebhookHandler(ctx context.Context) (*core.WebhookHandler, error) {
+	env, err := loadSyncEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := splitList(os.Getenv("CLOCKIFY_WEBHOOK_TOKENS"))
+	if len(tokens) == 0 {
+		return nil, configErrorf("missing env CLOCKIFY_WEBHOOK_TOKENS")
+	}
+
+	var pendingPlaceholders bool
+	if raw := os.Getenv("PENDING_PLACEHOLDERS"); raw != "" {
+		pendingPlaceholders, err = strconv.ParseBool(raw)
+		if err != nil {
+			return nil, configErrorf("invalid PENDING_PLACEHOLDERS %q: %w", raw, err)
+		}
+	}
+
+	eventCfg, err := loadEventConfig()
+	if err != nil {
+		return nil, configErrorf("%w", err)
+	}
+	calendars, err := loadCalendarRouting()
+	if err != nil {
+		return nil, configErrorf("%w", err)
+	}
+
+	store, err := newDynamoStore(ctx, env.tableName)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := newCalendarBackend(env.credB64, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := newUserResolver(env.credB64)
+	if err != nil {
+		return nil, err
+	}
+
+	syncerOpts := []func(*core.Syncer){
+		core.WithUserResolver(resolver),
+		core.WithSyncOptions(core.SyncOptions{
+			PendingPlaceholders: pendingPlaceholders,
+			Events:              eventCfg,
+		}),
+	}
+	if forcedSingleUser := os.Getenv("CLOCKIFY_FORCE_USER_ID"); forcedSingleUser != "" {
+		syncerOpts = append(syncerOpts, core.WithUsers(forcedSingleUser))
+	}
+	if slack := newSlackStatusUpdater(); slack != nil {
+		syncerOpts = append(syncerOpts, core.WithSlackStatuses(slack))
+	}
+
+	sink := &core.BackendCalendarSink{
+		Backend:   backend,
+		Calendars: calendars,
+	}
+	syncer := core.NewSyncer(env.workspaceID, core.NewClockifyClient(env.apiKey), sink, store, syncerOpts...)
+
+	return core.NewWebhookHandler(syncer, tokens...), nil
+}
+
+// newHTTPHandler serves the Clockify webhook receiver at webhookPath when
+// CLOCKIFY_WEBHOOK_TOKENS is set and the time-off feed at feedPath when
+// FEED_TOKENS is set.
+func newHTTPHandler(ctx context.Context, webhookPath, feedPath string) (http.Handler, error) {
+	mux := http.NewServeMux()
+	served := false
+
+	if os.Getenv("CLOCKIFY_WEBHOOK_TOKENS") != "" {
+		h, err := newWebhookHandler(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(webhookPath, h)
+		served = true
+	}
+	if os.Getenv("FEED_TOKENS") != "" {
+		h, err := newFeedHandler(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(feedPath, h)
+		served = true
+	}
+
+	if !served {
+		return nil, configErrorf("missing env CLOCKIFY_WEBHOOK_TOKENS or FEED_TOKENS")
+	}
+	return mux, nil
+}
+
+// functionURLHandler builds the handler handleFunctionURL serves once, on
+// the first request, and keeps it for the life of the Lambda execution
+// environment. The environment it is built from doesn't change, so neither
+// does an error building it.
+var functionURLHandler = sync.OnceValues(func() (http.Handler, error) {
+	return newHTTPHandler(context.Background(), "/", "/feed.ics")
+})
+
+// handleFunctionURL serves an HTTP request delivered through a Lambda
+// function URL or an API Gateway HTTP API, which share the 2.0 payload
+// format: /feed.ics is the time-off feed and any other path the webhook
+// receiver.
+func handleFunctionURL(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	h, err := functionURLHandler()
+	if err != nil {
+		return events.LambdaFunctionURLResponse{}, err
+	}
+	return serveFunctionURL(ctx, h, req), nil
+}
+
+// serveFunctionURL runs req through h.
+func serveFunctionURL(ctx context.Context, h http.Handler, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	body := req.Body
+	if req.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return events.LambdaFunctionURLResponse{StatusCode: http.StatusBadRequest, Body: "invalid base64 body"}
+		}
+		body = string(b)
+	}
+
+	target := req.RawPath
+	if req.RawQueryString != "" {
+		target += "?" + req.RawQueryString
+	}
+	r, err := http.NewRequestWithContext(ctx, req.RequestContext.HTTP.Method, target, strings.NewReader(body))
+	if err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusBadRequest, Body: err.Error()}
+	}
+	for name, value := range req.Headers {
+		r.Header.Set(name, value)
+	}
+
+	w := &functionURLResponseWriter{header: make(http.Header)}
+	h.ServeHTTP(w, r)
+	return w.response()
+}
+
+// functionURLResponseWriter buffers a response to return from a function
+// URL invocation.
+type functionURLResponseWriter struct {
+	header http.Header
+	status int
+	body   strings.Builder
+}
+
+func (w *functionURLResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *functionURLResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *functionURLResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// response returns what was written. Repeated headers are joined with
+// commas, except Set-Cookie, which the payload format carries separately.
+func (w *functionURLResponseWriter) response() events.LambdaFunctionURLResponse {
+	resp := events.LambdaFunctionURLResponse{
+		StatusCode: w.status,
+		Headers:    make(map[string]string, len(w.header)),
+		Body:       w.body.String(),
+	}
+	if resp.StatusCode == 0 {
+		resp.StatusCode = http.StatusOK
+	}
+
+	for name, values := range w.header {
+		if name == "Set-Cookie" {
+			resp.Cookies = values
+			continue
+		}
+		resp.Headers[name] = strings.Join(values, ", ")
+	}
+	return resp
+}
+
+// runServeCLI runs the webhook receiver and the time-off feed as a
+// standalone HTTP server.
+func runServeCLI(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var (
+		addr     = fs.String("addr", ":8080", "Address to listen on")
+		path     = fs.String("path", "/webhooks/clockify", "Path Clockify posts webhooks to")
+		feedPath = fs.String("feed-path", "/feed.ics", "Path the time-off feed is served at")
+	)
+	_ = fs.Parse(args)
+
+	h, err := newHTTPHandler(context.Background(), *path, *feedPath)
+	if err != nil {
+		core.Die("%v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		core.Die("%v", err)
+	}
+}
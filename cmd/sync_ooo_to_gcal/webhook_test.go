@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeFunctionURL_BuffersTheResponse(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/hooks?token=t", r.URL.String())
+		assert.Equal(t, "secret", r.Header.Get("Clockify-Signature"))
+		assert.Equal(t, `{"id":"request-1"}`, string(body))
+
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.WriteHeader(http.StatusAccepted)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("queued"))
+	})
+
+	var req events.LambdaFunctionURLRequest
+	req.RequestContext.HTTP.Method = "POST"
+	req.RawPath = "/hooks"
+	req.RawQueryString = "token=t"
+	req.Headers = map[string]string{"clockify-signature": "secret"}
+	req.Body = base64.StdEncoding.EncodeToString([]byte(`{"id":"request-1"}`))
+	req.IsBase64Encoded = true
+
+	resp := serveFunctionURL(context.Background(), h, req)
+
+	assert.Equal(t, events.LambdaFunctionURLResponse{
+		StatusCode: http.StatusAccepted,
+		Headers:    map[string]string{"Vary": "Accept, Origin"},
+		Body:       "queued",
+		Cookies:    []string{"a=1"},
+	}, resp)
+}
+
+func TestServeFunctionURL_DefaultsToOK(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var req events.LambdaFunctionURLRequest
+	req.RequestContext.HTTP.Method = "GET"
+	req.RawPath = "/feed.ics"
+
+	resp := serveFunctionURL(context.Background(), h, req)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Body)
+}
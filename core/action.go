@@ -0,0 +1,62 @@
+package core
+
+import "fmt"
+
+// SyncAction is what a sync does to the calendar for one Clockify request.
+type SyncAction string
+
+const (
+	SyncActionNone   SyncAction = "none"
+	SyncActionInsert SyncAction = "insert"
+	SyncActionUpdate SyncAction = "update"
+	SyncActionDelete SyncAction = "delete"
+
+	// SyncActionSkip marks a request whose synced record is already up to
+	// date, so it is not planned at all. Only dry runs report these.
+	SyncActionSkip SyncAction = "skip"
+)
+
+type SyncOptions struct {
+	// PendingPlaceholders puts a tentative event on the calendar while a
+	// request is awaiting approval. Without it pending requests are recorded
+	// but leave the calendar untouched.
+	PendingPlaceholders bool
+
+	// Events controls how the events themselves are written.
+	Events EventConfig
+}
+
+// PlanSyncAction decides the calendar outcome for a request from its current
+// status and whatever was synced for it before:
+//
+//   - APPROVED (and PENDING with placeholders) inserts events, or updates the
+//     ones already synced so they match the request.
+//   - REJECTED, WITHDRAWN, CANCELLED (and PENDING without placeholders)
+//     delete any events that were synced earlier, otherwise do nothing.
+func PlanSyncAction(req RequestToProcess, opts SyncOptions) (SyncAction, error) {
+	hasEvents := req.ExistingRecord != nil && len(req.ExistingRecord.GoogleCalendarEvents) > 0
+
+	switch status := req.Request.Status.StatusType; {
+	case status == ClockifyStatusApproved,
+		status == ClockifyStatusPending && opts.PendingPlaceholders:
+		if hasEvents {
+			return SyncActionUpdate, nil
+		}
+		return SyncActionInsert, nil
+
+	case status == ClockifyStatusPending,
+		status == ClockifyStatusRejected,
+		status == ClockifyStatusWithdrawn,
+		status == ClockifyStatusCancelled:
+		if hasEvents {
+			return SyncActionDelete, nil
+		}
+		return SyncActionNone, nil
+
+	default:
+		return SyncActionNone, fmt.Errorf(
+			"unsupported Clockify request status %q",
+			req.Request.Status.StatusType,
+		)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanSyncAction(t *testing.T) {
+	synced := &SyncedClockifyRequest{
+		ClockifyRequestID: "request-123",
+		GoogleCalendarEvents: []GoogleCalendarEvent{
+			{CalendarID: "primary", EventID: "event-1"},
+		},
+	}
+	recordedWithoutEvents := &SyncedClockifyRequest{ClockifyRequestID: "request-123"}
+
+	tests := []struct {
+		name     string
+		status   string
+		existing *SyncedClockifyRequest
+		opts     SyncOptions
+		want     SyncAction
+	}{
+		{"approved new", ClockifyStatusApproved, nil, SyncOptions{}, SyncActionInsert},
+		{"approved after rejection", ClockifyStatusApproved, recordedWithoutEvents, SyncOptions{}, SyncActionInsert},
+		{"approved already synced", ClockifyStatusApproved, synced, SyncOptions{}, SyncActionUpdate},
+		{"rejected new", ClockifyStatusRejected, nil, SyncOptions{}, SyncActionNone},
+		{"rejected after approval", ClockifyStatusRejected, synced, SyncOptions{}, SyncActionDelete},
+		{"withdrawn after approval", ClockifyStatusWithdrawn, synced, SyncOptions{}, SyncActionDelete},
+		{"cancelled after approval", ClockifyStatusCancelled, synced, SyncOptions{}, SyncActionDelete},
+		{"withdrawn before approval", ClockifyStatusWithdrawn, recordedWithoutEvents, SyncOptions{}, SyncActionNone},
+		{"pending without placeholders", ClockifyStatusPending, nil, SyncOptions{}, SyncActionNone},
+		{"pending with placeholders", ClockifyStatusPending, nil, SyncOptions{PendingPlaceholders: true}, SyncActionInsert},
+		{"pending placeholder already synced", ClockifyStatusPending, synced, SyncOptions{PendingPlaceholders: true}, SyncActionUpdate},
+		{"pending placeholder turned off", ClockifyStatusPending, synced, SyncOptions{}, SyncActionDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := RequestToProcess{
+				Request:        makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z"),
+				ExistingRecord: tt.existing,
+			}
+			req.Request.Status.StatusType = tt.status
+
+			got, err := PlanSyncAction(req, tt.opts)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPlanSyncAction_RejectsUnknownStatus(t *testing.T) {
+	req := RequestToProcess{Request: ClockifyRequest{ID: "request-123"}}
+	req.Request.Status.StatusType = "ARCHIVED"
+
+	_, err := PlanSyncAction(req, SyncOptions{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ARCHIVED")
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// CalendarBackends is a CalendarBackend that sends each call to the provider
+// of the user it is made for: the one named for the user's email in Users,
+// else for its domain in Domains, else Default. It lets one sync write to
+// Google for some users and Outlook for others.
+type CalendarBackends struct {
+	Default CalendarBackend
+	// Domains maps lowercased email domains, e.g. "contractor.example", to
+	// backends.
+	Domains map[string]CalendarBackend
+	// Users maps lowercased emails to backends.
+	Users map[string]CalendarBackend
+}
+
+func (b *CalendarBackends) backendFor(userEmail string) CalendarBackend {
+	email := strings.ToLower(userEmail)
+	if backend, ok := b.Users[email]; ok {
+		return backend
+	}
+	if _, domain, ok := strings.Cut(email, "@"); ok {
+		if backend, ok := b.Domains[domain]; ok {
+			return backend
+		}
+	}
+	return b.Default
+}
+
+func (b *CalendarBackends) FindEvents(
+	ctx context.Context,
+	userEmail, calendarID, clockifyRequestID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	return b.backendFor(userEmail).FindEvents(ctx, userEmail, calendarID, clockifyRequestID, timeMin, timeMax)
+}
+
+func (b *CalendarBackends) InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error) {
+	return b.backendFor(userEmail).InsertEvent(ctx, userEmail, calendarID, ev)
+}
+
+func (b *CalendarBackends) PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error {
+	return b.backendFor(userEmail).PatchEvent(ctx, userEmail, calendarID, eventID, ev)
+}
+
+func (b *CalendarBackends) patchesInPlace(userEmail string, current CalendarEvent, ev OOOEvent) bool {
+	patcher, ok := b.backendFor(userEmail).(inPlacePatcher)
+	return !ok || patcher.patchesInPlace(userEmail, current, ev)
+}
+
+func (b *CalendarBackends) DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error {
+	return b.backendFor(userEmail).DeleteEvent(ctx, userEmail, calendarID, eventID)
+}
+
+func (b *CalendarBackends) GetEvent(ctx context.Context, userEmail, calendarID, eventID string) (CalendarEvent, error) {
+	return b.backendFor(userEmail).GetEvent(ctx, userEmail, calendarID, eventID)
+}
+
+func (b *CalendarBackends) ListEvents(
+	ctx context.Context,
+	userEmail, calendarID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	return b.backendFor(userEmail).ListEvents(ctx, userEmail, calendarID, timeMin, timeMax)
+}
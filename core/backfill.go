@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+const defaultBackfillChunk = 30 * 24 * time.Hour
+
+type BackfillOptions struct {
+	// Chunk is how much of the time-off period each step fetches and syncs;
+	// zero means 30 days. The checkpoint advances a chunk at a time.
+	Chunk time.Duration
+
+	// Progress, when set, is called after each chunk.
+	Progress func(BackfillProgress)
+}
+
+// BackfillProgress describes a finished chunk of a backfill.
+type BackfillProgress struct {
+	Chunk       int
+	Chunks      int
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Report      Report
+	Err         error
+}
+
+// BackfillReport summarizes a backfill. Its Report totals the chunks synced
+// by this run; to keep it short, Results only holds failed requests.
+type BackfillReport struct {
+	Report
+
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	// ResumedFrom is the checkpoint an interrupted backfill continued from.
+	ResumedFrom time.Time `json:"resumedFrom,omitzero"`
+	// Checkpoint is how far the period has been backfilled without failures.
+	Checkpoint time.Time `json:"checkpoint"`
+
+	Chunks          int `json:"chunks"`
+	ChunksProcessed int `json:"chunksProcessed"`
+}
+
+// Backfill syncs the requests whose time off falls in [start, end), oldest
+// first, a chunk of the period at a time. Each chunk takes every request in
+// it regardless of when it was created or changed.
+//
+// Progress is checkpointed in the store after each chunk that synced without
+// failures, and a later Backfill of the same period resumes from the
+// checkpoint. Chunks after a failure are still processed, but the checkpoint
+// stays put so that the failed chunk is retried; requests that did sync are
+// skipped on the retry as usual.
+func (s *Syncer) Backfill(ctx context.Context, start, end time.Time, opts BackfillOptions) (BackfillReport, error) {
+	report := BackfillReport{
+		Report:      Report{DryRun: s.DryRun},
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Checkpoint:  start,
+	}
+
+	if !start.Before(end) {
+		return report, fmt.Errorf("backfill period start %s is not before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	chunk := opts.Chunk
+	if chunk <= 0 {
+		chunk = defaultBackfillChunk
+	}
+
+	name := s.backfillCheckpointName(start, end)
+
+	checkpoint, err := s.Store.GetWatermark(ctx, name)
+	if err != nil {
+		return report, fmt.Errorf("get backfill checkpoint %s: %w", name, err)
+	}
+	if checkpoint.After(start) {
+		log.Printf("Resuming backfill %s from %s", name, checkpoint.Format(time.RFC3339))
+		report.ResumedFrom = checkpoint
+		report.Checkpoint = checkpoint
+		start = checkpoint
+	}
+
+	var chunkStarts []time.Time
+	for t := start; t.Before(end); t = t.Add(chunk) {
+		chunkStarts = append(chunkStarts, t)
+	}
+	report.Chunks = len(chunkStarts)
+
+	var errs []error
+	contiguous, aborted := true, false
+
+	for i, chunkStart := range chunkStarts {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			aborted = true
+			break
+		}
+
+		chunkEnd := chunkStart.Add(chunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		// The activity window is left open so that every request in the
+		// chunk is considered, however long ago it was created.
+		chunkReport, err := s.Sync(ctx, SyncWindow{
+			PeriodStart:   chunkStart,
+			PeriodEnd:     chunkEnd,
+			ActivityStart: time.Time{},
+			ActivityEnd:   s.Clock(),
+		})
+		report.add(chunkReport)
+		report.ChunksProcessed++
+
+		if opts.Progress != nil {
+			opts.Progress(BackfillProgress{
+				Chunk:       i + 1,
+				Chunks:      len(chunkStarts),
+				PeriodStart: chunkStart,
+				PeriodEnd:   chunkEnd,
+				Report:      chunkReport,
+				Err:         err,
+			})
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chunk %s → %s: %w", chunkStart.Format(time.RFC3339), chunkEnd.Format(time.RFC3339), err))
+			contiguous = false
+
+			// Only request failures are worth carrying on past.
+			var partial *PartialSyncError
+			if !errors.As(err, &partial) {
+				aborted = true
+				break
+			}
+			continue
+		}
+
+		if !contiguous || s.DryRun {
+			continue
+		}
+		if err := s.Store.PutWatermark(ctx, name, chunkEnd); err != nil {
+			errs = append(errs, fmt.Errorf("store backfill checkpoint %s: %w", name, err))
+			aborted = true
+			break
+		}
+		report.Checkpoint = chunkEnd
+	}
+
+	err = errors.Join(errs...)
+	if err != nil && !aborted {
+		return report, &PartialSyncError{Failed: report.Failed, Err: err}
+	}
+	return report, err
+}
+
+// add totals a chunk's report into the backfill's.
+func (r *BackfillReport) add(chunk Report) {
+	r.Fetched += chunk.Fetched
+	r.Queued += chunk.Queued
+	r.Skipped += chunk.Skipped
+	r.Synced += chunk.Synced
+	r.Failed += chunk.Failed
+	r.Inserted += chunk.Inserted
+	r.Updated += chunk.Updated
+	r.Deleted += chunk.Deleted
+
+	r.Conflicts += chunk.Conflicts
+	r.Retried += chunk.Retried
+	r.DeadLettered += chunk.DeadLettered
+	for _, id := range chunk.DeadLetters {
+		r.deadLetter(id)
+	}
+	r.Unmapped += chunk.Unmapped
+	for _, userEmail := range chunk.UnmappedUsers {
+		if !slices.Contains(r.UnmappedUsers, userEmail) {
+			r.UnmappedUsers = append(r.UnmappedUsers, userEmail)
+		}
+	}
+
+	for _, result := range chunk.Results {
+		if result.Error != "" {
+			r.Results = append(r.Results, result)
+		}
+	}
+}
+
+// backfillCheckpointName keys a checkpoint by workspace and the exact
+// period, so a different range starts afresh.
+func (s *Syncer) backfillCheckpointName(start, end time.Time) string {
+	return fmt.Sprintf(
+		"backfill/%s/%s/%s",
+		s.WorkspaceID,
+		start.UTC().Format(time.RFC3339),
+		end.UTC().Format(time.RFC3339),
+	)
+}
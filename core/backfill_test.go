@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// periodClockifySource returns the requests whose period overlaps the
+// payload's and whose status it asks for, like Clockify does.
+type periodClockifySource struct {
+	requests []ClockifyRequest
+	calls    int
+}
+
+func (f *periodClockifySource) ListAllTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) (ClockifyEnvelope, error) {
+	f.calls++
+
+	var matched []ClockifyRequest
+	for _, r := range f.requests {
+		start, _ := ParseTimeAny(r.TimeOffPeriod.Period.Start)
+		end, _ := ParseTimeAny(r.TimeOffPeriod.Period.End)
+
+		if payload.Start != nil {
+			if from, _ := ParseTimeAny(*payload.Start); end.Before(from) {
+				continue
+			}
+		}
+		if payload.End != nil {
+			if to, _ := ParseTimeAny(*payload.End); !start.Before(to) {
+				continue
+			}
+		}
+		if !slices.Contains(payload.Statuses, r.Status.StatusType) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	return ClockifyEnvelope{Count: len(matched), Requests: matched}, nil
+}
+
+func TestSyncer_BackfillCheckpointsAndResumes(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	request := func(id, status, day string) ClockifyRequest {
+		r := makeRequestWithActivityTimestamps(id, "UTC", day+"T00:00:00Z", day+"T23:59:59Z", createdAt, createdAt)
+		r.Status.StatusType = status
+		return r
+	}
+
+	source := &periodClockifySource{requests: []ClockifyRequest{
+		request("january", ClockifyStatusApproved, "2025-01-15"),
+		request("february", ClockifyStatusApproved, "2025-02-15"),
+		request("february-fails", ClockifyStatusApproved, "2025-02-20"),
+		request("march", ClockifyStatusApproved, "2025-03-15"),
+		request("march-rejected", ClockifyStatusRejected, "2025-03-16"),
+		request("after-period", ClockifyStatusApproved, "2025-05-01"),
+	}}
+	sink := &fakeCalendarSink{fail: map[string]error{"february-fails": errors.New("calendar unavailable")}}
+	store := NewMemoryStore()
+	syncer := NewSyncer("ws", source, sink, store, WithStatuses(ClockifyStatusApproved))
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	chunk := 31 * 24 * time.Hour
+
+	var progress []BackfillProgress
+	report, err := syncer.Backfill(ctx, start, end, BackfillOptions{
+		Chunk:    chunk,
+		Progress: func(p BackfillProgress) { progress = append(progress, p) },
+	})
+
+	var partial *PartialSyncError
+	require.ErrorAs(t, err, &partial)
+	assert.Equal(t, 3, report.Chunks)
+	assert.Equal(t, 3, report.ChunksProcessed)
+	assert.Equal(t, 3, report.Synced)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "february-fails", report.Results[0].RequestID)
+
+	require.Len(t, progress, 3)
+	assert.Equal(t, 2, progress[1].Chunk)
+	assert.Error(t, progress[1].Err)
+	assert.Equal(t, end, progress[2].PeriodEnd)
+
+	// The checkpoint stops at the failed chunk although the next one synced.
+	assert.Equal(t, start.Add(chunk), report.Checkpoint)
+	assert.NotNil(t, mustGetSyncedRequest(t, store, "march"))
+	assert.Nil(t, mustGetSyncedRequest(t, store, "march-rejected"))
+	assert.Nil(t, mustGetSyncedRequest(t, store, "after-period"))
+
+	// Once the calendar recovers and the retry is due, the backfill resumes
+	// at the failed chunk.
+	sink.fail = nil
+	syncer.Clock = func() time.Time { return time.Now().Add(time.Hour) }
+	report, err = syncer.Backfill(ctx, start, end, BackfillOptions{Chunk: chunk})
+
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(chunk), report.ResumedFrom)
+	assert.Equal(t, 2, report.Chunks)
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, end, report.Checkpoint)
+	assert.NotNil(t, mustGetSyncedRequest(t, store, "february-fails"))
+
+	// A finished backfill has nothing left to do.
+	calls := source.calls
+	report, err = syncer.Backfill(ctx, start, end, BackfillOptions{Chunk: chunk})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Chunks)
+	assert.Equal(t, calls, source.calls)
+}
@@ -0,0 +1,383 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// caldavBusyStatus is the de facto property for Outlook's "show as", which
+// several CalDAV servers and clients honour. CalDAV has no out-of-office
+// event type, so the sync marks such events OOF.
+const caldavBusyStatus = "X-MICROSOFT-CDO-BUSYSTATUS"
+
+// CalDAVCalendarBackend writes to CalDAV calendars such as Fastmail's or
+// Nextcloud's, as an account that can write to every user's calendars.
+//
+// Events are stored as one resource each, named for their UID; their href
+// is the EventID.
+type CalDAVCalendarBackend struct {
+	calendarURL     string
+	username        string
+	password        string
+	primaryCalendar string
+	httpClient      *http.Client
+	limiter         *RateLimiter
+}
+
+// NewCalDAVCalendarBackend addresses calendars with calendarURL, in which
+// {user} is replaced by the user's email and {calendar} by the calendar ID,
+// e.g. "https://caldav.fastmail.com/dav/calendars/user/{user}/{calendar}/".
+// Requests are authenticated with username and password.
+func NewCalDAVCalendarBackend(
+	calendarURL, username, password string,
+	opts ...func(*CalDAVCalendarBackend),
+) *CalDAVCalendarBackend {
+	b := &CalDAVCalendarBackend{
+		calendarURL:     calendarURL,
+		username:        username,
+		password:        password,
+		primaryCalendar: "default",
+		httpClient:      http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.httpClient = rateLimitedClient(b.httpClient, b.limiter)
+	return b
+}
+
+// WithCalDAVPrimaryCalendar sets the calendar ID "primary" stands for, which
+// varies by server: "Default" on Fastmail, "personal" on Nextcloud.
+func WithCalDAVPrimaryCalendar(calendarID string) func(*CalDAVCalendarBackend) {
+	return func(b *CalDAVCalendarBackend) {
+		b.primaryCalendar = calendarID
+	}
+}
+
+// For testing: send requests with httpClient.
+func WithCalDAVHTTPClient(httpClient *http.Client) func(*CalDAVCalendarBackend) {
+	return func(b *CalDAVCalendarBackend) {
+		b.httpClient = httpClient
+	}
+}
+
+// WithCalDAVRateLimiter makes every CalDAV request wait on limiter.
+func WithCalDAVRateLimiter(limiter *RateLimiter) func(*CalDAVCalendarBackend) {
+	return func(b *CalDAVCalendarBackend) {
+		b.limiter = limiter
+	}
+}
+
+// FindEvents has the server match the request ID in its calendar-query,
+// rather than listing every tagged event in the window. A text-match is a
+// substring match, so the few events it returns are checked for the exact ID.
+func (b *CalDAVCalendarBackend) FindEvents(
+	ctx context.Context,
+	userEmail, calendarID, clockifyRequestID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	var id strings.Builder
+	if err := xml.EscapeText(&id, []byte(clockifyRequestID)); err != nil {
+		return nil, err
+	}
+	propFilter := fmt.Sprintf(
+		`<C:prop-filter name="%s"><C:text-match collation="i;octet">%s</C:text-match></C:prop-filter>`,
+		icalClockifyProperty, id.String(),
+	)
+
+	matches, err := b.query(ctx, userEmail, calendarID, propFilter, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []CalendarEvent
+	for _, event := range matches {
+		if event.ClockifyRequestID == clockifyRequestID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (b *CalDAVCalendarBackend) InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error) {
+	uid := strings.ToLower(rand.Text())
+	u, err := url.Parse(b.collection(userEmail, calendarID) + uid + ".ics")
+	if err != nil {
+		return "", fmt.Errorf("invalid CalDAV URL: %w", err)
+	}
+	href := u.EscapedPath()
+
+	header := http.Header{"If-None-Match": {"*"}}
+	if _, err := b.do(ctx, http.MethodPut, href, header, toICalendar(uid, ev, time.Now())); err != nil {
+		return "", err
+	}
+	return href, nil
+}
+
+// PatchEvent replaces the event, keeping its UID. It never returns
+// ErrEventTypeMismatch.
+func (b *CalDAVCalendarBackend) PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error {
+	uid := strings.TrimSuffix(path.Base(eventID), ".ics")
+
+	// If-Match: * fails rather than recreating a deleted event.
+	header := http.Header{"If-Match": {"*"}}
+	_, err := b.do(ctx, http.MethodPut, eventID, header, toICalendar(uid, ev, time.Now()))
+	return err
+}
+
+func (b *CalDAVCalendarBackend) DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error {
+	_, err := b.do(ctx, http.MethodDelete, eventID, nil, "")
+	return err
+}
+
+func (b *CalDAVCalendarBackend) GetEvent(ctx context.Context, userEmail, calendarID, eventID string) (CalendarEvent, error) {
+	data, err := b.do(ctx, http.MethodGet, eventID, nil, "")
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+
+	cal, err := parseICalendar(string(data))
+	if err != nil {
+		return CalendarEvent{}, fmt.Errorf("parse %s: %w", eventID, err)
+	}
+	events := cal.events()
+	if len(events) == 0 || events[0].text("STATUS") == "CANCELLED" {
+		return CalendarEvent{}, fmt.Errorf("%w: event %s is cancelled", ErrEventNotFound, eventID)
+	}
+	return fromICalEvent(eventID, events[0]), nil
+}
+
+// ListEvents runs a calendar-query REPORT for the events in the window
+// that carry a Clockify request ID.
+func (b *CalDAVCalendarBackend) ListEvents(
+	ctx context.Context,
+	userEmail, calendarID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	propFilter := fmt.Sprintf(`<C:prop-filter name="%s"/>`, icalClockifyProperty)
+	return b.query(ctx, userEmail, calendarID, propFilter, timeMin, timeMax)
+}
+
+// query runs a calendar-query REPORT for the events in the window that
+// match propFilter, a CalDAV prop-filter element, leaving out cancelled
+// events and those without a Clockify request ID.
+func (b *CalDAVCalendarBackend) query(
+	ctx context.Context,
+	userEmail, calendarID, propFilter string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	query := fmt.Sprintf(caldavCalendarQuery,
+		timeMin.UTC().Format(icalUTCLayout),
+		timeMax.UTC().Format(icalUTCLayout),
+		propFilter,
+	)
+
+	header := http.Header{
+		"Depth":        {"1"},
+		"Content-Type": {`application/xml; charset="utf-8"`},
+	}
+	data, err := b.do(ctx, "REPORT", b.collection(userEmail, calendarID), header, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms davMultistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("decode calendar-query response: %w", err)
+	}
+
+	var events []CalendarEvent
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if ps.Prop.CalendarData == "" {
+				continue
+			}
+
+			cal, err := parseICalendar(ps.Prop.CalendarData)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", resp.Href, err)
+			}
+			for _, vevent := range cal.events() {
+				event := fromICalEvent(resp.Href, vevent)
+				if event.ClockifyRequestID != "" && vevent.text("STATUS") != "CANCELLED" {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+const caldavCalendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <D:getetag/>
+    <C:calendar-data/>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%s" end="%s"/>
+        %s
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+// davMultistatus is the subset of a 207 Multi-Status response the sync
+// reads.
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// collection returns the URL of a user's calendar, with a trailing slash.
+func (b *CalDAVCalendarBackend) collection(userEmail, calendarID string) string {
+	if calendarID == "primary" || calendarID == userEmail {
+		calendarID = b.primaryCalendar
+	}
+
+	raw := strings.NewReplacer(
+		"{user}", url.PathEscape(userEmail),
+		"{calendar}", url.PathEscape(calendarID),
+	).Replace(b.calendarURL)
+	if !strings.HasSuffix(raw, "/") {
+		raw += "/"
+	}
+	return raw
+}
+
+// do sends a request to ref, an absolute URL or an href on the server, and
+// returns the response body. 404s, 410s and failed preconditions on
+// existing resources are returned as ErrEventNotFound.
+func (b *CalDAVCalendarBackend) do(
+	ctx context.Context,
+	method, ref string,
+	header http.Header,
+	body string,
+) ([]byte, error) {
+	base, err := url.Parse(b.calendarURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CalDAV URL %q: %w", b.calendarURL, err)
+	}
+	target, err := base.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CalDAV href %q: %w", ref, err)
+	}
+
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", `text/calendar; charset="utf-8"`)
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read CalDAV response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("caldav: %s %s: %s: %s", method, target.Path, resp.Status, bytes.TrimSpace(data))
+		switch {
+		case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+			return nil, fmt.Errorf("%w: %v", ErrEventNotFound, err)
+		case resp.StatusCode == http.StatusPreconditionFailed && req.Header.Get("If-Match") == "*":
+			return nil, fmt.Errorf("%w: %v", ErrEventNotFound, err)
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// toICalendar writes ev as a VCALENDAR holding one VEVENT.
+func toICalendar(uid string, ev OOOEvent, stamp time.Time) string {
+	var w icalWriter
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icalProductID)
+	w.line("BEGIN:VEVENT")
+	w.text("UID", uid)
+	w.line("DTSTAMP:" + stamp.UTC().Format(icalUTCLayout))
+	w.time("DTSTART", ev.Start, ev.AllDay)
+	w.time("DTEND", ev.End, ev.AllDay)
+	w.text("SUMMARY", ev.Summary)
+	if ev.Description != "" {
+		w.text("DESCRIPTION", ev.Description)
+	}
+
+	switch ev.Visibility {
+	case "public", "private", "confidential":
+		w.line("CLASS:" + strings.ToUpper(ev.Visibility))
+	}
+
+	if ev.Tentative {
+		w.line("STATUS:TENTATIVE")
+	} else {
+		w.line("STATUS:CONFIRMED")
+	}
+	if ev.Transparent {
+		w.line("TRANSP:TRANSPARENT")
+	} else {
+		w.line("TRANSP:OPAQUE")
+	}
+	if ev.OutOfOffice != nil {
+		w.line(caldavBusyStatus + ":OOF")
+	}
+
+	w.text(icalClockifyProperty, ev.ClockifyRequestID)
+	w.line("END:VEVENT")
+	w.line("END:VCALENDAR")
+	return w.String()
+}
+
+// fromICalEvent reads a VEVENT stored at href. Missing or unparseable times
+// are left zero, which never match a planned event.
+func fromICalEvent(href string, vevent *icalComponent) CalendarEvent {
+	found := CalendarEvent{
+		ID:                href,
+		ClockifyRequestID: vevent.text(icalClockifyProperty),
+		OutOfOffice:       strings.EqualFold(vevent.text(caldavBusyStatus), "OOF"),
+	}
+
+	if p, ok := vevent.prop("DTSTART"); ok {
+		found.Start, found.AllDay, _ = p.time()
+	}
+	if p, ok := vevent.prop("DTEND"); ok {
+		found.End, _, _ = p.time()
+	}
+	return found
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalDAVCalendarBackend_SyncOOORequest(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeCalDAV(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "America/New_York", "2025-12-10T05:00:00Z", "2025-12-11T04:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	inserted, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: req}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, inserted, 1)
+
+	collection := "/calendars/" + req.UserEmail + "/default/"
+	href := inserted[0].EventID
+	assert.True(t, strings.HasPrefix(href, collection), href)
+
+	stored := fake.resource(href)
+	assert.Contains(t, stored, "DTSTART;VALUE=DATE:20251210\r\n")
+	assert.Contains(t, stored, "DTEND;VALUE=DATE:20251211\r\n")
+	assert.Contains(t, stored, "SUMMARY:OOO — Vacation\r\n")
+	assert.Contains(t, stored, "X-MICROSOFT-CDO-BUSYSTATUS:OOF\r\n")
+	assert.Contains(t, stored, "X-CLOCKIFY-REQUEST-ID:request-123\r\n")
+
+	// Re-inserting finds the existing event instead of duplicating it.
+	found, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, found)
+	assert.Equal(t, 1, fake.count(collection))
+
+	// Moving the request rewrites the resource in place, keeping its UID.
+	existing := &SyncedClockifyRequest{ClockifyRequestID: req.ID, GoogleCalendarEvents: inserted}
+	moved := req
+	moved.TimeOffPeriod.Period.Start = "2025-12-15T05:00:00Z"
+	moved.TimeOffPeriod.Period.End = "2025-12-17T04:59:59Z"
+
+	updated, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: moved, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, updated)
+
+	cal, err := parseICalendar(fake.resource(href))
+	require.NoError(t, err)
+	vevent := cal.events()[0]
+	assert.Equal(t, strings.TrimSuffix(href[len(collection):], ".ics"), vevent.text("UID"))
+	got, err := backend.GetEvent(ctx, req.UserEmail, "primary", href)
+	require.NoError(t, err)
+	assert.Equal(t, CalendarEvent{
+		ID:                href,
+		ClockifyRequestID: "request-123",
+		Start:             time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC),
+		End:               time.Date(2025, 12, 17, 0, 0, 0, 0, time.UTC),
+		AllDay:            true,
+		OutOfOffice:       true,
+	}, got)
+
+	// Rejecting it deletes the resource; deleting it again is not an error,
+	// and patching it doesn't bring it back.
+	rejected := moved
+	rejected.Status.StatusType = ClockifyStatusRejected
+	existing.GoogleCalendarEvents = updated
+
+	remaining, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: rejected, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Zero(t, fake.count(collection))
+
+	require.NoError(t, DeleteOOOEvents(ctx, backend, req.UserEmail, updated))
+
+	ev, err := planOOOEvent(req, EventConfig{})
+	require.NoError(t, err)
+	err = backend.PatchEvent(ctx, req.UserEmail, "primary", href, ev)
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+	assert.Zero(t, fake.count(collection))
+}
+
+func TestCalDAVCalendarBackend_ListEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeCalDAV(t)
+	backend := fake.backend()
+	userEmail := "user@fastmail.example"
+
+	timed := OOOEvent{
+		ClockifyRequestID: "request-timed",
+		Summary:           "Dentist; then, lunch",
+		Description:       "Back at 2pm.\nCall if urgent — " + strings.Repeat("really ", 20),
+		Visibility:        "private",
+		Start:             time.Date(2025, 12, 3, 14, 0, 0, 0, time.UTC),
+		End:               time.Date(2025, 12, 3, 18, 0, 0, 0, time.UTC),
+		Tentative:         true,
+		Transparent:       true,
+	}
+	later := OOOEvent{
+		ClockifyRequestID: "request-later",
+		Summary:           "OOO",
+		Start:             time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC),
+		End:               time.Date(2025, 12, 21, 0, 0, 0, 0, time.UTC),
+		AllDay:            true,
+	}
+
+	timedHref, err := backend.InsertEvent(ctx, userEmail, "team", timed)
+	require.NoError(t, err)
+	_, err = backend.InsertEvent(ctx, userEmail, "team", later)
+	require.NoError(t, err)
+
+	stored := fake.resource(timedHref)
+	assert.Contains(t, stored, "DTSTART:20251203T140000Z\r\n")
+	assert.Contains(t, stored, "CLASS:PRIVATE\r\n")
+	assert.Contains(t, stored, "STATUS:TENTATIVE\r\n")
+	assert.Contains(t, stored, "TRANSP:TRANSPARENT\r\n")
+	for line := range strings.SplitSeq(stored, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+
+	cal, err := parseICalendar(stored)
+	require.NoError(t, err)
+	assert.Equal(t, timed.Summary, cal.events()[0].text("SUMMARY"))
+	assert.Equal(t, timed.Description, cal.events()[0].text("DESCRIPTION"))
+
+	events, err := backend.ListEvents(
+		ctx,
+		userEmail, "team",
+		time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []CalendarEvent{{
+		ID:                timedHref,
+		ClockifyRequestID: "request-timed",
+		Start:             timed.Start,
+		End:               timed.End,
+	}}, events)
+
+	_, err = backend.GetEvent(ctx, userEmail, "team", "/calendars/"+userEmail+"/team/missing.ics")
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+}
+
+func TestCalDAVCalendarBackend_FindEventsMatchesOnTheServer(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeCalDAV(t)
+	backend := fake.backend()
+	userEmail := "user@fastmail.example"
+
+	hrefs := make(map[string]string)
+	for _, requestID := range []string{"request-1", "request-12", "request-2", "<&>"} {
+		href, err := backend.InsertEvent(ctx, userEmail, "team", OOOEvent{
+			ClockifyRequestID: requestID,
+			Summary:           "OOO",
+			Start:             time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+			End:               time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC),
+			AllDay:            true,
+		})
+		require.NoError(t, err)
+		hrefs[requestID] = href
+	}
+
+	find := func(requestID string) []CalendarEvent {
+		events, err := backend.FindEvents(
+			ctx,
+			userEmail, "team", requestID,
+			time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC),
+		)
+		require.NoError(t, err)
+		return events
+	}
+
+	events := find("request-2")
+	require.Len(t, events, 1)
+	assert.Equal(t, hrefs["request-2"], events[0].ID)
+
+	events = find("<&>")
+	require.Len(t, events, 1)
+	assert.Equal(t, hrefs["<&>"], events[0].ID)
+
+	// The server only returns events containing the ID; a longer ID that
+	// contains it is dropped.
+	events = find("request-1")
+	require.Len(t, events, 1)
+	assert.Equal(t, hrefs["request-1"], events[0].ID)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	assert.Equal(t, 4, fake.returned, "only the matching events were sent")
+}
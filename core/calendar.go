@@ -6,10 +6,6 @@ import (
 	"fmt"
 	"log"
 	"time"
-
-	"golang.org/x/oauth2/jwt"
-	"google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
 type RequestToProcess struct {
@@ -17,101 +13,372 @@ type RequestToProcess struct {
 	ExistingRecord *SyncedClockifyRequest
 }
 
-func InsertOOOEvents(ctx context.Context, jwtCfg jwt.Config, r ClockifyRequest, calendarIDs []string) ([]GoogleCalendarEvent, error) {
-	var syncedEvents []GoogleCalendarEvent
-	var errs []error
+// CalendarBackend is a calendar provider the sync can write OOO events to.
+// Every call acts on behalf of userEmail, on one of that user's calendars.
+// Implementations return ErrEventNotFound for events that no longer exist.
+type CalendarBackend interface {
+	// FindEvents returns the events tagged with clockifyRequestID that
+	// overlap [timeMin, timeMax).
+	FindEvents(
+		ctx context.Context,
+		userEmail, calendarID, clockifyRequestID string,
+		timeMin, timeMax time.Time,
+	) ([]CalendarEvent, error)
+	// InsertEvent creates ev and returns its ID.
+	InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error)
+	// PatchEvent overwrites an existing event with ev. It returns
+	// ErrEventTypeMismatch when the provider can't convert the event in
+	// place, e.g. between a regular and an out-of-office event.
+	PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error
+	DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error
+
+	// GetEvent returns one event by ID.
+	GetEvent(ctx context.Context, userEmail, calendarID, eventID string) (CalendarEvent, error)
+	// ListEvents returns every event tagged with any Clockify request ID that
+	// overlaps [timeMin, timeMax).
+	ListEvents(
+		ctx context.Context,
+		userEmail, calendarID string,
+		timeMin, timeMax time.Time,
+	) ([]CalendarEvent, error)
+}
+
+var (
+	_ CalendarBackend = (*GoogleCalendarBackend)(nil)
+	_ CalendarBackend = (*OutlookCalendarBackend)(nil)
+	_ CalendarBackend = (*CalDAVCalendarBackend)(nil)
+	_ CalendarBackend = (*CalendarBackends)(nil)
+)
+
+// inPlacePatcher is implemented by backends whose PatchEvent returns
+// ErrEventTypeMismatch for some events. patchesInPlace reports whether
+// patching current to ev would work, so that dry runs can predict when an
+// event is replaced instead.
+type inPlacePatcher interface {
+	patchesInPlace(userEmail string, current CalendarEvent, ev OOOEvent) bool
+}
+
+var (
+	_ inPlacePatcher = (*GoogleCalendarBackend)(nil)
+	_ inPlacePatcher = (*CalendarBackends)(nil)
+)
+
+var (
+	ErrEventNotFound     = errors.New("calendar event not found")
+	ErrEventTypeMismatch = errors.New("calendar event type cannot be changed in place")
+)
+
+// OOOEvent is the provider-neutral event a Clockify request should be
+// represented by.
+type OOOEvent struct {
+	ClockifyRequestID string
+	Summary           string
+	Description       string
+	ColorID           string
+	// Visibility is default, public, private or confidential; empty leaves
+	// the calendar's default.
+	Visibility string
+
+	// Start and End bound the event, End exclusive. For AllDay events they
+	// are local midnights in the user's time zone and only their dates matter.
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+
+	// Tentative marks a placeholder for a request that is awaiting approval;
+	// it should not block the user's time.
+	Tentative bool
+	// Transparent shows the user as free rather than busy. Tentative events
+	// are always transparent.
+	Transparent bool
+
+	// OutOfOffice asks for the provider's native out-of-office event, which
+	// declines conflicting meetings. It is only kept for the user's primary
+	// calendar; see forTarget.
+	OutOfOffice *OutOfOfficeSettings
+}
+
+type OutOfOfficeSettings struct {
+	AutoDeclineMode string
+	DeclineMessage  string
+}
+
+// forTarget adapts ev, planned for userEmail, to the calendar it is written
+// to: out-of-office events only exist on a user's own primary calendar, so
+// other calendars get a regular event. On shared calendars the summary is
+// prefixed with the user, who would otherwise be anonymous there.
+func (e OOOEvent) forTarget(userEmail string, target CalendarTarget) OOOEvent {
+	if target.shared(userEmail) {
+		e.OutOfOffice = nil
+		e.Summary = userEmail + ": " + e.Summary
+		return e
+	}
+	if target.CalendarID != "primary" && target.CalendarID != userEmail {
+		e.OutOfOffice = nil
+	}
+	return e
+}
+
+// CalendarEvent is an event found on a calendar.
+type CalendarEvent struct {
+	ID                string
+	ClockifyRequestID string
+	Start             time.Time
+	End               time.Time
+	AllDay            bool
+	OutOfOffice       bool
+}
+
+// matches reports whether an existing calendar event already has the
+// planned type and covers the planned time.
+func (e OOOEvent) matches(found CalendarEvent) bool {
+	if (e.OutOfOffice != nil) != found.OutOfOffice {
+		return false
+	}
+	if e.AllDay && found.AllDay {
+		return formatDate(e.Start) == formatDate(found.Start) && formatDate(e.End) == formatDate(found.End)
+	}
+	// Providers may store whole days as timed events between local
+	// midnights, so timed matches are compared by instant.
+	return e.Start.Equal(found.Start) && e.End.Equal(found.End)
+}
 
+// formatDate formats t as YYYY-MM-DD in its own location, the form all-day
+// events are exchanged in.
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func planOOOEvent(r ClockifyRequest, cfg EventConfig) (OOOEvent, error) {
 	// Load user's local timezone
 	loc, err := time.LoadLocation(r.UserTimeZone)
 	if err != nil {
 		log.Printf("skip %s: unknown tz %q: %v", r.ID, r.UserTimeZone, err)
-		return nil, fmt.Errorf("req=%s user=%s: unknown tz %q: %w", r.ID, r.UserEmail, r.UserTimeZone, err)
+		return OOOEvent{}, fmt.Errorf("req=%s user=%s: unknown tz %q: %w", r.ID, r.UserEmail, r.UserTimeZone, err)
 	}
 
 	startUTC, err := ParseTimeAny(r.TimeOffPeriod.Period.Start)
 	if err != nil {
 		log.Printf("skip %s: bad period.start: %v", r.ID, err)
-		return nil, fmt.Errorf("req=%s user=%s: bad period.start: %w", r.ID, r.UserEmail, err)
+		return OOOEvent{}, fmt.Errorf("req=%s user=%s: bad period.start: %w", r.ID, r.UserEmail, err)
 	}
 	endUTC, err := ParseTimeAny(r.TimeOffPeriod.Period.End)
 	if err != nil {
 		log.Printf("skip %s: bad period.end: %v", r.ID, err)
-		return nil, fmt.Errorf("req=%s user=%s: bad period.end: %w", r.ID, r.UserEmail, err)
+		return OOOEvent{}, fmt.Errorf("req=%s user=%s: bad period.end: %w", r.ID, r.UserEmail, err)
 	}
 
 	// Normalize to local dates
 	startLocal := startUTC.In(loc)
 	endLocal := endUTC.In(loc)
 
-	y1, m1, d1 := startLocal.Date()
-	y2, m2, d2 := endLocal.Date()
+	start, end, allDay := partialDayPeriod(r, startLocal, endLocal)
+	if allDay {
+		y1, m1, d1 := startLocal.Date()
+		y2, m2, d2 := endLocal.Date()
 
-	// All-day local time window used for Events.List (TimeMin / TimeMax).
-	allDayStart := time.Date(y1, m1, d1, 0, 0, 0, 0, loc)
-	// Clockify is inclusive; GCal all-day is [start, end) exclusive.
-	// So cover the last OOO day by adding +1 local day to the end date.
-	allDayEndExclusive := time.Date(y2, m2, d2, 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		start = time.Date(y1, m1, d1, 0, 0, 0, 0, loc)
+		// Clockify is inclusive; calendar all-day events are [start, end)
+		// exclusive. So cover the last OOO day by adding +1 local day to the end.
+		end = time.Date(y2, m2, d2, 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	}
 
-	// YYYY-MM-DD string format is used for the Insert event payload.
-	startDate := allDayStart.Format("2006-01-02")
-	endDate := allDayEndExclusive.Format("2006-01-02")
+	details, err := cfg.templateFor(r.PolicyName).render(r)
+	if err != nil {
+		return OOOEvent{}, fmt.Errorf("req=%s user=%s: %w", r.ID, r.UserEmail, err)
+	}
 
-	cfg := jwtCfg
-	cfg.Subject = r.UserEmail
-	client := cfg.Client(ctx)
+	// Pending requests are placeholders: tentative and not blocking time.
+	tentative := r.Status.StatusType == ClockifyStatusPending
+	if tentative {
+		details.Summary += " (pending)"
+	}
 
-	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		log.Printf("user %s: calendar service error: %v", r.UserEmail, err)
-		return nil, fmt.Errorf("req=%s user=%s: calendar service error: %w", r.ID, r.UserEmail, err)
+	ev := OOOEvent{
+		ClockifyRequestID: r.ID,
+		Summary:           details.Summary,
+		Description:       details.Description,
+		ColorID:           details.ColorID,
+		Visibility:        details.Visibility,
+		Start:             start,
+		End:               end,
+		AllDay:            allDay,
+		Tentative:         tentative,
+		Transparent:       tentative || details.Transparent,
+	}
+
+	// A placeholder must not decline anybody's meetings.
+	if !tentative {
+		ev.OutOfOffice = cfg.OutOfOffice.settingsFor(r.PolicyName)
+	}
+
+	return ev, nil
+}
+
+// partialDayPeriod returns the timed span a request covers when it is less
+// than a full day: tracked in hours, flagged half-day, or, when the unit
+// isn't known, shorter than a local day and not from midnight to midnight.
+// Requests tracked in days are full days even when their period doesn't line
+// up with the user's midnight, as when Clockify planned them in another
+// time zone. Otherwise allDay is true and the span is left to the caller.
+func partialDayPeriod(r ClockifyRequest, startLocal, endLocal time.Time) (start, end time.Time, allDay bool) {
+	period := r.TimeOffPeriod
+	loc := startLocal.Location()
+
+	if period.HalfDay {
+		hoursStart, errStart := ParseTimeAny(period.HalfDayHours.Start)
+		hoursEnd, errEnd := ParseTimeAny(period.HalfDayHours.End)
+		if errStart == nil && errEnd == nil && hoursEnd.After(hoursStart) {
+			return hoursStart.In(loc), roundUpToMinute(hoursEnd.In(loc)), false
+		}
+
+		// Without hours, a half-day period spanning the whole day is split
+		// at local noon.
+		y, m, d := startLocal.Date()
+		noon := time.Date(y, m, d, 12, 0, 0, 0, loc)
+		switch period.HalfDayPeriod {
+		case ClockifyHalfDayFirstHalf:
+			return time.Date(y, m, d, 0, 0, 0, 0, loc), noon, false
+		case ClockifyHalfDaySecondHalf:
+			return noon, time.Date(y, m, d, 0, 0, 0, 0, loc).AddDate(0, 0, 1), false
+		}
+		return startLocal, roundUpToMinute(endLocal), false
+	}
+
+	if r.TimeUnit == ClockifyTimeUnitHours ||
+		r.TimeUnit != ClockifyTimeUnitDays && underADay(startLocal, endLocal) && !wholeDays(startLocal, endLocal) {
+		return startLocal, roundUpToMinute(endLocal), false
+	}
+
+	return time.Time{}, time.Time{}, true
+}
+
+// wholeDays reports whether a period starts at local midnight and ends at
+// the end of a local day, 23:59:59 or the next midnight. It goes by the wall
+// clock rather than the elapsed time, as days are 23 or 25 hours long when
+// DST changes.
+func wholeDays(startLocal, endLocal time.Time) bool {
+	return endLocal.After(startLocal) &&
+		isLocalMidnight(startLocal) &&
+		isLocalMidnight(roundUpToMinute(endLocal))
+}
+
+// underADay reports whether a period ends before the same wall-clock time
+// on the next day.
+func underADay(startLocal, endLocal time.Time) bool {
+	return roundUpToMinute(endLocal).Before(startLocal.AddDate(0, 0, 1))
+}
+
+func isLocalMidnight(t time.Time) bool {
+	y, m, d := t.Date()
+	return t.Equal(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+}
+
+// roundUpToMinute turns Clockify's inclusive end times, like 12:59:59.999,
+// into the exclusive minute calendars expect.
+func roundUpToMinute(t time.Time) time.Time {
+	if truncated := t.Truncate(time.Minute); !truncated.Equal(t) {
+		return truncated.Add(time.Minute)
 	}
+	return t
+}
 
-	summary := "[TEST] OOO"
-	if r.PolicyName != "" {
-		summary = fmt.Sprintf("[TEST] OOO — %s", r.PolicyName)
+// moveEvent patches an existing event to ev, replacing it when the provider
+// can't change its type in place. It returns the event's ID, which changes
+// when the event had to be replaced.
+func moveEvent(
+	ctx context.Context,
+	backend CalendarBackend,
+	userEmail, calendarID, eventID string,
+	ev OOOEvent,
+) (string, error) {
+	err := backend.PatchEvent(ctx, userEmail, calendarID, eventID, ev)
+	if !errors.Is(err, ErrEventTypeMismatch) {
+		return eventID, err
 	}
-	ev := &calendar.Event{
-		Summary:     summary,
-		Description: fmt.Sprintf("Clockify request: %s\nCreatedAt: %s", r.ID, r.CreatedAt),
-		Start:       &calendar.EventDateTime{Date: startDate},
-		End:         &calendar.EventDateTime{Date: endDate}, // exclusive
-		// Attaching the Clockify request ID as a private extended property.
-		// TODO: Before inserting, check for an existing event with this key and insert event/skip:
-		ExtendedProperties: &calendar.EventExtendedProperties{
-			Private: map[string]string{
-				"clockifyRequestId": r.ID,
-			},
-		},
+
+	if err := backend.DeleteEvent(ctx, userEmail, calendarID, eventID); err != nil && !errors.Is(err, ErrEventNotFound) {
+		return eventID, fmt.Errorf("replace event %s: %w", eventID, err)
+	}
+
+	newID, err := backend.InsertEvent(ctx, userEmail, calendarID, ev)
+	if err != nil {
+		// Keep the old ID; the next attempt finds it gone and recreates it.
+		return eventID, fmt.Errorf("replace event %s: %w", eventID, err)
+	}
+	return newID, nil
+}
+
+func InsertOOOEvents(
+	ctx context.Context,
+	backend CalendarBackend,
+	r ClockifyRequest,
+	targets []CalendarTarget,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	var syncedEvents []GoogleCalendarEvent
+	var errs []error
+
+	planned, err := planOOOEvent(r, opts.Events)
+	if err != nil {
+		return nil, err
 	}
 
 	// Insert into calendars
-	for _, calID := range calendarIDs {
-		existing, err := findClockifyEvents(
-			ctx, srv, calID, r.ID,
-			allDayStart, allDayEndExclusive,
+	for _, target := range targets {
+		ev := planned.forTarget(r.UserEmail, target)
+		user, calID := target.account(r.UserEmail), target.CalendarID
+
+		existing, err := backend.FindEvents(
+			ctx, user, calID, r.ID,
+			ev.Start, ev.End,
 		)
 		if err != nil {
 			log.Printf("lookup %s (user=%s cal=%s) failed: %v",
-				r.ID, r.UserEmail, calID, err)
-			errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s: lookup failed: %w", r.ID, r.UserEmail, calID, err))
+				r.ID, user, calID, err)
+			errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s: lookup failed: %w", r.ID, user, calID, err))
 			continue
 		}
 
 		if len(existing) > 0 {
 			for _, e := range existing {
+				if ev.matches(e) {
+					syncedEvents = append(syncedEvents, GoogleCalendarEvent{
+						CalendarID: calID,
+						EventID:    e.ID,
+						Owner:      target.Owner,
+					})
+
+					log.Printf(
+						"FOUND existing OOO event for req=%s user=%s cal=%s eventId=%s (%s → %s)",
+						r.ID,
+						user,
+						calID,
+						e.ID,
+						formatDate(e.Start),
+						formatDate(e.End),
+					)
+					continue
+				}
+
+				// The lookup window overlaps a stale event whose dates or type
+				// no longer match the request; move it rather than keep it.
+				eventID, err := moveEvent(ctx, backend, user, calID, e.ID, ev)
 				syncedEvents = append(syncedEvents, GoogleCalendarEvent{
 					CalendarID: calID,
-					EventID:    e.Id,
+					EventID:    eventID,
+					Owner:      target.Owner,
 				})
+				if err != nil {
+					log.Printf("patch stale %s (user=%s cal=%s eventId=%s) failed: %v",
+						r.ID, user, calID, e.ID, err)
+					errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s event=%s: patch failed: %w", r.ID, user, calID, e.ID, err))
+					continue
+				}
 
 				log.Printf(
-					"FOUND existing OOO event for req=%s user=%s cal=%s eventId=%s (%s → %s)",
-					r.ID,
-					r.UserEmail,
-					calID,
-					e.Id,
-					e.Start.Date,
-					e.End.Date,
+					"MOVED stale OOO event for req=%s user=%s cal=%s eventId=%s (%s → %s)",
+					r.ID, user, calID, eventID, formatDate(ev.Start), formatDate(ev.End),
 				)
 			}
 
@@ -119,49 +386,157 @@ func InsertOOOEvents(ctx context.Context, jwtCfg jwt.Config, r ClockifyRequest,
 		}
 
 		// No existing event
-		insertedEvent, err := srv.Events.Insert(calID, ev).Do()
+		eventID, err := backend.InsertEvent(ctx, user, calID, ev)
 		if err != nil {
 			log.Printf("insert %s (user=%s cal=%s) failed: %v",
-				r.ID, r.UserEmail, calID, err)
-			errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s: insert failed: %w", r.ID, r.UserEmail, calID, err))
+				r.ID, user, calID, err)
+			errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s: insert failed: %w", r.ID, user, calID, err))
 			continue
 		}
 
 		syncedEvents = append(syncedEvents, GoogleCalendarEvent{
 			CalendarID: calID,
-			EventID:    insertedEvent.Id,
+			EventID:    eventID,
+			Owner:      target.Owner,
 		})
 
 		log.Printf(
 			"Inserted OOO for req=%s user=%s cal=%s (%s → %s)\n",
-			r.ID, r.UserEmail, calID, startDate, endDate,
+			r.ID, user, calID, formatDate(ev.Start), formatDate(ev.End),
 		)
 	}
 
 	return syncedEvents, errors.Join(errs...)
 }
 
-func DeleteOOOEvents(
+// UpdateOOOEvents moves previously synced events to the request's current
+// dates and wording. Events that no longer exist in the calendar are
+// recreated in the same calendar.
+func UpdateOOOEvents(
 	ctx context.Context,
-	jwtCfg jwt.Config,
-	userEmail string,
+	backend CalendarBackend,
+	r ClockifyRequest,
 	events []GoogleCalendarEvent,
-) error {
-	cfg := jwtCfg
-	cfg.Subject = userEmail
-	client := cfg.Client(ctx)
-	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
-
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	planned, err := planOOOEvent(r, opts.Events)
 	if err != nil {
-		return fmt.Errorf("create calendar service: %w", err)
+		return nil, err
 	}
 
+	var syncedEvents []GoogleCalendarEvent
 	var errs []error
 
 	for _, event := range events {
-		err := srv.Events.
-			Delete(event.CalendarID, event.EventID).
-			Do()
+		ev := planned.forTarget(r.UserEmail, event.target())
+		user := event.target().account(r.UserEmail)
+
+		eventID, err := moveEvent(ctx, backend, user, event.CalendarID, event.EventID, ev)
+		if err == nil {
+			event.EventID = eventID
+			syncedEvents = append(syncedEvents, event)
+
+			log.Printf(
+				"UPDATED OOO event for req=%s user=%s cal=%s eventId=%s (%s → %s)",
+				r.ID, user, event.CalendarID, event.EventID, formatDate(ev.Start), formatDate(ev.End),
+			)
+			continue
+		}
+
+		if !errors.Is(err, ErrEventNotFound) {
+			log.Printf("patch %s (user=%s cal=%s eventId=%s) failed: %v",
+				r.ID, user, event.CalendarID, event.EventID, err)
+			errs = append(errs, fmt.Errorf("req=%s user=%s cal=%s event=%s: patch failed: %w", r.ID, user, event.CalendarID, event.EventID, err))
+
+			// Keep tracking the event so a later run can retry the move.
+			syncedEvents = append(syncedEvents, event)
+			continue
+		}
+
+		inserted, err := InsertOOOEvents(ctx, backend, r, []CalendarTarget{event.target()}, opts)
+		syncedEvents = append(syncedEvents, inserted...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return syncedEvents, errors.Join(errs...)
+}
+
+// rerouteOOOEvents updates a request's events for the calendars targets now
+// route it to, which change with its policy or the routing: events on
+// calendars still routed to are moved, calendars newly routed to get an
+// event, and events on calendars no longer routed to are deleted.
+func rerouteOOOEvents(
+	ctx context.Context,
+	backend CalendarBackend,
+	r ClockifyRequest,
+	events []GoogleCalendarEvent,
+	targets []CalendarTarget,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	routed := make(map[CalendarTarget]bool, len(targets))
+	for _, target := range targets {
+		routed[target] = true
+	}
+
+	var kept, unrouted []GoogleCalendarEvent
+	recorded := make(map[CalendarTarget]bool, len(events))
+	for _, event := range events {
+		if routed[event.target()] {
+			kept = append(kept, event)
+			recorded[event.target()] = true
+		} else {
+			unrouted = append(unrouted, event)
+		}
+	}
+
+	var unrecorded []CalendarTarget
+	for _, target := range targets {
+		if !recorded[target] {
+			unrecorded = append(unrecorded, target)
+		}
+	}
+
+	syncedEvents, err := UpdateOOOEvents(ctx, backend, r, kept, opts)
+	errs := []error{err}
+
+	if len(unrecorded) > 0 {
+		inserted, err := InsertOOOEvents(ctx, backend, r, unrecorded, opts)
+		syncedEvents = append(syncedEvents, inserted...)
+		errs = append(errs, err)
+	}
+
+	if err := DeleteOOOEvents(ctx, backend, r.UserEmail, unrouted); err != nil {
+		// Keep tracking them so a later run can retry; events that did go
+		// are skipped as already deleted then.
+		syncedEvents = append(syncedEvents, unrouted...)
+		errs = append(errs, err)
+	}
+
+	return syncedEvents, errors.Join(errs...)
+}
+
+// DeleteOOOEvents deletes a request's events, each as the account that wrote
+// it: userEmail, or the owner of a shared calendar.
+func DeleteOOOEvents(
+	ctx context.Context,
+	backend CalendarBackend,
+	userEmail string,
+	events []GoogleCalendarEvent,
+) error {
+	var errs []error
+
+	for _, event := range events {
+		err := backend.DeleteEvent(ctx, event.target().account(userEmail), event.CalendarID, event.EventID)
+		if errors.Is(err, ErrEventNotFound) {
+			log.Printf(
+				"OOO event cal=%s eventId=%s was already deleted",
+				event.CalendarID,
+				event.EventID,
+			)
+			continue
+		}
 		if err != nil {
 			errs = append(
 				errs,
@@ -185,32 +560,45 @@ func DeleteOOOEvents(
 	return errors.Join(errs...)
 }
 
+// SyncOOORequest applies the action chosen by PlanSyncAction and returns the
+// calendar events that now represent the request. New requests are written to
+// targets; updates and deletes act on the events already recorded.
 func SyncOOORequest(
 	ctx context.Context,
-	jwtCfg jwt.Config,
+	backend CalendarBackend,
 	req RequestToProcess,
-	calendarIDs []string,
+	targets []CalendarTarget,
+	opts SyncOptions,
 ) ([]GoogleCalendarEvent, error) {
-	switch req.Request.Status.StatusType {
-	case ClockifyStatusApproved:
+	action, err := PlanSyncAction(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case SyncActionInsert:
 		return InsertOOOEvents(
 			ctx,
-			jwtCfg,
+			backend,
 			req.Request,
-			calendarIDs,
+			targets,
+			opts,
 		)
 
-	case ClockifyStatusRejected:
-		if req.ExistingRecord == nil {
-			return nil, fmt.Errorf(
-				"cannot reject Clockify request %s: no existing synced record",
-				req.Request.ID,
-			)
-		}
+	case SyncActionUpdate:
+		return rerouteOOOEvents(
+			ctx,
+			backend,
+			req.Request,
+			req.ExistingRecord.GoogleCalendarEvents,
+			targets,
+			opts,
+		)
 
+	case SyncActionDelete:
 		err := DeleteOOOEvents(
 			ctx,
-			jwtCfg,
+			backend,
 			req.Request.UserEmail,
 			req.ExistingRecord.GoogleCalendarEvents,
 		)
@@ -221,9 +609,6 @@ func SyncOOORequest(
 		return nil, nil
 
 	default:
-		return nil, fmt.Errorf(
-			"unsupported Clockify request status %q",
-			req.Request.Status.StatusType,
-		)
+		return nil, nil
 	}
 }
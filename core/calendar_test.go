@@ -3,16 +3,18 @@ package core
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/calendar/v3"
 )
 
 func TestInsertOOOEvent_ReturnsErrorsForInvalidRequests(t *testing.T) {
 	ctx := context.Background()
-	jwtCfg := jwt.Config{}
-	calendarIDs := []string{"primary"}
+	backend := NewGoogleCalendarBackend(jwt.Config{})
+	targets := UserCalendars("primary")
 
 	reqs := []ClockifyRequest{
 		fixtureBadTimeZone(),
@@ -22,7 +24,7 @@ func TestInsertOOOEvent_ReturnsErrorsForInvalidRequests(t *testing.T) {
 
 	for _, req := range reqs {
 		t.Run(req.ID, func(t *testing.T) {
-			_, err := InsertOOOEvents(ctx, jwtCfg, req, calendarIDs)
+			_, err := InsertOOOEvents(ctx, backend, req, targets, SyncOptions{})
 
 			require.Error(t, err)
 		})
@@ -31,7 +33,7 @@ func TestInsertOOOEvent_ReturnsErrorsForInvalidRequests(t *testing.T) {
 
 func TestDeleteOOOEvents_ReturnsErrorsForFailedDeletes(t *testing.T) {
 	ctx := context.Background()
-	jwtCfg := jwt.Config{}
+	backend := NewGoogleCalendarBackend(jwt.Config{})
 	userEmail := "test@email.com"
 
 	events := []GoogleCalendarEvent{
@@ -45,7 +47,7 @@ func TestDeleteOOOEvents_ReturnsErrorsForFailedDeletes(t *testing.T) {
 		},
 	}
 
-	err := DeleteOOOEvents(ctx, jwtCfg, userEmail, events)
+	err := DeleteOOOEvents(ctx, backend, userEmail, events)
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "event-1")
@@ -53,3 +55,369 @@ func TestDeleteOOOEvents_ReturnsErrorsForFailedDeletes(t *testing.T) {
 	assert.Contains(t, err.Error(), "event-2")
 	assert.Contains(t, err.Error(), "calendar-2")
 }
+
+func TestPlanOOOEvent_Matches(t *testing.T) {
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-11T23:59:59Z")
+
+	ev, err := planOOOEvent(req, EventConfig{})
+	require.NoError(t, err)
+
+	assert.True(t, ev.AllDay)
+	assert.Equal(t, "2025-12-10", formatDate(ev.Start))
+	assert.Equal(t, "2025-12-12", formatDate(ev.End))
+
+	regular := ev.forTarget(req.UserEmail, CalendarTarget{CalendarID: "team@example.com"})
+	assert.True(t, regular.matches(fromGoogleEvent(&calendar.Event{
+		Start: &calendar.EventDateTime{Date: "2025-12-10"},
+		End:   &calendar.EventDateTime{Date: "2025-12-12"},
+	})))
+	assert.False(t, regular.matches(fromGoogleEvent(&calendar.Event{
+		Start: &calendar.EventDateTime{Date: "2025-12-09"},
+		End:   &calendar.EventDateTime{Date: "2025-12-11"},
+	})))
+
+	// On the primary calendar the same days are an out-of-office event
+	// between local midnights.
+	assert.True(t, ev.matches(fromGoogleEvent(&calendar.Event{
+		EventType: "outOfOffice",
+		Start:     &calendar.EventDateTime{DateTime: "2025-12-10T00:00:00Z"},
+		End:       &calendar.EventDateTime{DateTime: "2025-12-12T00:00:00Z"},
+	})))
+	assert.False(t, ev.matches(fromGoogleEvent(&calendar.Event{
+		Start: &calendar.EventDateTime{DateTime: "2025-12-10T00:00:00Z"},
+		End:   &calendar.EventDateTime{DateTime: "2025-12-12T00:00:00Z"},
+	})))
+}
+
+func TestGoogleCalendarBackend_InsertFindUpdateDeleteFlow(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "America/New_York", "2025-12-10T05:00:00Z", "2025-12-11T04:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	inserted, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: req}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, inserted, 1)
+
+	stored := fake.event("primary", inserted[0].EventID)
+	require.NotNil(t, stored)
+	assert.Equal(t, "OOO — Vacation", stored["summary"])
+	assert.Equal(t, "outOfOffice", stored["eventType"])
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-10T00:00:00-05:00",
+		"timeZone": "America/New_York",
+	}, stored["start"])
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-11T00:00:00-05:00",
+		"timeZone": "America/New_York",
+	}, stored["end"])
+
+	// Re-inserting finds the existing event instead of duplicating it.
+	found, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, found)
+	assert.Equal(t, 1, fake.count("primary"))
+
+	// Moving the request patches the recorded event in place.
+	existing := &SyncedClockifyRequest{ClockifyRequestID: req.ID, GoogleCalendarEvents: inserted}
+	moved := req
+	moved.TimeOffPeriod.Period.Start = "2025-12-15T05:00:00Z"
+	moved.TimeOffPeriod.Period.End = "2025-12-17T04:59:59Z"
+
+	updated, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: moved, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, updated)
+
+	stored = fake.event("primary", inserted[0].EventID)
+	assert.Equal(t, "2025-12-15T00:00:00-05:00", stored["start"].(map[string]any)["dateTime"])
+	assert.Equal(t, "2025-12-17T00:00:00-05:00", stored["end"].(map[string]any)["dateTime"])
+
+	// Withdrawing deletes it, and deleting again tolerates the 410.
+	withdrawn := moved
+	withdrawn.Status.StatusType = ClockifyStatusWithdrawn
+	existing.GoogleCalendarEvents = updated
+
+	remaining, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: withdrawn, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Equal(t, 0, fake.count("primary"))
+
+	require.NoError(t, DeleteOOOEvents(ctx, backend, req.UserEmail, updated))
+}
+
+func TestUpdateOOOEvents_RecreatesManuallyDeletedEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	stale := []GoogleCalendarEvent{{CalendarID: "primary", EventID: "deleted-by-hand"}}
+
+	events, err := UpdateOOOEvents(ctx, backend, req, stale, SyncOptions{})
+
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.NotEqual(t, "deleted-by-hand", events[0].EventID)
+	assert.NotNil(t, fake.event("primary", events[0].EventID))
+}
+
+func TestInsertOOOEvents_MovesStaleOverlappingEvent(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-11T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	inserted, err := InsertOOOEvents(ctx, backend, req, UserCalendars("team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+
+	// Shorten the request so the old event still overlaps the new window.
+	shortened := req
+	shortened.TimeOffPeriod.Period.End = "2025-12-10T23:59:59Z"
+
+	found, err := InsertOOOEvents(ctx, backend, shortened, UserCalendars("team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, found)
+
+	stored := fake.event("team@example.com", inserted[0].EventID)
+	assert.Equal(t, map[string]any{"date": "2025-12-11"}, stored["end"])
+}
+
+func TestSyncOOORequest_PendingPlaceholderIsConfirmedOnApproval(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	opts := SyncOptions{PendingPlaceholders: true}
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusPending
+
+	events, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: req}, UserCalendars("primary"), opts)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	stored := fake.event("primary", events[0].EventID)
+	assert.Equal(t, "tentative", stored["status"])
+	assert.Equal(t, "transparent", stored["transparency"])
+
+	approved := req
+	approved.Status.StatusType = ClockifyStatusApproved
+	existing := &SyncedClockifyRequest{ClockifyRequestID: req.ID, GoogleCalendarEvents: events}
+
+	// The placeholder is a regular event, which Google can't turn into an
+	// out-of-office one, so it is replaced.
+	confirmed, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: approved, ExistingRecord: existing}, UserCalendars("primary"), opts)
+	require.NoError(t, err)
+	require.Len(t, confirmed, 1)
+	assert.NotEqual(t, events[0].EventID, confirmed[0].EventID)
+	assert.Nil(t, fake.event("primary", events[0].EventID))
+	assert.Equal(t, 1, fake.count("primary"))
+
+	stored = fake.event("primary", confirmed[0].EventID)
+	assert.Equal(t, "outOfOffice", stored["eventType"])
+	assert.Equal(t, "confirmed", stored["status"])
+	assert.Equal(t, "opaque", stored["transparency"])
+	assert.Equal(t, "OOO — Vacation", stored["summary"])
+}
+
+func TestInsertOOOEvents_OutOfOfficeOnlyOnPrimaryCalendar(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	opts := SyncOptions{Events: EventConfig{OutOfOffice: OutOfOfficeConfig{
+		AutoDeclineMode: "declineAllConflictingInvitations",
+		DeclineMessage:  "I'm out of office.",
+		PolicyDeclineMessages: map[string]string{
+			"Vacation": "I'm on vacation.",
+		},
+	}}}
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary", "team@example.com"), opts)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	primary := fake.event("primary", events[0].EventID)
+	assert.Equal(t, "outOfOffice", primary["eventType"])
+	assert.Equal(t, map[string]any{
+		"autoDeclineMode": "declineAllConflictingInvitations",
+		"declineMessage":  "I'm on vacation.",
+	}, primary["outOfOfficeProperties"])
+
+	team := fake.event("team@example.com", events[1].EventID)
+	assert.Nil(t, team["eventType"])
+	assert.Nil(t, team["outOfOfficeProperties"])
+	assert.Equal(t, map[string]any{"date": "2025-12-10"}, team["start"])
+	assert.Equal(t, map[string]any{"date": "2025-12-11"}, team["end"])
+
+	// Other policies fall back to the default decline message.
+	req.ID = "request-456"
+	req.PolicyName = "Sick leave"
+
+	events, err = InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), opts)
+	require.NoError(t, err)
+	primary = fake.event("primary", events[0].EventID)
+	assert.Equal(t, "I'm out of office.", primary["outOfOfficeProperties"].(map[string]any)["declineMessage"])
+}
+
+func TestPlanOOOEvent_PartialDays(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		start     string
+		end       string
+		configure func(r *ClockifyRequest)
+		wantStart time.Time
+		wantEnd   time.Time
+		allDay    bool
+	}{
+		{
+			name:      "full day",
+			start:     "2025-12-10T05:00:00Z",
+			end:       "2025-12-11T04:59:59Z",
+			wantStart: time.Date(2025, 12, 10, 0, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 11, 0, 0, 0, 0, ny),
+			allDay:    true,
+		},
+		{
+			name:      "full day on spring forward",
+			start:     "2026-03-08T05:00:00Z",
+			end:       "2026-03-09T03:59:59Z",
+			wantStart: time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+			wantEnd:   time.Date(2026, 3, 9, 0, 0, 0, 0, ny),
+			allDay:    true,
+		},
+		{
+			name:      "full day on fall back",
+			start:     "2026-11-01T04:00:00Z",
+			end:       "2026-11-02T04:59:59Z",
+			wantStart: time.Date(2026, 11, 1, 0, 0, 0, 0, ny),
+			wantEnd:   time.Date(2026, 11, 2, 0, 0, 0, 0, ny),
+			allDay:    true,
+		},
+		{
+			name:      "afternoon to the end of the day",
+			start:     "2025-12-10T18:00:00Z",
+			end:       "2025-12-11T04:59:59Z",
+			wantStart: time.Date(2025, 12, 10, 13, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 11, 0, 0, 0, 0, ny),
+		},
+		{
+			name:      "two hour appointment",
+			start:     "2025-12-10T19:00:00Z",
+			end:       "2025-12-10T20:59:59Z",
+			wantStart: time.Date(2025, 12, 10, 14, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 10, 16, 0, 0, 0, ny),
+		},
+		{
+			name:  "hours policy spanning days",
+			start: "2025-12-10T14:00:00Z",
+			end:   "2025-12-11T22:00:00Z",
+			configure: func(r *ClockifyRequest) {
+				r.TimeUnit = ClockifyTimeUnitHours
+			},
+			wantStart: time.Date(2025, 12, 10, 9, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 11, 17, 0, 0, 0, ny),
+		},
+		{
+			name:  "days planned in another time zone",
+			start: "2025-12-10T08:00:00Z",
+			end:   "2025-12-12T07:59:59Z",
+			configure: func(r *ClockifyRequest) {
+				r.TimeUnit = ClockifyTimeUnitDays
+			},
+			wantStart: time.Date(2025, 12, 10, 0, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 13, 0, 0, 0, 0, ny),
+			allDay:    true,
+		},
+		{
+			name:  "one day planned in another time zone",
+			start: "2025-12-10T08:00:00Z",
+			end:   "2025-12-11T07:59:59Z",
+			configure: func(r *ClockifyRequest) {
+				r.TimeUnit = ClockifyTimeUnitDays
+			},
+			wantStart: time.Date(2025, 12, 10, 0, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 12, 0, 0, 0, 0, ny),
+			allDay:    true,
+		},
+		{
+			name:  "half day with hours",
+			start: "2025-12-10T05:00:00Z",
+			end:   "2025-12-11T04:59:59Z",
+			configure: func(r *ClockifyRequest) {
+				r.TimeOffPeriod.HalfDay = true
+				r.TimeOffPeriod.HalfDayHours.Start = "2025-12-10T18:00:00Z"
+				r.TimeOffPeriod.HalfDayHours.End = "2025-12-10T22:00:00Z"
+			},
+			wantStart: time.Date(2025, 12, 10, 13, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 10, 17, 0, 0, 0, ny),
+		},
+		{
+			name:  "second half without hours",
+			start: "2025-12-10T05:00:00Z",
+			end:   "2025-12-11T04:59:59Z",
+			configure: func(r *ClockifyRequest) {
+				r.TimeOffPeriod.HalfDay = true
+				r.TimeOffPeriod.HalfDayPeriod = ClockifyHalfDaySecondHalf
+			},
+			wantStart: time.Date(2025, 12, 10, 12, 0, 0, 0, ny),
+			wantEnd:   time.Date(2025, 12, 11, 0, 0, 0, 0, ny),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeRequest("request-123", "America/New_York", tt.start, tt.end)
+			if tt.configure != nil {
+				tt.configure(&req)
+			}
+
+			ev, err := planOOOEvent(req, EventConfig{})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.allDay, ev.AllDay)
+			assert.True(t, tt.wantStart.Equal(ev.Start), "start %s", ev.Start)
+			assert.True(t, tt.wantEnd.Equal(ev.End), "end %s", ev.End)
+			assert.Equal(t, ny, ev.Start.Location())
+		})
+	}
+}
+
+func TestInsertOOOEvents_WritesPartialDaysAsTimedEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "America/New_York", "2025-12-10T19:00:00Z", "2025-12-10T20:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	stored := fake.event("team@example.com", events[0].EventID)
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-10T14:00:00-05:00",
+		"timeZone": "America/New_York",
+	}, stored["start"])
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-10T16:00:00-05:00",
+		"timeZone": "America/New_York",
+	}, stored["end"])
+
+	// The timed event is found again rather than duplicated.
+	found, err := InsertOOOEvents(ctx, backend, req, UserCalendars("team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, events, found)
+	assert.Equal(t, 1, fake.count("team@example.com"))
+}
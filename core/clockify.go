@@ -2,15 +2,20 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type ClockifyEnvelope struct {
+	Count    int               `json:"count"`
 	Requests []ClockifyRequest `json:"requests"`
 }
 
@@ -19,14 +24,27 @@ type ClockifyRequest struct {
 	CreatedAt  string `json:"createdAt"`
 	PolicyName string `json:"policyName"`
 
+	UserID       string `json:"userId"`
 	UserEmail    string `json:"userEmail"`
 	UserTimeZone string `json:"userTimeZone"`
 
+	// TimeUnit is DAYS or HOURS, depending on how the policy is tracked.
+	TimeUnit string `json:"timeUnit"`
+
 	TimeOffPeriod struct {
 		Period struct {
 			Start string `json:"start"`
 			End   string `json:"end"`
 		} `json:"period"`
+
+		// HalfDay requests cover HalfDayHours when Clockify reports them,
+		// otherwise the half of the day named by HalfDayPeriod.
+		HalfDay       bool   `json:"halfDay"`
+		HalfDayPeriod string `json:"halfDayPeriod"`
+		HalfDayHours  struct {
+			Start string `json:"start"`
+			End   string `json:"end"`
+		} `json:"halfDayHours"`
 	} `json:"timeOffPeriod"`
 
 	Status struct {
@@ -36,10 +54,30 @@ type ClockifyRequest struct {
 }
 
 const (
-	ClockifyStatusApproved = "APPROVED"
-	ClockifyStatusRejected = "REJECTED"
+	ClockifyStatusApproved  = "APPROVED"
+	ClockifyStatusRejected  = "REJECTED"
+	ClockifyStatusPending   = "PENDING"
+	ClockifyStatusWithdrawn = "WITHDRAWN"
+	ClockifyStatusCancelled = "CANCELLED"
 )
 
+const (
+	ClockifyTimeUnitDays  = "DAYS"
+	ClockifyTimeUnitHours = "HOURS"
+
+	ClockifyHalfDayFirstHalf  = "FIRST_HALF"
+	ClockifyHalfDaySecondHalf = "SECOND_HALF"
+)
+
+// ClockifyStatuses lists every request status the sync knows how to handle.
+var ClockifyStatuses = []string{
+	ClockifyStatusApproved,
+	ClockifyStatusRejected,
+	ClockifyStatusPending,
+	ClockifyStatusWithdrawn,
+	ClockifyStatusCancelled,
+}
+
 type ClockifyRequestPayload struct {
 	Start    *string  `json:"start,omitempty"`
 	End      *string  `json:"end,omitempty"`
@@ -51,17 +89,84 @@ type ClockifyRequestPayload struct {
 
 const defaultClockifyBaseURL = "https://api.clockify.me/api/v1"
 
+const (
+	defaultClockifyPageSize = 50
+
+	// maxClockifyPages is a safety net against an API that never reports a
+	// short page or a count we can reach.
+	maxClockifyPages = 200
+
+	defaultClockifyMaxAttempts = 4
+	defaultClockifyBaseBackoff = 500 * time.Millisecond
+	defaultClockifyMaxBackoff  = 30 * time.Second
+
+	// defaultClockifyMaxRetryAfter is the longest Retry-After the client
+	// waits out before giving up.
+	defaultClockifyMaxRetryAfter = 5 * time.Minute
+)
+
+// Sentinel errors that a *ClockifyAPIError unwraps to, so callers can branch
+// with errors.Is without inspecting status codes.
+var (
+	ErrClockifyUnauthorized = errors.New("clockify: authentication failed")
+	ErrClockifyNotFound     = errors.New("clockify: not found")
+	ErrClockifyRateLimited  = errors.New("clockify: rate limited")
+	ErrClockifyServerError  = errors.New("clockify: server error")
+)
+
+// ClockifyAPIError is returned for any non-2xx response from Clockify.
+type ClockifyAPIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter is the delay requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *ClockifyAPIError) Error() string {
+	return fmt.Sprintf("non-2xx status: %s\n%s", e.Status, e.Body)
+}
+
+func (e *ClockifyAPIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrClockifyUnauthorized
+	case e.StatusCode == http.StatusNotFound:
+		return ErrClockifyNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrClockifyRateLimited
+	case e.StatusCode >= 500:
+		return ErrClockifyServerError
+	default:
+		return nil
+	}
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *ClockifyAPIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
 type ClockifyClient struct {
 	baseURL string
 	apiKey  string
 	http    *http.Client
+
+	maxAttempts   int
+	baseBackoff   time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
 }
 
 func NewClockifyClient(apiKey string, opts ...func(*ClockifyClient)) *ClockifyClient {
 	client := &ClockifyClient{
-		baseURL: defaultClockifyBaseURL,
-		apiKey:  apiKey,
-		http:    &http.Client{Timeout: 30 * time.Second},
+		baseURL:       defaultClockifyBaseURL,
+		apiKey:        apiKey,
+		http:          &http.Client{Timeout: 30 * time.Second},
+		maxAttempts:   defaultClockifyMaxAttempts,
+		baseBackoff:   defaultClockifyBaseBackoff,
+		maxBackoff:    defaultClockifyMaxBackoff,
+		maxRetryAfter: defaultClockifyMaxRetryAfter,
 	}
 	for _, opt := range opts {
 		opt(client)
@@ -82,7 +187,144 @@ func WithHTTPClient(h *http.Client) func(*ClockifyClient) {
 	}
 }
 
+// WithClockifyRetry configures how many times a request is attempted and the
+// jittered exponential backoff between attempts. A Retry-After header from
+// Clockify replaces the computed backoff; see WithClockifyMaxRetryAfter.
+func WithClockifyRetry(maxAttempts int, baseBackoff, maxBackoff time.Duration) func(*ClockifyClient) {
+	return func(c *ClockifyClient) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.baseBackoff = baseBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithClockifyMaxRetryAfter sets the longest Retry-After the client waits
+// out. Longer ones, or ones past the context's deadline, fail the request
+// with the rate limit error rather than retrying before Clockify allows.
+func WithClockifyMaxRetryAfter(d time.Duration) func(*ClockifyClient) {
+	return func(c *ClockifyClient) {
+		c.maxRetryAfter = d
+	}
+}
+
+// ListTimeOffRequests fetches a single page of time-off requests. Requests
+// that cannot be decoded are logged and skipped.
+func (c *ClockifyClient) ListTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) (ClockifyEnvelope, error) {
+	respBytes, err := c.postTimeOffRequests(ctx, workspaceID, payload)
+	if err != nil {
+		return ClockifyEnvelope{}, err
+	}
+
+	rawEnv, err := ParseRawClockifyEnvelope(respBytes)
+	if err != nil {
+		return ClockifyEnvelope{}, fmt.Errorf("parse response: %w", err)
+	}
+
+	return rawEnv.decode(), nil
+}
+
+// ListAllTimeOffRequests is ListTimeOffRequests across every page.
+func (c *ClockifyClient) ListAllTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) (ClockifyEnvelope, error) {
+	rawEnv, err := c.fetchAllTimeOffRequests(ctx, workspaceID, payload)
+	if err != nil {
+		return ClockifyEnvelope{}, err
+	}
+
+	return rawEnv.decode(), nil
+}
+
 func FetchClockifyRequests(c *ClockifyClient, workspaceID string, payload ClockifyRequestPayload) ([]byte, error) {
+	return c.postTimeOffRequests(context.Background(), workspaceID, payload)
+}
+
+// FetchAllClockifyRequests walks the paginated time-off requests endpoint,
+// starting at page 1, until the count reported by Clockify is exhausted or a
+// short page comes back, and returns the merged set of requests. Requests that
+// shift between pages while walking are only returned once, and requests that
+// cannot be decoded are logged and skipped.
+func FetchAllClockifyRequests(c *ClockifyClient, workspaceID string, payload ClockifyRequestPayload) ([]ClockifyRequest, error) {
+	env, err := c.ListAllTimeOffRequests(context.Background(), workspaceID, payload)
+	if err != nil {
+		return nil, err
+	}
+	return env.Requests, nil
+}
+
+func (c *ClockifyClient) fetchAllTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) (rawClockifyEnvelope, error) {
+	if payload.PageSize <= 0 {
+		payload.PageSize = defaultClockifyPageSize
+	}
+
+	var merged rawClockifyEnvelope
+	seen := make(map[string]bool)
+
+	for page := 1; ; page++ {
+		if page > maxClockifyPages {
+			return rawClockifyEnvelope{}, fmt.Errorf(
+				"clockify pagination exceeded %d pages of %d requests",
+				maxClockifyPages,
+				payload.PageSize,
+			)
+		}
+
+		payload.Page = page
+
+		respBytes, err := c.postTimeOffRequests(ctx, workspaceID, payload)
+		if err != nil {
+			return rawClockifyEnvelope{}, fmt.Errorf("page %d: %w", page, err)
+		}
+
+		env, err := ParseRawClockifyEnvelope(respBytes)
+		if err != nil {
+			return rawClockifyEnvelope{}, fmt.Errorf("page %d: parse response: %w", page, err)
+		}
+
+		merged.Count = env.Count
+		for _, raw := range env.Requests {
+			var ref struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(raw, &ref); err == nil && ref.ID != "" {
+				if seen[ref.ID] {
+					continue
+				}
+				seen[ref.ID] = true
+			}
+
+			merged.Requests = append(merged.Requests, raw)
+		}
+
+		if len(env.Requests) < payload.PageSize {
+			break
+		}
+		if env.Count > 0 && page*payload.PageSize >= env.Count {
+			break
+		}
+	}
+
+	return merged, nil
+}
+
+// postTimeOffRequests sends one time-off requests query, retrying transport
+// errors, 429s and 5xx responses with exponential backoff, or after the
+// Retry-After Clockify asks for.
+func (c *ClockifyClient) postTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) ([]byte, error) {
 	url := fmt.Sprintf("%s/workspaces/%s/time-off/requests", c.baseURL, workspaceID)
 
 	body, err := json.Marshal(payload)
@@ -90,7 +332,55 @@ func FetchClockifyRequests(c *ClockifyClient, workspaceID string, payload Clocki
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	for attempt := 1; ; attempt++ {
+		respBytes, err := c.doOnce(ctx, http.MethodPost, url, body)
+		if err == nil {
+			return respBytes, nil
+		}
+
+		delay := c.backoff(attempt)
+
+		var apiErr *ClockifyAPIError
+		if errors.As(err, &apiErr) {
+			if !apiErr.retryable() {
+				return nil, err
+			}
+			if apiErr.RetryAfter > 0 {
+				delay = apiErr.RetryAfter
+			}
+		}
+
+		if attempt >= c.maxAttempts || ctx.Err() != nil {
+			return nil, err
+		}
+
+		// Retrying before Clockify allows would only burn attempts, so a
+		// Retry-After we can't wait out ends the request now.
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			deadline, hasDeadline := ctx.Deadline()
+			if delay > c.maxRetryAfter || (hasDeadline && time.Until(deadline) < delay) {
+				return nil, err
+			}
+		}
+
+		log.Printf(
+			"Clockify request failed (attempt %d/%d), retrying in %s: %v",
+			attempt,
+			c.maxAttempts,
+			delay,
+			err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *ClockifyClient) doOnce(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("new request: %w", err)
 	}
@@ -113,8 +403,43 @@ func FetchClockifyRequests(c *ClockifyClient, workspaceID string, payload Clocki
 		return nil, fmt.Errorf("read body: %w", err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("non-2xx status: %s\n%s", resp.Status, string(respBytes))
+		return nil, &ClockifyAPIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(respBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	return respBytes, nil
 }
+
+// backoff returns the exponential delay before retrying after attempt, with
+// jitter over its upper half so that clients failing together don't retry
+// together.
+func (c *ClockifyClient) backoff(attempt int) time.Duration {
+	delay := c.baseBackoff
+	for i := 1; i < attempt && delay < c.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.maxBackoff)
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: a number
+// of seconds or an HTTP date. It returns 0 if the header is absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPagedClockifyServer serves total requests in pages of the requested
+// size, reporting total as the envelope count.
+func newPagedClockifyServer(t *testing.T, total int, pagesServed *int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload ClockifyRequestPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		*pagesServed++
+
+		requests := []map[string]string{}
+		for i := (payload.Page - 1) * payload.PageSize; i < total && i < payload.Page*payload.PageSize; i++ {
+			requests = append(requests, map[string]string{"id": fmt.Sprintf("req-%d", i)})
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+			"count":    total,
+			"requests": requests,
+		}))
+	}))
+}
+
+func TestFetchAllClockifyRequests_WalksAllPages(t *testing.T) {
+	pagesServed := 0
+	srv := newPagedClockifyServer(t, 5, &pagesServed)
+	defer srv.Close()
+
+	client := NewClockifyClient("key", WithClockifyBaseURL(srv.URL))
+
+	requests, err := FetchAllClockifyRequests(client, "ws", ClockifyRequestPayload{PageSize: 2})
+
+	require.NoError(t, err)
+	assert.Len(t, requests, 5)
+	assert.Equal(t, "req-4", requests[4].ID)
+	assert.Equal(t, 3, pagesServed)
+}
+
+func TestFetchAllClockifyRequests_StopsAtCountOnFullLastPage(t *testing.T) {
+	pagesServed := 0
+	srv := newPagedClockifyServer(t, 4, &pagesServed)
+	defer srv.Close()
+
+	client := NewClockifyClient("key", WithClockifyBaseURL(srv.URL))
+
+	requests, err := FetchAllClockifyRequests(client, "ws", ClockifyRequestPayload{PageSize: 2})
+
+	require.NoError(t, err)
+	assert.Len(t, requests, 4)
+	assert.Equal(t, 2, pagesServed)
+}
+
+func TestFetchAllClockifyRequests_DeduplicatesShiftedRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload ClockifyRequestPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		// A request created mid-walk pushes req-b onto the second page too.
+		pages := map[int]string{
+			1: `{"count": 3, "requests": [{"id": "req-a"}, {"id": "req-b"}]}`,
+			2: `{"count": 3, "requests": [{"id": "req-b"}]}`,
+		}
+		_, _ = w.Write([]byte(pages[payload.Page]))
+	}))
+	defer srv.Close()
+
+	client := NewClockifyClient("key", WithClockifyBaseURL(srv.URL))
+
+	requests, err := FetchAllClockifyRequests(client, "ws", ClockifyRequestPayload{PageSize: 2})
+
+	require.NoError(t, err)
+	assert.Len(t, requests, 2)
+}
+
+func TestFetchAllClockifyRequests_ReturnsErrorPastPageCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload ClockifyRequestPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		// Always a full page with no usable count.
+		_, _ = fmt.Fprintf(w, `{"count": 0, "requests": [{"id": "req-%d"}]}`, payload.Page)
+	}))
+	defer srv.Close()
+
+	client := NewClockifyClient("key", WithClockifyBaseURL(srv.URL))
+
+	_, err := FetchAllClockifyRequests(client, "ws", ClockifyRequestPayload{PageSize: 1})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded")
+}
+
+func newTestClockifyClient(url string) *ClockifyClient {
+	return NewClockifyClient(
+		"key",
+		WithClockifyBaseURL(url),
+		WithClockifyRetry(3, time.Millisecond, 5*time.Millisecond),
+	)
+}
+
+func TestListTimeOffRequests_RetriesServerErrors(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"count": 1, "requests": [{"id": "req-1", "status": {"statusType": "APPROVED"}}]}`))
+	}))
+	defer srv.Close()
+
+	env, err := newTestClockifyClient(srv.URL).ListTimeOffRequests(context.Background(), "ws", ClockifyRequestPayload{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	require.Len(t, env.Requests, 1)
+	assert.Equal(t, "req-1", env.Requests[0].ID)
+	assert.Equal(t, ClockifyStatusApproved, env.Requests[0].Status.StatusType)
+}
+
+func TestListTimeOffRequests_HonorsRetryAfter(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"count": 0, "requests": []}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestClockifyClient(srv.URL).ListTimeOffRequests(context.Background(), "ws", ClockifyRequestPayload{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestListTimeOffRequests_WaitsOutRetryAfterPastMaxBackoff(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"count": 0, "requests": []}`))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := newTestClockifyClient(srv.URL).ListTimeOffRequests(context.Background(), "ws", ClockifyRequestPayload{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestListTimeOffRequests_GivesUpOnRetryAfterItCannotWaitOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []func(*ClockifyClient)
+		timeout time.Duration
+	}{
+		{"past max retry after", []func(*ClockifyClient){WithClockifyMaxRetryAfter(30 * time.Second)}, time.Hour},
+		{"past context deadline", nil, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			opts := append([]func(*ClockifyClient){
+				WithClockifyBaseURL(srv.URL),
+				WithClockifyRetry(3, time.Millisecond, 5*time.Millisecond),
+			}, tt.opts...)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			_, err := NewClockifyClient("key", opts...).ListTimeOffRequests(ctx, "ws", ClockifyRequestPayload{})
+
+			require.ErrorIs(t, err, ErrClockifyRateLimited)
+			assert.Equal(t, 1, attempts)
+		})
+	}
+}
+
+func TestClockifyClient_BackoffIsJitteredAndCapped(t *testing.T) {
+	client := NewClockifyClient("key", WithClockifyRetry(5, time.Second, 4*time.Second))
+
+	for range 20 {
+		assert.GreaterOrEqual(t, client.backoff(1), 500*time.Millisecond)
+		assert.LessOrEqual(t, client.backoff(1), time.Second)
+		assert.GreaterOrEqual(t, client.backoff(5), 2*time.Second)
+		assert.LessOrEqual(t, client.backoff(5), 4*time.Second)
+	}
+}
+
+func TestListTimeOffRequests_ReturnsTypedErrors(t *testing.T) {
+	tests := []struct {
+		status       int
+		want         error
+		wantAttempts int
+	}{
+		{http.StatusUnauthorized, ErrClockifyUnauthorized, 1},
+		{http.StatusForbidden, ErrClockifyUnauthorized, 1},
+		{http.StatusNotFound, ErrClockifyNotFound, 1},
+		{http.StatusTooManyRequests, ErrClockifyRateLimited, 3},
+		{http.StatusInternalServerError, ErrClockifyServerError, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			_, err := newTestClockifyClient(srv.URL).ListTimeOffRequests(context.Background(), "ws", ClockifyRequestPayload{})
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tt.want), "got %v", err)
+
+			var apiErr *ClockifyAPIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestListTimeOffRequests_StopsRetryingWhenContextCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewClockifyClient(
+		"key",
+		WithClockifyBaseURL(srv.URL),
+		WithClockifyRetry(5, time.Hour, time.Hour),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.ListTimeOffRequests(ctx, "ws", ClockifyRequestPayload{})
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 7*time.Second, parseRetryAfter("7", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-3", now))
+}
@@ -3,12 +3,33 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Watermarks and leases share the table with the synced requests, under keys
+// that can't collide with Clockify's request IDs.
+const (
+	dynamoInternalKeyPrefix  = "#"
+	dynamoWatermarkKeyPrefix = "#watermark#"
+	dynamoLeaseKeyPrefix     = "#lease#"
+)
+
+// The global secondary indexes the store queries, projecting all attributes.
+// dynamoPeriodEndIndex is keyed on Status with PeriodEnd as the sort key.
+// dynamoRetryIndex is keyed on RetryState, which only failed and
+// dead-lettered records have, so that it holds just them.
+const (
+	dynamoPeriodEndIndex = "Status-PeriodEnd-index"
+	dynamoRetryIndex     = "RetryState-index"
+)
+
 type DynamoStore struct {
 	Client    *dynamodb.Client
 	TableName string
@@ -26,17 +47,48 @@ func (s *DynamoStore) PutSyncedRequest(ctx context.Context, item *SyncedClockify
 		return errors.New("missing Clockify request ID")
 	}
 
-	av, err := attributevalue.MarshalMap(item)
+	next := *item
+	next.Version++
+
+	av, err := attributevalue.MarshalMap(&next)
 	if err != nil {
 		return err
 	}
+	if next.SyncState == SyncStateFailed || next.SyncState == SyncStateDeadLetter {
+		av["RetryState"] = &types.AttributeValueMemberS{Value: next.SyncState}
+	}
+
+	// Records from before versioning have no Version and are replaced as if
+	// at version zero.
+	condition := "attribute_not_exists(Version)"
+	values := map[string]types.AttributeValue(nil)
+	if item.Version > 0 {
+		condition = "Version = :version"
+		values = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(item.Version, 10)},
+		}
+	}
 
 	_, err = s.Client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: &s.TableName,
-		Item:      av,
+		TableName:                 &s.TableName,
+		Item:                      av,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
 	})
 
-	return err
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return &ConflictError{
+			ClockifyRequestID: item.ClockifyRequestID,
+			Reason:            fmt.Sprintf("record is no longer at version %d", item.Version),
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	item.Version = next.Version
+	return nil
 }
 
 func (s *DynamoStore) DeleteSyncedRequest(
@@ -92,3 +144,216 @@ func (s *DynamoStore) GetSyncedRequest(
 
 	return &item, nil
 }
+
+func (s *DynamoStore) ListSyncedRequests(ctx context.Context) ([]*SyncedClockifyRequest, error) {
+	paginator := dynamodb.NewScanPaginator(s.Client, &dynamodb.ScanInput{
+		TableName:        &s.TableName,
+		FilterExpression: aws.String("NOT begins_with(ClockifyRequestId, :internal)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":internal": &types.AttributeValueMemberS{Value: dynamoInternalKeyPrefix},
+		},
+	})
+
+	var items []*SyncedClockifyRequest
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err = appendDynamoItems(items, page.Items)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// ListSyncedRequestsEndingAfter queries dynamoPeriodEndIndex. Period ends are
+// compared as strings, which orders the UTC timestamps Clockify returns.
+func (s *DynamoStore) ListSyncedRequestsEndingAfter(
+	ctx context.Context,
+	status string,
+	after time.Time,
+) ([]*SyncedClockifyRequest, error) {
+	return s.query(ctx, &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		IndexName:              aws.String(dynamoPeriodEndIndex),
+		KeyConditionExpression: aws.String("#status = :status AND PeriodEnd > :after"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: status},
+			":after":  &types.AttributeValueMemberS{Value: after.UTC().Format(time.RFC3339)},
+		},
+	})
+}
+
+// ListDueRetries queries dynamoRetryIndex. Retry times are stored in UTC, so
+// they compare as strings.
+func (s *DynamoStore) ListDueRetries(ctx context.Context, now time.Time) ([]*SyncedClockifyRequest, error) {
+	return s.query(ctx, &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		IndexName:              aws.String(dynamoRetryIndex),
+		KeyConditionExpression: aws.String("RetryState = :state"),
+		FilterExpression:       aws.String("attribute_not_exists(NextRetryAt) OR NextRetryAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":state": &types.AttributeValueMemberS{Value: SyncStateFailed},
+			":now":   &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+	})
+}
+
+// ListDeadLetters queries dynamoRetryIndex.
+func (s *DynamoStore) ListDeadLetters(ctx context.Context) ([]*SyncedClockifyRequest, error) {
+	return s.query(ctx, &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		IndexName:              aws.String(dynamoRetryIndex),
+		KeyConditionExpression: aws.String("RetryState = :state"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":state": &types.AttributeValueMemberS{Value: SyncStateDeadLetter},
+		},
+	})
+}
+
+func (s *DynamoStore) query(ctx context.Context, input *dynamodb.QueryInput) ([]*SyncedClockifyRequest, error) {
+	paginator := dynamodb.NewQueryPaginator(s.Client, input)
+
+	var items []*SyncedClockifyRequest
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err = appendDynamoItems(items, page.Items)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func appendDynamoItems(
+	items []*SyncedClockifyRequest,
+	page []map[string]types.AttributeValue,
+) ([]*SyncedClockifyRequest, error) {
+	for _, av := range page {
+		var item SyncedClockifyRequest
+		if err := attributevalue.UnmarshalMap(av, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+func (s *DynamoStore) GetWatermark(ctx context.Context, name string) (time.Time, error) {
+	if name == "" {
+		return time.Time{}, errors.New("missing watermark name")
+	}
+
+	response, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &s.TableName,
+		Key: map[string]types.AttributeValue{
+			"ClockifyRequestId": &types.AttributeValueMemberS{
+				Value: dynamoWatermarkKeyPrefix + name,
+			},
+		},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	value, ok := response.Item["Watermark"].(*types.AttributeValueMemberS)
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("watermark %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func (s *DynamoStore) PutWatermark(ctx context.Context, name string, t time.Time) error {
+	if name == "" {
+		return errors.New("missing watermark name")
+	}
+
+	_, err := s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &s.TableName,
+		Item: map[string]types.AttributeValue{
+			"ClockifyRequestId": &types.AttributeValueMemberS{
+				Value: dynamoWatermarkKeyPrefix + name,
+			},
+			"Watermark": &types.AttributeValueMemberS{
+				Value: t.UTC().Format(time.RFC3339Nano),
+			},
+		},
+	})
+
+	return err
+}
+
+func (s *DynamoStore) ClaimSyncedRequest(ctx context.Context, clockifyRequestID, owner string, now, until time.Time) error {
+	if clockifyRequestID == "" || owner == "" {
+		return errors.New("missing Clockify request ID or lease owner")
+	}
+
+	_, err := s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &s.TableName,
+		Item: map[string]types.AttributeValue{
+			"ClockifyRequestId": &types.AttributeValueMemberS{
+				Value: dynamoLeaseKeyPrefix + clockifyRequestID,
+			},
+			"LeaseOwner": &types.AttributeValueMemberS{Value: owner},
+			"LeaseUntil": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(until.UnixMilli(), 10),
+			},
+		},
+		ConditionExpression: aws.String(
+			"attribute_not_exists(ClockifyRequestId) OR LeaseOwner = :owner OR LeaseUntil <= :now",
+		),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: owner},
+			":now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+		},
+	})
+
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return &ConflictError{
+			ClockifyRequestID: clockifyRequestID,
+			Reason:            "another run is processing it",
+		}
+	}
+	return err
+}
+
+func (s *DynamoStore) ReleaseSyncedRequest(ctx context.Context, clockifyRequestID, owner string) error {
+	_, err := s.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &s.TableName,
+		Key: map[string]types.AttributeValue{
+			"ClockifyRequestId": &types.AttributeValueMemberS{
+				Value: dynamoLeaseKeyPrefix + clockifyRequestID,
+			},
+		},
+		ConditionExpression: aws.String("LeaseOwner = :owner"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: owner},
+		},
+	})
+
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// EventConfig controls how OOO events are written to calendars. It is
+// loaded from JSON, e.g.
+//
+//	{
+//	  "template": {
+//	    "summary": "{{.PolicyName}}",
+//	    "description": "Clockify request {{.ID}}"
+//	  },
+//	  "policyTemplates": {
+//	    "Sick leave": {"summary": "Out sick", "visibility": "private"}
+//	  },
+//	  "outOfOffice": {
+//	    "autoDeclineMode": "declineOnlyNewConflictingInvitations",
+//	    "declineMessage": "I'm out of office and will reply when I'm back.",
+//	    "policyDeclineMessages": {"Parental leave": "I'm on parental leave."}
+//	  }
+//	}
+type EventConfig struct {
+	// Template is used for every request. PolicyTemplates override its
+	// non-empty fields for requests under the named Clockify policy.
+	Template        EventTemplate            `json:"template"`
+	PolicyTemplates map[string]EventTemplate `json:"policyTemplates"`
+
+	OutOfOffice OutOfOfficeConfig `json:"outOfOffice"`
+}
+
+// EventTemplate holds text/template sources executed against the
+// ClockifyRequest an event is written for, e.g. "{{.PolicyName}}" or
+// "{{.UserEmail}}". Empty fields fall back to the defaults.
+type EventTemplate struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	// ColorID is one of the calendar's event color IDs, "1" to "11" on Google.
+	ColorID string `json:"colorId"`
+	// Visibility is default, public, private or confidential.
+	Visibility string `json:"visibility"`
+	// Transparency is opaque (busy, the default) or transparent (free).
+	// Pending placeholders are always transparent.
+	Transparency string `json:"transparency"`
+}
+
+var defaultEventTemplate = EventTemplate{
+	Summary:     "OOO{{with .PolicyName}} — {{.}}{{end}}",
+	Description: "Clockify request: {{.ID}}\nCreatedAt: {{.CreatedAt}}",
+}
+
+var (
+	validVisibilities   = map[string]bool{"default": true, "public": true, "private": true, "confidential": true}
+	validTransparencies = map[string]bool{"opaque": true, "transparent": true}
+)
+
+// EventDetails are the rendered fields of an EventTemplate.
+type EventDetails struct {
+	Summary     string
+	Description string
+	ColorID     string
+	Visibility  string
+	Transparent bool
+}
+
+// templateFor merges the templates that apply to policyName over the
+// defaults.
+func (c EventConfig) templateFor(policyName string) EventTemplate {
+	t := defaultEventTemplate
+	t.merge(c.Template)
+	if override, ok := c.PolicyTemplates[policyName]; ok {
+		t.merge(override)
+	}
+	return t
+}
+
+func (t *EventTemplate) merge(override EventTemplate) {
+	for _, f := range []struct{ dst, src *string }{
+		{&t.Summary, &override.Summary},
+		{&t.Description, &override.Description},
+		{&t.ColorID, &override.ColorID},
+		{&t.Visibility, &override.Visibility},
+		{&t.Transparency, &override.Transparency},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+}
+
+// render executes every field of t against r.
+func (t EventTemplate) render(r ClockifyRequest) (EventDetails, error) {
+	var rendered [5]string
+	for i, f := range []struct{ name, src string }{
+		{"summary", t.Summary},
+		{"description", t.Description},
+		{"colorId", t.ColorID},
+		{"visibility", t.Visibility},
+		{"transparency", t.Transparency},
+	} {
+		tmpl, err := template.New(f.name).Option("missingkey=error").Parse(f.src)
+		if err != nil {
+			return EventDetails{}, fmt.Errorf("%s template: %w", f.name, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, r); err != nil {
+			return EventDetails{}, fmt.Errorf("%s template: %w", f.name, err)
+		}
+		rendered[i] = strings.TrimSpace(b.String())
+	}
+
+	details := EventDetails{
+		Summary:     rendered[0],
+		Description: rendered[1],
+		ColorID:     rendered[2],
+		Visibility:  rendered[3],
+		Transparent: rendered[4] == "transparent",
+	}
+
+	if details.Visibility != "" && !validVisibilities[details.Visibility] {
+		return EventDetails{}, fmt.Errorf("visibility template: invalid visibility %q", details.Visibility)
+	}
+	if rendered[4] != "" && !validTransparencies[rendered[4]] {
+		return EventDetails{}, fmt.Errorf("transparency template: invalid transparency %q", rendered[4])
+	}
+
+	return details, nil
+}
+
+// OutOfOfficeConfig configures the native out-of-office events written to a
+// user's primary calendar.
+type OutOfOfficeConfig struct {
+	// AutoDeclineMode is one of Google's autoDeclineMode values:
+	// declineNone, declineAllConflictingInvitations or
+	// declineOnlyNewConflictingInvitations (the default).
+	AutoDeclineMode string `json:"autoDeclineMode"`
+	DeclineMessage  string `json:"declineMessage"`
+	// PolicyDeclineMessages overrides DeclineMessage per Clockify policy name.
+	PolicyDeclineMessages map[string]string `json:"policyDeclineMessages"`
+}
+
+const defaultAutoDeclineMode = "declineOnlyNewConflictingInvitations"
+
+var validAutoDeclineModes = map[string]bool{
+	"declineNone":                          true,
+	"declineAllConflictingInvitations":     true,
+	"declineOnlyNewConflictingInvitations": true,
+}
+
+// settingsFor returns the out-of-office settings for a request under the
+// given Clockify policy.
+func (c OutOfOfficeConfig) settingsFor(policyName string) *OutOfOfficeSettings {
+	settings := &OutOfOfficeSettings{
+		AutoDeclineMode: c.AutoDeclineMode,
+		DeclineMessage:  c.DeclineMessage,
+	}
+	if settings.AutoDeclineMode == "" {
+		settings.AutoDeclineMode = defaultAutoDeclineMode
+	}
+	if msg, ok := c.PolicyDeclineMessages[policyName]; ok {
+		settings.DeclineMessage = msg
+	}
+	return settings
+}
+
+// LoadEventConfig reads an EventConfig from a JSON file.
+func LoadEventConfig(path string) (EventConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return EventConfig{}, fmt.Errorf("read event config: %w", err)
+	}
+	return ParseEventConfig(b)
+}
+
+// ParseEventConfig decodes and validates an EventConfig. Unknown fields are
+// rejected so that typos don't silently fall back to defaults.
+func ParseEventConfig(b []byte) (EventConfig, error) {
+	var cfg EventConfig
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return EventConfig{}, fmt.Errorf("parse event config: %w", err)
+	}
+
+	if mode := cfg.OutOfOffice.AutoDeclineMode; mode != "" && !validAutoDeclineModes[mode] {
+		return EventConfig{}, fmt.Errorf("parse event config: invalid autoDeclineMode %q", mode)
+	}
+
+	// Catch template mistakes now rather than on the first matching request.
+	if _, err := cfg.templateFor("").render(ClockifyRequest{}); err != nil {
+		return EventConfig{}, fmt.Errorf("parse event config: %w", err)
+	}
+	for policy := range cfg.PolicyTemplates {
+		if _, err := cfg.templateFor(policy).render(ClockifyRequest{}); err != nil {
+			return EventConfig{}, fmt.Errorf("parse event config: policy %q: %w", policy, err)
+		}
+	}
+
+	return cfg, nil
+}
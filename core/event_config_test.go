@@ -0,0 +1,101 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventConfig(t *testing.T) {
+	cfg, err := ParseEventConfig([]byte(`{
+		"outOfOffice": {
+			"autoDeclineMode": "declineNone",
+			"declineMessage": "Out of office",
+			"policyDeclineMessages": {"Parental leave": "On parental leave"}
+		}
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, &OutOfOfficeSettings{
+		AutoDeclineMode: "declineNone",
+		DeclineMessage:  "On parental leave",
+	}, cfg.OutOfOffice.settingsFor("Parental leave"))
+	assert.Equal(t, &OutOfOfficeSettings{
+		AutoDeclineMode: "declineNone",
+		DeclineMessage:  "Out of office",
+	}, cfg.OutOfOffice.settingsFor("Vacation"))
+
+	assert.Equal(t, defaultAutoDeclineMode, EventConfig{}.OutOfOffice.settingsFor("Vacation").AutoDeclineMode)
+}
+
+func TestParseEventConfig_RejectsInvalidConfig(t *testing.T) {
+	for name, raw := range map[string]string{
+		"unknown field":          `{"outOfOffice": {"declineMesage": "typo"}}`,
+		"bad decline mode":       `{"outOfOffice": {"autoDeclineMode": "declineEverything"}}`,
+		"not a JSON object":      `[]`,
+		"template syntax":        `{"template": {"summary": "{{.PolicyName"}}`,
+		"unknown template field": `{"policyTemplates": {"Vacation": {"summary": "{{.Policy}}"}}}`,
+		"bad visibility":         `{"template": {"visibility": "secret"}}`,
+		"bad transparency":       `{"template": {"transparency": "busy"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseEventConfig([]byte(raw))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPlanOOOEvent_UsesPolicyTemplates(t *testing.T) {
+	cfg, err := ParseEventConfig([]byte(`{
+		"template": {
+			"summary": "{{.PolicyName}}: {{.UserEmail}}",
+			"description": "Request {{.ID}}",
+			"colorId": "5"
+		},
+		"policyTemplates": {
+			"Sick leave": {"summary": "Out sick", "visibility": "private", "transparency": "transparent"}
+		}
+	}`))
+	require.NoError(t, err)
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	ev, err := planOOOEvent(req, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "Vacation: "+req.UserEmail, ev.Summary)
+	assert.Equal(t, "Request request-123", ev.Description)
+	assert.Equal(t, "5", ev.ColorID)
+	assert.Empty(t, ev.Visibility)
+	assert.False(t, ev.Transparent)
+
+	req.PolicyName = "Sick leave"
+	ev, err = planOOOEvent(req, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "Out sick", ev.Summary)
+	assert.Equal(t, "Request request-123", ev.Description)
+	assert.Equal(t, "5", ev.ColorID)
+	assert.Equal(t, "private", ev.Visibility)
+	assert.True(t, ev.Transparent)
+
+	// Pending placeholders keep their marker whatever the template says.
+	req.Status.StatusType = ClockifyStatusPending
+	ev, err = planOOOEvent(req, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "Out sick (pending)", ev.Summary)
+}
+
+func TestPlanOOOEvent_DefaultTemplate(t *testing.T) {
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+
+	ev, err := planOOOEvent(req, EventConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "OOO — Vacation", ev.Summary)
+	assert.Equal(t, "Clockify request: request-123\nCreatedAt: "+req.CreatedAt, ev.Description)
+
+	req.PolicyName = ""
+	ev, err = planOOOEvent(req, EventConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "OOO", ev.Summary)
+}
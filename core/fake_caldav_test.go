@@ -0,0 +1,202 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAV is an in-memory CalDAV server holding calendar resources by
+// path. It supports what CalDAVCalendarBackend uses: conditional PUT, GET,
+// DELETE and a calendar-query REPORT filtered by time range and property.
+type fakeCalDAV struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	resources map[string]string // path → iCalendar object
+	// returned counts the events REPORTs have returned.
+	returned int
+}
+
+func newFakeCalDAV(t *testing.T) *fakeCalDAV {
+	t.Helper()
+
+	f := &fakeCalDAV{resources: make(map[string]string)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// backend returns a CalDAVCalendarBackend pointed at the fake, which keeps
+// calendars under /calendars/{user}/{calendar}/.
+func (f *fakeCalDAV) backend() *CalDAVCalendarBackend {
+	return NewCalDAVCalendarBackend(
+		f.URL+"/calendars/{user}/{calendar}/",
+		"sync", "app-password",
+		WithCalDAVHTTPClient(f.Client()),
+	)
+}
+
+// resource returns the iCalendar object stored at href, or "".
+func (f *fakeCalDAV) resource(href string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.resources[href]
+}
+
+// count returns how many resources a collection holds.
+func (f *fakeCalDAV) count(collection string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for p := range f.resources {
+		if strings.HasPrefix(p, collection) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeCalDAV) serve(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "sync" || pass != "app-password" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := r.URL.EscapedPath()
+	data, exists := f.resources[p]
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && exists || r.Header.Get("If-Match") == "*" && !exists {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if _, err := parseICalendar(string(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.resources[p] = string(body)
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+	case http.MethodGet:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		_, _ = io.WriteString(w, data)
+
+	case http.MethodDelete:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.resources, p)
+		w.WriteHeader(http.StatusNoContent)
+
+	case "REPORT":
+		f.report(w, r, p)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// report answers a calendar-query with the collection's events that overlap
+// its time range and have the property it filters on, containing the text
+// it matches, if any. Only the i;octet collation is supported.
+func (f *fakeCalDAV) report(w http.ResponseWriter, r *http.Request, collection string) {
+	var query struct {
+		Filter struct {
+			Calendar struct {
+				Event struct {
+					TimeRange struct {
+						Start string `xml:"start,attr"`
+						End   string `xml:"end,attr"`
+					} `xml:"time-range"`
+					PropFilter struct {
+						Name      string `xml:"name,attr"`
+						TextMatch struct {
+							Collation string `xml:"collation,attr"`
+							Text      string `xml:",chardata"`
+						} `xml:"text-match"`
+					} `xml:"prop-filter"`
+				} `xml:"comp-filter"`
+			} `xml:"comp-filter"`
+		} `xml:"filter"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := query.Filter.Calendar.Event
+	if collation := filter.PropFilter.TextMatch.Collation; collation != "" && collation != "i;octet" {
+		http.Error(w, "unsupported collation", http.StatusPreconditionFailed)
+		return
+	}
+	start, errStart := time.Parse(icalUTCLayout, filter.TimeRange.Start)
+	end, errEnd := time.Parse(icalUTCLayout, filter.TimeRange.End)
+	if errStart != nil || errEnd != nil {
+		http.Error(w, "bad time-range", http.StatusBadRequest)
+		return
+	}
+
+	var paths []string
+	for p := range f.resources {
+		if strings.HasPrefix(p, collection) {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	for _, p := range paths {
+		cal, _ := parseICalendar(f.resources[p])
+		vevent := cal.events()[0]
+		prop, ok := vevent.prop(filter.PropFilter.Name)
+		if !ok || !strings.Contains(prop.Value, filter.PropFilter.TextMatch.Text) {
+			continue
+		}
+		dtstart, _ := vevent.prop("DTSTART")
+		dtend, _ := vevent.prop("DTEND")
+		evStart, _, _ := dtstart.time()
+		evEnd, _, _ := dtend.time()
+		if !evStart.Before(end) || !evEnd.After(start) {
+			continue
+		}
+
+		f.returned++
+		fmt.Fprintf(&b,
+			`<D:response><D:href>%s</D:href><D:propstat><D:prop><D:getetag>"%d"</D:getetag><C:calendar-data>%s</C:calendar-data></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`,
+			p, len(f.resources[p]), html.EscapeString(f.resources[p]),
+		)
+	}
+	b.WriteString(`</D:multistatus>`)
+
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+// fakeGoogleCalendar is an in-memory stand-in for the Calendar v3 endpoints
+// the sync uses: events list (filtered by privateExtendedProperty and time
+// range), get, insert, patch and delete.
+type fakeGoogleCalendar struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	nextID  int
+	events  map[string]map[string]map[string]any // calendarID → eventID → event
+	deleted map[string]bool                      // calendarID/eventID
+}
+
+func newFakeGoogleCalendar(t *testing.T) *fakeGoogleCalendar {
+	t.Helper()
+
+	f := &fakeGoogleCalendar{
+		events:  make(map[string]map[string]map[string]any),
+		deleted: make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendars/{cal}/events", f.list)
+	mux.HandleFunc("POST /calendars/{cal}/events", f.insert)
+	mux.HandleFunc("GET /calendars/{cal}/events/{id}", f.get)
+	mux.HandleFunc("PATCH /calendars/{cal}/events/{id}", f.patch)
+	mux.HandleFunc("DELETE /calendars/{cal}/events/{id}", f.delete)
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// backend returns a GoogleCalendarBackend pointed at the fake.
+func (f *fakeGoogleCalendar) backend() *GoogleCalendarBackend {
+	return NewGoogleCalendarBackend(
+		jwt.Config{},
+		WithGoogleCalendarEndpoint(f.URL+"/", f.Client()),
+	)
+}
+
+// event returns a copy of a stored event, or nil.
+func (f *fakeGoogleCalendar) event(calendarID, eventID string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, ok := f.events[calendarID][eventID]
+	if !ok {
+		return nil
+	}
+	return maps.Clone(ev)
+}
+
+// count returns how many events the calendar holds.
+func (f *fakeGoogleCalendar) count(calendarID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.events[calendarID])
+}
+
+func (f *fakeGoogleCalendar) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	timeMin, _ := time.Parse(time.RFC3339, query.Get("timeMin"))
+	timeMax, _ := time.Parse(time.RFC3339, query.Get("timeMax"))
+
+	items := []map[string]any{}
+	for _, ev := range f.events[r.PathValue("cal")] {
+		if !matchesPrivateProperties(ev, query["privateExtendedProperty"]) {
+			continue
+		}
+
+		start, end := eventBounds(ev)
+		if !timeMax.IsZero() && !start.Before(timeMax) {
+			continue
+		}
+		if !timeMin.IsZero() && !end.After(timeMin) {
+			continue
+		}
+
+		items = append(items, ev)
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (f *fakeGoogleCalendar) insert(w http.ResponseWriter, r *http.Request) {
+	var ev map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	id := fmt.Sprintf("event-%d", f.nextID)
+	ev["id"] = id
+
+	cal := r.PathValue("cal")
+	if f.events[cal] == nil {
+		f.events[cal] = make(map[string]map[string]any)
+	}
+	f.events[cal][id] = ev
+
+	writeFakeJSON(w, http.StatusOK, ev)
+}
+
+func (f *fakeGoogleCalendar) get(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, status := f.lookup(r)
+	if ev == nil {
+		writeFakeError(w, status, "not found")
+		return
+	}
+	writeFakeJSON(w, http.StatusOK, ev)
+}
+
+func (f *fakeGoogleCalendar) patch(w http.ResponseWriter, r *http.Request) {
+	var changes map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, status := f.lookup(r)
+	if ev == nil {
+		writeFakeError(w, status, "not found")
+		return
+	}
+
+	maps.Copy(ev, changes)
+	writeFakeJSON(w, http.StatusOK, ev)
+}
+
+func (f *fakeGoogleCalendar) delete(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, status := f.lookup(r)
+	if ev == nil {
+		writeFakeError(w, status, "not found")
+		return
+	}
+
+	cal, id := r.PathValue("cal"), r.PathValue("id")
+	delete(f.events[cal], id)
+	f.deleted[cal+"/"+id] = true
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup finds the event addressed by r. Like the real API it answers 410
+// for events that were deleted and 404 for ones that never existed.
+func (f *fakeGoogleCalendar) lookup(r *http.Request) (map[string]any, int) {
+	cal, id := r.PathValue("cal"), r.PathValue("id")
+
+	if ev, ok := f.events[cal][id]; ok {
+		return ev, http.StatusOK
+	}
+	if f.deleted[cal+"/"+id] {
+		return nil, http.StatusGone
+	}
+	return nil, http.StatusNotFound
+}
+
+func matchesPrivateProperties(ev map[string]any, filters []string) bool {
+	props, _ := ev["extendedProperties"].(map[string]any)
+	private, _ := props["private"].(map[string]any)
+
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, "=")
+		if private[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func eventBounds(ev map[string]any) (time.Time, time.Time) {
+	parse := func(field string) time.Time {
+		dt, _ := ev[field].(map[string]any)
+		if date, ok := dt["date"].(string); ok {
+			t, _ := time.Parse("2006-01-02", date)
+			return t
+		}
+		dateTime, _ := dt["dateTime"].(string)
+		t, _ := time.Parse(time.RFC3339, dateTime)
+		return t
+	}
+	return parse("start"), parse("end")
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, message string) {
+	writeFakeJSON(w, status, map[string]any{
+		"error": map[string]any{"code": status, "message": message},
+	})
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGraph is an in-memory stand-in for the Microsoft Graph event endpoints
+// the Outlook backend uses: calendarView, events filtered on an extended
+// property, create, get, update and delete.
+// Events are kept per user and calendar, with "default" for the user's
+// default calendar.
+type fakeGraph struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	events   map[string]map[string]any // eventID → event
+	calendar map[string]string         // eventID → user/calendar
+	pageSize int
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	t.Helper()
+
+	f := &fakeGraph{
+		events:   make(map[string]map[string]any),
+		calendar: make(map[string]string),
+		pageSize: 2,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{user}/calendar/calendarView", f.calendarView)
+	mux.HandleFunc("GET /users/{user}/calendars/{cal}/calendarView", f.calendarView)
+	mux.HandleFunc("GET /users/{user}/calendar/events", f.list)
+	mux.HandleFunc("GET /users/{user}/calendars/{cal}/events", f.list)
+	mux.HandleFunc("POST /users/{user}/calendar/events", f.create)
+	mux.HandleFunc("POST /users/{user}/calendars/{cal}/events", f.create)
+	mux.HandleFunc("GET /users/{user}/events/{id}", f.get)
+	mux.HandleFunc("PATCH /users/{user}/events/{id}", f.update)
+	mux.HandleFunc("DELETE /users/{user}/events/{id}", f.delete)
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// backend returns an OutlookCalendarBackend pointed at the fake.
+func (f *fakeGraph) backend() *OutlookCalendarBackend {
+	return NewOutlookCalendarBackend(
+		"tenant", "client", "secret",
+		WithOutlookEndpoint(f.URL, f.Client()),
+	)
+}
+
+// event returns a copy of a stored event, or nil.
+func (f *fakeGraph) event(eventID string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, ok := f.events[eventID]
+	if !ok {
+		return nil
+	}
+	return maps.Clone(ev)
+}
+
+// count returns how many events a user's calendar holds.
+func (f *fakeGraph) count(userEmail, calendarID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, where := range f.calendar {
+		if where == userEmail+"/"+calendarID {
+			n++
+		}
+	}
+	return n
+}
+
+func fakeGraphCalendar(r *http.Request) string {
+	cal := r.PathValue("cal")
+	if cal == "" {
+		cal = "default"
+	}
+	return r.PathValue("user") + "/" + cal
+}
+
+// calendarView pages through the events overlapping the requested window,
+// pageSize at a time, following Graph's @odata.nextLink convention.
+func (f *fakeGraph) calendarView(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	start, errStart := time.Parse(time.RFC3339, query.Get("startDateTime"))
+	end, errEnd := time.Parse(time.RFC3339, query.Get("endDateTime"))
+	if errStart != nil || errEnd != nil {
+		writeFakeGraphError(w, http.StatusBadRequest, "ErrorInvalidParameter", "startDateTime and endDateTime are required")
+		return
+	}
+
+	var ids []string
+	for id, where := range f.calendar {
+		if where != fakeGraphCalendar(r) {
+			continue
+		}
+		evStart, evEnd := graphEventBounds(f.events[id])
+		if evStart.Before(end) && evEnd.After(start) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+
+	items := []map[string]any{}
+	for i := skip; i < len(ids) && i < skip+f.pageSize; i++ {
+		items = append(items, f.events[ids[i]])
+	}
+	page := map[string]any{"value": items}
+
+	if skip+f.pageSize < len(ids) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("$skip", fmt.Sprint(skip+f.pageSize))
+		next.RawQuery = q.Encode()
+		page["@odata.nextLink"] = f.URL + next.String()
+	}
+
+	writeFakeJSON(w, http.StatusOK, page)
+}
+
+// fakeGraphPropertyFilter is the one $filter the fake understands: events
+// with a single-value extended property of the given ID and value.
+var fakeGraphPropertyFilter = regexp.MustCompile(`^singleValueExtendedProperties/Any\(ep: ep/id eq '(.+)' and ep/value eq '(.*)'\)$`)
+
+// list returns a calendar's events matching the property filter, which it
+// requires, so that the backend can't list a whole calendar this way.
+func (f *fakeGraph) list(w http.ResponseWriter, r *http.Request) {
+	match := fakeGraphPropertyFilter.FindStringSubmatch(r.URL.Query().Get("$filter"))
+	if match == nil {
+		writeFakeGraphError(w, http.StatusBadRequest, "ErrorInvalidParameter", "unsupported $filter")
+		return
+	}
+	propertyID, value := match[1], strings.ReplaceAll(match[2], "''", "'")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for id, where := range f.calendar {
+		if where != fakeGraphCalendar(r) {
+			continue
+		}
+		props, _ := f.events[id]["singleValueExtendedProperties"].([]any)
+		for _, prop := range props {
+			prop, _ := prop.(map[string]any)
+			if propID, _ := prop["id"].(string); strings.EqualFold(propID, propertyID) && prop["value"] == value {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+
+	items := []map[string]any{}
+	for _, id := range ids {
+		items = append(items, f.events[id])
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]any{"value": items})
+}
+
+func (f *fakeGraph) create(w http.ResponseWriter, r *http.Request) {
+	var ev map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		writeFakeGraphError(w, http.StatusBadRequest, "ErrorInvalidRequest", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	id := fmt.Sprintf("AAMk-%d", f.nextID)
+	ev["id"] = id
+	f.events[id] = ev
+	f.calendar[id] = fakeGraphCalendar(r)
+
+	writeFakeJSON(w, http.StatusCreated, ev)
+}
+
+func (f *fakeGraph) get(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, ok := f.events[r.PathValue("id")]
+	if !ok {
+		writeFakeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+	writeFakeJSON(w, http.StatusOK, ev)
+}
+
+func (f *fakeGraph) update(w http.ResponseWriter, r *http.Request) {
+	var changes map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeFakeGraphError(w, http.StatusBadRequest, "ErrorInvalidRequest", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ev, ok := f.events[r.PathValue("id")]
+	if !ok {
+		writeFakeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	maps.Copy(ev, changes)
+	writeFakeJSON(w, http.StatusOK, ev)
+}
+
+func (f *fakeGraph) delete(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := f.events[id]; !ok {
+		writeFakeGraphError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+	delete(f.events, id)
+	delete(f.calendar, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func graphEventBounds(ev map[string]any) (time.Time, time.Time) {
+	parse := func(field string) time.Time {
+		dt, _ := ev[field].(map[string]any)
+		value, _ := dt["dateTime"].(string)
+		zone, _ := dt["timeZone"].(string)
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			loc = time.UTC
+		}
+		t, _ := time.ParseInLocation(graphDateTimeLayout, value, loc)
+		return t
+	}
+	return parse("start"), parse("end")
+}
+
+func writeFakeGraphError(w http.ResponseWriter, status int, code, message string) {
+	writeFakeJSON(w, status, map[string]any{
+		"error": map[string]any{"code": code, "message": message},
+	})
+}
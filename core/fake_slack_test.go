@@ -0,0 +1,114 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSlack is an in-memory stand-in for the Slack Web API methods the
+// status updater calls: users.lookupByEmail, users.profile.get and
+// users.profile.set.
+type fakeSlack struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]string             // email → user ID
+	profiles map[string]slackProfileStatus // user ID → status
+	sets     int
+}
+
+func newFakeSlack(t *testing.T, users map[string]string) *fakeSlack {
+	t.Helper()
+
+	f := &fakeSlack{
+		users:    users,
+		profiles: make(map[string]slackProfileStatus),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users.lookupByEmail", f.lookupByEmail)
+	mux.HandleFunc("GET /users.profile.get", f.profileGet)
+	mux.HandleFunc("POST /users.profile.set", f.profileSet)
+
+	f.Server = httptest.NewServer(f.authorized(mux))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// updater returns a SlackStatusUpdater pointed at the fake.
+func (f *fakeSlack) updater() *SlackStatusUpdater {
+	return NewSlackStatusUpdater("xoxp-admin", WithSlackEndpoint(f.URL, f.Client()))
+}
+
+// profile returns a user's current status.
+func (f *fakeSlack) profile(userID string) slackProfileStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.profiles[userID]
+}
+
+// setProfile sets a status as the user would.
+func (f *fakeSlack) setProfile(userID string, status slackProfileStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.profiles[userID] = status
+}
+
+func (f *fakeSlack) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxp-admin" {
+			writeFakeSlack(w, map[string]any{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *fakeSlack) lookupByEmail(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, ok := f.users[strings.ToLower(r.URL.Query().Get("email"))]
+	if !ok {
+		writeFakeSlack(w, map[string]any{"ok": false, "error": "users_not_found"})
+		return
+	}
+	writeFakeSlack(w, map[string]any{"ok": true, "user": map[string]any{"id": id}})
+}
+
+func (f *fakeSlack) profileGet(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeFakeSlack(w, map[string]any{"ok": true, "profile": f.profiles[r.URL.Query().Get("user")]})
+}
+
+func (f *fakeSlack) profileSet(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		User    string             `json:"user"`
+		Profile slackProfileStatus `json:"profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeFakeSlack(w, map[string]any{"ok": false, "error": "invalid_json"})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.profiles[body.User] = body.Profile
+	f.sets++
+	writeFakeSlack(w, map[string]any{"ok": true, "profile": body.Profile})
+}
+
+// writeFakeSlack answers like Slack does, with 200 OK even for errors.
+func writeFakeSlack(w http.ResponseWriter, v any) {
+	writeFakeJSON(w, http.StatusOK, v)
+}
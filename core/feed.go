@@ -0,0 +1,263 @@
+package core
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultFeedHistory = 90 * 24 * time.Hour
+
+// FeedHandler serves the approved time off in the state store as an
+// iCalendar feed that calendar apps can subscribe to, for people who want to
+// see who's out without events being written to their own calendars.
+//
+// The query string narrows the feed: user (an email or Clockify user ID),
+// policy and group may each be repeated, and an event must match one value
+// of every parameter given. token must be one of Tokens; subscriptions
+// can't send headers, so the token is part of the feed's URL.
+type FeedHandler struct {
+	Store  SyncStateStore
+	Tokens []string
+	Groups FeedGroups
+	// Events words the feed's events as they are on calendars.
+	Events EventConfig
+	// Name is shown by calendar apps for the subscription.
+	Name string
+	// History is how long past time off stays in the feed.
+	History time.Duration
+	Clock   func() time.Time
+}
+
+func NewFeedHandler(store SyncStateStore, tokens []string, opts ...func(*FeedHandler)) *FeedHandler {
+	h := &FeedHandler{
+		Store:   store,
+		Tokens:  tokens,
+		Name:    "Out of office",
+		History: defaultFeedHistory,
+		Clock:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func WithFeedGroups(groups FeedGroups) func(*FeedHandler) {
+	return func(h *FeedHandler) {
+		h.Groups = groups
+	}
+}
+
+func WithFeedEventConfig(cfg EventConfig) func(*FeedHandler) {
+	return func(h *FeedHandler) {
+		h.Events = cfg
+	}
+}
+
+func WithFeedName(name string) func(*FeedHandler) {
+	return func(h *FeedHandler) {
+		h.Name = name
+	}
+}
+
+// FeedGroups names sets of users, by email or Clockify user ID, that a feed
+// can be filtered to. It is loaded from JSON, e.g.
+//
+//	{"engineering": ["ada@example.com", "5f3c0b7e2a1d4c0012345678"]}
+type FeedGroups map[string][]string
+
+// LoadFeedGroups reads FeedGroups from a JSON file.
+func LoadFeedGroups(path string) (FeedGroups, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read feed groups %s: %w", path, err)
+	}
+	return ParseFeedGroups(b)
+}
+
+// ParseFeedGroups parses FeedGroups from JSON.
+func ParseFeedGroups(b []byte) (FeedGroups, error) {
+	var groups FeedGroups
+	if err := json.Unmarshal(b, &groups); err != nil {
+		return nil, fmt.Errorf("parse feed groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if !validToken(h.Tokens, query.Get("token")) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := h.filter(query["user"], query["policy"], query["group"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only approved time off that ended within the history can be in the
+	// feed, give or take the day a local end can be past the period's.
+	after := h.Clock().Add(-h.History - 24*time.Hour)
+	records, err := h.Store.ListSyncedRequestsEndingAfter(r.Context(), ClockifyStatusApproved, after)
+	if err != nil {
+		log.Printf("Failed to list approved requests for the feed: %v", err)
+		http.Error(w, "state store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(h.render(records, filter)))
+	}
+}
+
+// feedFilter is a feed's query; nil sets match everything.
+type feedFilter struct {
+	users    map[string]bool
+	policies map[string]bool
+}
+
+func (h *FeedHandler) filter(users, policies, groups []string) (feedFilter, error) {
+	var f feedFilter
+
+	if len(users) > 0 || len(groups) > 0 {
+		f.users = make(map[string]bool)
+	}
+	for _, user := range users {
+		f.users[strings.ToLower(user)] = true
+	}
+	for _, group := range groups {
+		members, ok := h.Groups[group]
+		if !ok {
+			return feedFilter{}, fmt.Errorf("unknown group %q", group)
+		}
+		for _, member := range members {
+			f.users[strings.ToLower(member)] = true
+		}
+	}
+
+	if len(policies) > 0 {
+		f.policies = make(map[string]bool)
+	}
+	for _, policy := range policies {
+		f.policies[strings.ToLower(policy)] = true
+	}
+
+	return f, nil
+}
+
+func (f feedFilter) matches(rec *SyncedClockifyRequest) bool {
+	if f.users != nil && !f.users[strings.ToLower(rec.UserEmail)] && !f.users[strings.ToLower(rec.UserID)] {
+		return false
+	}
+	if f.policies != nil && !f.policies[strings.ToLower(rec.PolicyName)] {
+		return false
+	}
+	return true
+}
+
+// render writes the VCALENDAR for the approved records matching filter, in
+// the order they start.
+func (h *FeedHandler) render(records []*SyncedClockifyRequest, filter feedFilter) string {
+	now := h.Clock()
+	cutoff := now.Add(-h.History)
+
+	type feedEvent struct {
+		rec *SyncedClockifyRequest
+		ev  OOOEvent
+	}
+	var events []feedEvent
+	for _, rec := range records {
+		if rec.Status != ClockifyStatusApproved || !filter.matches(rec) {
+			continue
+		}
+
+		ev, err := planOOOEvent(rec.clockifyRequest(), h.Events)
+		if err != nil {
+			log.Printf("Leaving request %s out of the feed: %v", rec.ClockifyRequestID, err)
+			continue
+		}
+		if ev.End.Before(cutoff) {
+			continue
+		}
+		events = append(events, feedEvent{rec: rec, ev: ev})
+	}
+	slices.SortFunc(events, func(a, b feedEvent) int {
+		if c := a.ev.Start.Compare(b.ev.Start); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.rec.ClockifyRequestID, b.rec.ClockifyRequestID)
+	})
+
+	var w icalWriter
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icalProductID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.text("X-WR-CALNAME", h.Name)
+	w.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	w.line("X-PUBLISHED-TTL:PT1H")
+
+	for _, fe := range events {
+		rec, ev := fe.rec, fe.ev
+
+		w.line("BEGIN:VEVENT")
+		w.text("UID", feedEventUID(rec.ClockifyRequestID))
+		w.line("DTSTAMP:" + feedEventStamp(rec, now).Format(icalUTCLayout))
+		w.time("DTSTART", ev.Start, ev.AllDay)
+		w.time("DTEND", ev.End, ev.AllDay)
+		w.text("SUMMARY", rec.UserEmail+": "+ev.Summary)
+		if ev.Description != "" {
+			w.text("DESCRIPTION", ev.Description)
+		}
+		if rec.PolicyName != "" {
+			w.text("CATEGORIES", rec.PolicyName)
+		}
+		switch ev.Visibility {
+		case "public", "private", "confidential":
+			w.line("CLASS:" + strings.ToUpper(ev.Visibility))
+		}
+		// Someone else's time off never makes the subscriber busy.
+		w.line("TRANSP:TRANSPARENT")
+		w.text(icalClockifyProperty, rec.ClockifyRequestID)
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return w.String()
+}
+
+// feedEventUID is derived from the request alone, so that calendar apps
+// update an event when its request changes rather than duplicating it.
+func feedEventUID(clockifyRequestID string) string {
+	return "clockify-" + clockifyRequestID + "@ooo-calendar-sync"
+}
+
+// feedEventStamp is when the request was approved, falling back to when it
+// was last seen and then now.
+func feedEventStamp(rec *SyncedClockifyRequest, now time.Time) time.Time {
+	for _, raw := range []string{rec.StatusChangedAt, rec.LastSeenAt} {
+		if t, err := ParseTimeAny(raw); err == nil {
+			return t.UTC()
+		}
+	}
+	return now.UTC()
+}
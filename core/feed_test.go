@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getFeed(t *testing.T, h http.Handler, query string) (*httptest.ResponseRecorder, []*icalComponent) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.ics?"+query, nil))
+	if w.Code != http.StatusOK {
+		return w, nil
+	}
+
+	cal, err := parseICalendar(w.Body.String())
+	require.NoError(t, err)
+	return w, cal.events()
+}
+
+func feedUIDs(events []*icalComponent) []string {
+	var uids []string
+	for _, ev := range events {
+		uids = append(uids, ev.text("UID"))
+	}
+	return uids
+}
+
+// scanlessStore fails listings of the whole store, which subscribers could
+// otherwise set off as often as they like.
+type scanlessStore struct {
+	*MemoryStore
+}
+
+func (scanlessStore) ListSyncedRequests(context.Context) ([]*SyncedClockifyRequest, error) {
+	return nil, errors.New("listed the whole store")
+}
+
+func TestFeedHandler_ServesApprovedTimeOff(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	put := func(req ClockifyRequest) {
+		item, err := req.ToDynamoItem()
+		require.NoError(t, err)
+		require.NoError(t, store.PutSyncedRequest(ctx, item))
+	}
+
+	vacation := makeStatusRequest("vacation", ClockifyStatusApproved, time.Date(2025, 12, 2, 9, 30, 0, 0, time.UTC))
+	vacation.UserID = "user-ada"
+	vacation.UserEmail = "ada@example.com"
+	vacation.UserTimeZone = "America/New_York"
+	vacation.TimeOffPeriod.Period.Start = "2025-12-15T05:00:00Z"
+	vacation.TimeOffPeriod.Period.End = "2025-12-17T04:59:59Z"
+	put(vacation)
+
+	sick := makeStatusRequest("sick", ClockifyStatusApproved, time.Date(2025, 12, 9, 8, 0, 0, 0, time.UTC))
+	sick.UserID = "user-grace"
+	sick.UserEmail = "grace@example.com"
+	sick.PolicyName = "Sick leave"
+	put(sick)
+
+	put(makeStatusRequest("pending", ClockifyStatusPending, time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC)))
+	put(makeStatusRequest("rejected", ClockifyStatusRejected, time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC)))
+
+	old := makeStatusRequest("old", ClockifyStatusApproved, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	old.TimeOffPeriod.Period.Start = "2025-06-02T00:00:00Z"
+	old.TimeOffPeriod.Period.End = "2025-06-02T23:59:59Z"
+	put(old)
+
+	h := NewFeedHandler(
+		scanlessStore{store}, []string{"feed-token"},
+		WithFeedGroups(FeedGroups{"engineering": {"Ada@example.com"}}),
+	)
+	h.Clock = func() time.Time { return time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC) }
+
+	w, _ := getFeed(t, h, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = getFeed(t, h, "token=wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = getFeed(t, h, "token=feed-token&group=marketing")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only approved time off is listed, in the order it starts, leaving out
+	// what ended before the feed's history.
+	w, events := getFeed(t, h, "token=feed-token")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{
+		"clockify-sick@ooo-calendar-sync",
+		"clockify-vacation@ooo-calendar-sync",
+	}, feedUIDs(events))
+
+	ev := events[1]
+	assert.Equal(t, "ada@example.com: OOO — Vacation", ev.text("SUMMARY"))
+	assert.Equal(t, "Vacation", ev.text("CATEGORIES"))
+	assert.Equal(t, "TRANSPARENT", ev.text("TRANSP"))
+	assert.Equal(t, "vacation", ev.text(icalClockifyProperty))
+	assert.Equal(t, "20251202T093000Z", ev.text("DTSTAMP"))
+	start, _ := ev.prop("DTSTART")
+	assert.Equal(t, icalProperty{Name: "DTSTART", Params: map[string]string{"VALUE": "DATE"}, Value: "20251215"}, start)
+	end, _ := ev.prop("DTEND")
+	assert.Equal(t, "20251217", end.Value)
+
+	// The same request always gets the same UID, so moving it updates the
+	// subscribed event.
+	moved := vacation
+	moved.TimeOffPeriod.Period.Start = "2025-12-22T05:00:00Z"
+	moved.TimeOffPeriod.Period.End = "2025-12-23T04:59:59Z"
+	item := mustGetSyncedRequest(t, store, "vacation")
+	item.PeriodStart = moved.TimeOffPeriod.Period.Start
+	item.PeriodEnd = moved.TimeOffPeriod.Period.End
+	require.NoError(t, store.PutSyncedRequest(ctx, item))
+
+	_, events = getFeed(t, h, "token=feed-token&user=ada@example.com")
+	require.Len(t, events, 1)
+	assert.Equal(t, "clockify-vacation@ooo-calendar-sync", events[0].text("UID"))
+	start, _ = events[0].prop("DTSTART")
+	assert.Equal(t, "20251222", start.Value)
+
+	// Filters combine: any listed value of each, and all parameters.
+	_, events = getFeed(t, h, "token=feed-token&user=user-grace")
+	assert.Equal(t, []string{"clockify-sick@ooo-calendar-sync"}, feedUIDs(events))
+	_, events = getFeed(t, h, "token=feed-token&group=engineering")
+	assert.Equal(t, []string{"clockify-vacation@ooo-calendar-sync"}, feedUIDs(events))
+	_, events = getFeed(t, h, "token=feed-token&policy=sick+leave&policy=Vacation")
+	assert.Len(t, events, 2)
+	_, events = getFeed(t, h, "token=feed-token&group=engineering&policy=Sick+leave")
+	assert.Empty(t, events)
+}
+
+func TestParseFeedGroups(t *testing.T) {
+	groups, err := ParseFeedGroups([]byte(`{"engineering": ["ada@example.com", "user-grace"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, FeedGroups{"engineering": {"ada@example.com", "user-grace"}}, groups)
+
+	_, err = ParseFeedGroups([]byte(`["ada@example.com"]`))
+	assert.Error(t, err)
+}
@@ -26,6 +26,14 @@ func ParseRawClockifyEnvelope(respBytes []byte) (rawClockifyEnvelope, error) {
 	return env, nil
 }
 
+// decode converts the envelope into typed requests, skipping malformed ones.
+func (env rawClockifyEnvelope) decode() ClockifyEnvelope {
+	return ClockifyEnvelope{
+		Count:    env.Count,
+		Requests: ParseClockifyRequests(env.Requests),
+	}
+}
+
 // ParseClockifyRequests converts valid raw request payloads into ClockifyRequest structs and skips malformed JSON entries, logging each unmarshal error via log.Printf.
 func ParseClockifyRequests(rawRequests []json.RawMessage) []ClockifyRequest {
 	requests := make([]ClockifyRequest, 0, len(rawRequests))
@@ -57,6 +65,27 @@ func isTimestampInWindow(
 	return !timestamp.Before(start) && timestamp.Before(end)
 }
 
+// FilterRequestsByActivity returns the requests that were created or had
+// their status updated in [start, end).
+func FilterRequestsByActivity(
+	requests []ClockifyRequest,
+	start, end time.Time,
+) []ClockifyRequest {
+	filtered := make([]ClockifyRequest, 0, len(requests))
+
+	for _, r := range requests {
+		createdInWindow := isTimestampInWindow(r.CreatedAt, start, end)
+
+		statusUpdatedInWindow := isTimestampInWindow(r.Status.ChangedAt, start, end)
+
+		if createdInWindow || statusUpdatedInWindow {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return filtered
+}
+
 // Filters raw requests that were created or had their status updated in [start, end).
 func FilterRawRequestsByActivity(
 	rawRequests []json.RawMessage,
@@ -24,6 +24,7 @@ func findClockifyEvents(
 		TimeMax(timeMax.Format(time.RFC3339)).
 		SingleEvents(true).
 		ShowDeleted(false).
+		Context(ctx).
 		Do()
 
 	if err != nil {
@@ -32,3 +33,34 @@ func findClockifyEvents(
 
 	return events.Items, nil
 }
+
+// listClockifyEvents returns every event in calID within the time range that
+// carries a "clockifyRequestId" private extended property, whatever its value.
+func listClockifyEvents(
+	ctx context.Context,
+	srv *calendar.Service,
+	calID string,
+	timeMin, timeMax time.Time,
+) ([]*calendar.Event, error) {
+	var items []*calendar.Event
+
+	err := srv.Events.List(calID).
+		TimeMin(timeMin.Format(time.RFC3339)).
+		TimeMax(timeMax.Format(time.RFC3339)).
+		SingleEvents(true).
+		ShowDeleted(false).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, item := range page.Items {
+				if item.ExtendedProperties != nil && item.ExtendedProperties.Private["clockifyRequestId"] != "" {
+					items = append(items, item)
+				}
+			}
+			return nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
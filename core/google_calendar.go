@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const googleOutOfOfficeEventType = "outOfOffice"
+
+// GoogleCalendarBackend writes to Google Calendar by impersonating each user
+// with a domain-wide delegated service account. It is safe for concurrent
+// use; each user's service, and so their access token, is created once.
+type GoogleCalendarBackend struct {
+	jwtCfg  jwt.Config
+	limiter *RateLimiter
+
+	// endpoint and httpClient replace impersonation when set, for testing.
+	endpoint   string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	services map[string]*calendar.Service
+}
+
+func NewGoogleCalendarBackend(jwtCfg jwt.Config, opts ...func(*GoogleCalendarBackend)) *GoogleCalendarBackend {
+	b := &GoogleCalendarBackend{
+		jwtCfg:   jwtCfg,
+		services: make(map[string]*calendar.Service),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// For testing: talk to endpoint with httpClient rather than impersonating
+// users against the real API.
+func WithGoogleCalendarEndpoint(endpoint string, httpClient *http.Client) func(*GoogleCalendarBackend) {
+	return func(b *GoogleCalendarBackend) {
+		b.endpoint = endpoint
+		b.httpClient = httpClient
+	}
+}
+
+// WithGoogleRateLimiter makes every Calendar API request wait on limiter,
+// which may be shared with other backends drawing on the same quota.
+func WithGoogleRateLimiter(limiter *RateLimiter) func(*GoogleCalendarBackend) {
+	return func(b *GoogleCalendarBackend) {
+		b.limiter = limiter
+	}
+}
+
+func (b *GoogleCalendarBackend) service(ctx context.Context, userEmail string) (*calendar.Service, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if srv, ok := b.services[userEmail]; ok {
+		return srv, nil
+	}
+
+	var opts []option.ClientOption
+	if b.httpClient != nil {
+		opts = append(opts,
+			option.WithEndpoint(b.endpoint),
+			option.WithHTTPClient(rateLimitedClient(b.httpClient, b.limiter)),
+		)
+	} else {
+		cfg := b.jwtCfg
+		cfg.Subject = userEmail
+		// The service outlives ctx, so the token source must not use it.
+		client := cfg.Client(context.Background())
+		opts = append(opts, option.WithHTTPClient(rateLimitedClient(client, b.limiter)))
+	}
+
+	srv, err := calendar.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("calendar service for %s: %w", userEmail, err)
+	}
+
+	b.services[userEmail] = srv
+	return srv, nil
+}
+
+func (b *GoogleCalendarBackend) FindEvents(
+	ctx context.Context,
+	userEmail, calendarID, clockifyRequestID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := findClockifyEvents(ctx, srv, calendarID, clockifyRequestID, timeMin, timeMax)
+	if err != nil {
+		return nil, googleError(err)
+	}
+
+	events := make([]CalendarEvent, 0, len(items))
+	for _, item := range items {
+		events = append(events, fromGoogleEvent(item))
+	}
+	return events, nil
+}
+
+func (b *GoogleCalendarBackend) InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error) {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return "", err
+	}
+
+	inserted, err := srv.Events.Insert(calendarID, toGoogleEvent(ev)).Context(ctx).Do()
+	if err != nil {
+		return "", googleError(err)
+	}
+	return inserted.Id, nil
+}
+
+func (b *GoogleCalendarBackend) PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return err
+	}
+
+	// Google rejects changing an event's eventType with a patch.
+	current, err := srv.Events.Get(calendarID, eventID).Context(ctx).Do()
+	if err != nil {
+		return googleError(err)
+	}
+	if !b.patchesInPlace(userEmail, fromGoogleEvent(current), ev) {
+		return fmt.Errorf("%w: event %s is %q", ErrEventTypeMismatch, eventID, current.EventType)
+	}
+
+	_, err = srv.Events.Patch(calendarID, eventID, toGoogleEvent(ev)).Context(ctx).Do()
+	return googleError(err)
+}
+
+// patchesInPlace is false between out-of-office and other events.
+func (b *GoogleCalendarBackend) patchesInPlace(userEmail string, current CalendarEvent, ev OOOEvent) bool {
+	return current.OutOfOffice == (ev.OutOfOffice != nil)
+}
+
+func (b *GoogleCalendarBackend) DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return err
+	}
+
+	return googleError(srv.Events.Delete(calendarID, eventID).Context(ctx).Do())
+}
+
+func (b *GoogleCalendarBackend) GetEvent(ctx context.Context, userEmail, calendarID, eventID string) (CalendarEvent, error) {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+
+	e, err := srv.Events.Get(calendarID, eventID).Context(ctx).Do()
+	if err != nil {
+		return CalendarEvent{}, googleError(err)
+	}
+	// Cancelled events are still returned by ID once deleted.
+	if e.Status == "cancelled" {
+		return CalendarEvent{}, fmt.Errorf("%w: event %s is cancelled", ErrEventNotFound, eventID)
+	}
+	return fromGoogleEvent(e), nil
+}
+
+func (b *GoogleCalendarBackend) ListEvents(
+	ctx context.Context,
+	userEmail, calendarID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	srv, err := b.service(ctx, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := listClockifyEvents(ctx, srv, calendarID, timeMin, timeMax)
+	if err != nil {
+		return nil, googleError(err)
+	}
+
+	events := make([]CalendarEvent, 0, len(items))
+	for _, item := range items {
+		events = append(events, fromGoogleEvent(item))
+	}
+	return events, nil
+}
+
+func toGoogleEvent(ev OOOEvent) *calendar.Event {
+	// Both fields are always set so that patching a placeholder after
+	// approval confirms it.
+	status, transparency := "confirmed", "opaque"
+	if ev.Tentative {
+		status = "tentative"
+	}
+	if ev.Transparent {
+		transparency = "transparent"
+	}
+
+	g := &calendar.Event{
+		Summary:      ev.Summary,
+		Description:  ev.Description,
+		ColorId:      ev.ColorID,
+		Visibility:   ev.Visibility,
+		Status:       status,
+		Transparency: transparency,
+		// Attaching the Clockify request ID as a private extended property.
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				"clockifyRequestId": ev.ClockifyRequestID,
+			},
+		},
+	}
+
+	if ev.OutOfOffice != nil {
+		g.EventType = googleOutOfOfficeEventType
+		g.OutOfOfficeProperties = &calendar.EventOutOfOfficeProperties{
+			AutoDeclineMode: ev.OutOfOffice.AutoDeclineMode,
+			DeclineMessage:  ev.OutOfOffice.DeclineMessage,
+		}
+	}
+
+	// Out-of-office events can't be all-day, so whole days are written as
+	// the span between local midnights instead.
+	if ev.AllDay && ev.OutOfOffice == nil {
+		g.Start = &calendar.EventDateTime{Date: formatDate(ev.Start)}
+		g.End = &calendar.EventDateTime{Date: formatDate(ev.End)} // exclusive
+	} else {
+		g.Start = &calendar.EventDateTime{
+			DateTime: ev.Start.Format(time.RFC3339),
+			TimeZone: ev.Start.Location().String(),
+		}
+		g.End = &calendar.EventDateTime{
+			DateTime: ev.End.Format(time.RFC3339),
+			TimeZone: ev.End.Location().String(),
+		}
+	}
+
+	return g
+}
+
+func fromGoogleEvent(e *calendar.Event) CalendarEvent {
+	found := CalendarEvent{
+		ID:          e.Id,
+		OutOfOffice: e.EventType == googleOutOfOfficeEventType,
+	}
+
+	if e.ExtendedProperties != nil {
+		found.ClockifyRequestID = e.ExtendedProperties.Private["clockifyRequestId"]
+	}
+
+	if e.Start != nil && e.Start.Date != "" {
+		found.AllDay = true
+	}
+	found.Start = parseGoogleEventTime(e.Start)
+	found.End = parseGoogleEventTime(e.End)
+
+	return found
+}
+
+// parseGoogleEventTime returns the zero time for missing or unparseable
+// values, which never match a planned event.
+func parseGoogleEventTime(t *calendar.EventDateTime) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	value := t.DateTime
+	if t.Date != "" {
+		value = t.Date
+	}
+	parsed, err := ParseTimeAny(value)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// googleError maps a Google API 404 or 410, which the Calendar API returns
+// for events that were deleted out from under us, to ErrEventNotFound.
+func googleError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) &&
+		(apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone) {
+		return fmt.Errorf("%w: %v", ErrEventNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Just enough of iCalendar (RFC 5545) to write the sync's events and read
+// them back from servers that may have rewritten them.
+
+const icalProductID = "-//Corbalt//ooo-calendar-sync//EN"
+
+// icalClockifyProperty tags events with their Clockify request ID, the
+// iCalendar equivalent of the private clockifyRequestId property on Google
+// events.
+const icalClockifyProperty = "X-CLOCKIFY-REQUEST-ID"
+
+const (
+	icalDateLayout     = "20060102"
+	icalDateTimeLayout = "20060102T150405"
+	icalUTCLayout      = "20060102T150405Z"
+)
+
+// icalWriter builds an iCalendar object, folding long content lines.
+type icalWriter struct {
+	strings.Builder
+}
+
+// line writes a content line, folded at 75 octets without splitting
+// characters.
+func (w *icalWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the space.
+		limit = 74
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// text writes a property with a TEXT value.
+func (w *icalWriter) text(name, value string) {
+	w.line(name + ":" + escapeICalText(value))
+}
+
+// time writes a DATE property for all-day times, dated in their own
+// location, and a UTC DATE-TIME property otherwise.
+func (w *icalWriter) time(name string, t time.Time, allDay bool) {
+	if allDay {
+		w.line(name + ";VALUE=DATE:" + t.Format(icalDateLayout))
+		return
+	}
+	w.line(name + ":" + t.UTC().Format(icalUTCLayout))
+}
+
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+var icalTextUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+// icalProperty is one content line.
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icalComponent is a parsed component, e.g. a VCALENDAR or a VEVENT.
+type icalComponent struct {
+	Name       string
+	Properties []icalProperty
+	Components []*icalComponent
+}
+
+// prop returns the component's first property called name.
+func (c *icalComponent) prop(name string) (icalProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icalProperty{}, false
+}
+
+// text returns the unescaped value of a TEXT property, or "".
+func (c *icalComponent) text(name string) string {
+	p, ok := c.prop(name)
+	if !ok {
+		return ""
+	}
+	return icalTextUnescaper.Replace(p.Value)
+}
+
+// events returns the VEVENTs directly inside c.
+func (c *icalComponent) events() []*icalComponent {
+	var events []*icalComponent
+	for _, sub := range c.Components {
+		if sub.Name == "VEVENT" {
+			events = append(events, sub)
+		}
+	}
+	return events
+}
+
+// time reads a DATE or DATE-TIME property. Dates are returned as midnight
+// UTC; date-times in their TZID, or UTC when floating or the zone is
+// unknown.
+func (p icalProperty) time() (t time.Time, allDay bool, err error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == len(icalDateLayout) {
+		t, err = time.ParseInLocation(icalDateLayout, p.Value, time.UTC)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		t, err = time.Parse(icalUTCLayout, p.Value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err = time.ParseInLocation(icalDateTimeLayout, p.Value, loc)
+	return t, false, err
+}
+
+// parseICalendar parses an iCalendar object into its outermost component.
+func parseICalendar(data string) (*icalComponent, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	var stack []*icalComponent
+	var root *icalComponent
+
+	for line := range strings.SplitSeq(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		p, err := parseICalLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			c := &icalComponent{Name: strings.ToUpper(p.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root == nil {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("ical: unexpected END:%s", p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ical: property %s outside a component", p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("ical: no component")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: unterminated %s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// parseICalLine splits an unfolded content line into its name, parameters
+// and value. Parameter values may be quoted.
+func parseICalLine(line string) (icalProperty, error) {
+	var (
+		fields  []string
+		start   int
+		quoted  bool
+		valueAt = -1
+	)
+	for i := 0; i < len(line) && valueAt < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				fields = append(fields, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				fields = append(fields, line[start:i])
+				valueAt = i + 1
+			}
+		}
+	}
+	if valueAt < 0 {
+		return icalProperty{}, fmt.Errorf("ical: malformed line %q", line)
+	}
+
+	p := icalProperty{
+		Name:  strings.ToUpper(fields[0]),
+		Value: line[valueAt:],
+	}
+	for _, param := range fields[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		if p.Params == nil {
+			p.Params = make(map[string]string)
+		}
+		p.Params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+	return p, nil
+}
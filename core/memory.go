@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a SyncStateStore kept in process memory, for tests and
+// local runs that should not touch DynamoDB.
+type MemoryStore struct {
+	mu         sync.Mutex
+	items      map[string]*SyncedClockifyRequest
+	watermarks map[string]time.Time
+	leases     map[string]memoryLease
+}
+
+type memoryLease struct {
+	owner string
+	until time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:      make(map[string]*SyncedClockifyRequest),
+		watermarks: make(map[string]time.Time),
+		leases:     make(map[string]memoryLease),
+	}
+}
+
+func (s *MemoryStore) PutSyncedRequest(ctx context.Context, item *SyncedClockifyRequest) error {
+	if item.ClockifyRequestID == "" {
+		return errors.New("missing Clockify request ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stored int64
+	if existing, ok := s.items[item.ClockifyRequestID]; ok {
+		stored = existing.Version
+	}
+	if stored != item.Version {
+		return &ConflictError{
+			ClockifyRequestID: item.ClockifyRequestID,
+			Reason:            fmt.Sprintf("record is at version %d, not %d", stored, item.Version),
+		}
+	}
+
+	item.Version++
+	s.items[item.ClockifyRequestID] = cloneSyncedRequest(item)
+	return nil
+}
+
+func (s *MemoryStore) DeleteSyncedRequest(ctx context.Context, clockifyRequestID string) error {
+	if clockifyRequestID == "" {
+		return errors.New("missing Clockify request ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, clockifyRequestID)
+	return nil
+}
+
+func (s *MemoryStore) GetSyncedRequest(ctx context.Context, clockifyRequestID string) (*SyncedClockifyRequest, error) {
+	if clockifyRequestID == "" {
+		return nil, errors.New("missing Clockify request ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[clockifyRequestID]
+	if !ok {
+		return nil, nil
+	}
+	return cloneSyncedRequest(item), nil
+}
+
+// ListSyncedRequests returns the records ordered by request ID.
+func (s *MemoryStore) ListSyncedRequests(ctx context.Context) ([]*SyncedClockifyRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]*SyncedClockifyRequest, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, cloneSyncedRequest(item))
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ClockifyRequestID < items[j].ClockifyRequestID
+	})
+
+	return items, nil
+}
+
+// ListSyncedRequestsEndingAfter returns the records ordered by request ID.
+// Records whose period end doesn't parse are left out.
+func (s *MemoryStore) ListSyncedRequestsEndingAfter(
+	ctx context.Context,
+	status string,
+	after time.Time,
+) ([]*SyncedClockifyRequest, error) {
+	return s.listSyncedRequestsWhere(ctx, func(item *SyncedClockifyRequest) bool {
+		end, err := ParseTimeAny(item.PeriodEnd)
+		return item.Status == status && err == nil && end.After(after)
+	})
+}
+
+// ListDueRetries returns the records ordered by request ID.
+func (s *MemoryStore) ListDueRetries(ctx context.Context, now time.Time) ([]*SyncedClockifyRequest, error) {
+	return s.listSyncedRequestsWhere(ctx, func(item *SyncedClockifyRequest) bool {
+		return item.SyncState == SyncStateFailed && item.retryDue(now)
+	})
+}
+
+// ListDeadLetters returns the records ordered by request ID.
+func (s *MemoryStore) ListDeadLetters(ctx context.Context) ([]*SyncedClockifyRequest, error) {
+	return s.listSyncedRequestsWhere(ctx, func(item *SyncedClockifyRequest) bool {
+		return item.SyncState == SyncStateDeadLetter
+	})
+}
+
+func (s *MemoryStore) listSyncedRequestsWhere(
+	ctx context.Context,
+	match func(*SyncedClockifyRequest) bool,
+) ([]*SyncedClockifyRequest, error) {
+	all, err := s.ListSyncedRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*SyncedClockifyRequest
+	for _, item := range all {
+		if match(item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) GetWatermark(ctx context.Context, name string) (time.Time, error) {
+	if name == "" {
+		return time.Time{}, errors.New("missing watermark name")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.watermarks[name], nil
+}
+
+func (s *MemoryStore) PutWatermark(ctx context.Context, name string, t time.Time) error {
+	if name == "" {
+		return errors.New("missing watermark name")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watermarks[name] = t.UTC()
+	return nil
+}
+
+func (s *MemoryStore) ClaimSyncedRequest(ctx context.Context, clockifyRequestID, owner string, now, until time.Time) error {
+	if clockifyRequestID == "" || owner == "" {
+		return errors.New("missing Clockify request ID or lease owner")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[clockifyRequestID]; ok && lease.owner != owner && lease.until.After(now) {
+		return &ConflictError{
+			ClockifyRequestID: clockifyRequestID,
+			Reason:            "another run is processing it",
+		}
+	}
+
+	s.leases[clockifyRequestID] = memoryLease{owner: owner, until: until}
+	return nil
+}
+
+func (s *MemoryStore) ReleaseSyncedRequest(ctx context.Context, clockifyRequestID, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[clockifyRequestID]; ok && lease.owner == owner {
+		delete(s.leases, clockifyRequestID)
+	}
+	return nil
+}
+
+// cloneSyncedRequest copies item so callers can't mutate stored state.
+func cloneSyncedRequest(item *SyncedClockifyRequest) *SyncedClockifyRequest {
+	clone := *item
+	clone.GoogleCalendarEvents = slices.Clone(item.GoogleCalendarEvents)
+	if item.SlackStatus != nil {
+		status := *item.SlackStatus
+		clone.SlackStatus = &status
+	}
+	return &clone
+}
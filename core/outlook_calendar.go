@@ -0,0 +1,393 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	defaultGraphEndpoint = "https://graph.microsoft.com/v1.0"
+
+	// outlookClockifyPropertyID names the single-value extended property
+	// that tags events with their Clockify request ID, Outlook's equivalent
+	// of the private clockifyRequestId property on Google events.
+	outlookClockifyPropertyID = "String {6d3b1f52-8a4e-4c7b-9f0d-2e5a7c9b1d84} Name clockifyRequestId"
+
+	// graphDateTimeLayout is Graph's dateTimeTimeZone format, a local time
+	// without an offset.
+	graphDateTimeLayout = "2006-01-02T15:04:05"
+)
+
+// OutlookCalendarBackend writes to Microsoft 365 calendars through Microsoft
+// Graph, using an app registration with the Calendars.ReadWrite application
+// permission so that it can act on any user in the tenant.
+//
+// Outlook has no out-of-office event type; events that would be one on
+// Google are shown as "oof" instead, and may be all-day. Decline settings
+// don't apply.
+type OutlookCalendarBackend struct {
+	endpoint   string
+	httpClient *http.Client
+	limiter    *RateLimiter
+}
+
+// NewOutlookCalendarBackend authenticates as the app registration clientID
+// in tenantID with the client credentials flow.
+func NewOutlookCalendarBackend(
+	tenantID, clientID, clientSecret string,
+	opts ...func(*OutlookCalendarBackend),
+) *OutlookCalendarBackend {
+	cfg := clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token",
+		Scopes:       []string{"https://graph.microsoft.com/.default"},
+	}
+
+	b := &OutlookCalendarBackend{
+		endpoint: defaultGraphEndpoint,
+		// The client outlives any one request's context.
+		httpClient: cfg.Client(context.Background()),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.httpClient = rateLimitedClient(b.httpClient, b.limiter)
+	return b
+}
+
+// For testing: talk to endpoint with httpClient rather than the real Graph
+// API.
+func WithOutlookEndpoint(endpoint string, httpClient *http.Client) func(*OutlookCalendarBackend) {
+	return func(b *OutlookCalendarBackend) {
+		b.endpoint = strings.TrimSuffix(endpoint, "/")
+		b.httpClient = httpClient
+	}
+}
+
+// WithOutlookRateLimiter makes every Graph request wait on limiter.
+func WithOutlookRateLimiter(limiter *RateLimiter) func(*OutlookCalendarBackend) {
+	return func(b *OutlookCalendarBackend) {
+		b.limiter = limiter
+	}
+}
+
+// graphEvent is the subset of Graph's event resource the sync reads and
+// writes.
+type graphEvent struct {
+	ID          string          `json:"id,omitempty"`
+	Subject     string          `json:"subject,omitempty"`
+	Body        *graphItemBody  `json:"body,omitempty"`
+	Start       *graphDateTime  `json:"start,omitempty"`
+	End         *graphDateTime  `json:"end,omitempty"`
+	IsAllDay    bool            `json:"isAllDay"`
+	ShowAs      string          `json:"showAs,omitempty"`
+	Sensitivity string          `json:"sensitivity,omitempty"`
+	IsCancelled bool            `json:"isCancelled,omitempty"`
+	Properties  []graphProperty `json:"singleValueExtendedProperties,omitempty"`
+}
+
+type graphItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphProperty struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+type graphEventList struct {
+	Value    []graphEvent `json:"value"`
+	NextLink string       `json:"@odata.nextLink"`
+}
+
+// GraphError is an error response from Microsoft Graph.
+type GraphError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// FindEvents has Graph filter the calendar's events on the extended property
+// carrying the request ID, rather than reading the whole calendar view. The
+// tag alone identifies the request's events, so the window isn't sent.
+func (b *OutlookCalendarBackend) FindEvents(
+	ctx context.Context,
+	userEmail, calendarID, clockifyRequestID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	query := expandClockifyProperty()
+	query.Set("$filter", fmt.Sprintf(
+		"singleValueExtendedProperties/Any(ep: ep/id eq '%s' and ep/value eq '%s')",
+		outlookClockifyPropertyID,
+		strings.ReplaceAll(clockifyRequestID, "'", "''"),
+	))
+
+	return b.listEvents(ctx, calendarPath(userEmail, calendarID)+"/events", query)
+}
+
+func (b *OutlookCalendarBackend) InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error) {
+	var created graphEvent
+	err := b.do(ctx, http.MethodPost, calendarPath(userEmail, calendarID)+"/events", nil, toGraphEvent(ev), &created)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// PatchEvent never returns ErrEventTypeMismatch: showAs can be changed in
+// place.
+func (b *OutlookCalendarBackend) PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error {
+	return b.do(ctx, http.MethodPatch, eventPath(userEmail, eventID), nil, toGraphEvent(ev), nil)
+}
+
+func (b *OutlookCalendarBackend) DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error {
+	return b.do(ctx, http.MethodDelete, eventPath(userEmail, eventID), nil, nil, nil)
+}
+
+func (b *OutlookCalendarBackend) GetEvent(ctx context.Context, userEmail, calendarID, eventID string) (CalendarEvent, error) {
+	var event graphEvent
+	if err := b.do(ctx, http.MethodGet, eventPath(userEmail, eventID), expandClockifyProperty(), nil, &event); err != nil {
+		return CalendarEvent{}, err
+	}
+	if event.IsCancelled {
+		return CalendarEvent{}, fmt.Errorf("%w: event %s is cancelled", ErrEventNotFound, eventID)
+	}
+	return fromGraphEvent(event), nil
+}
+
+// ListEvents reads the calendar view, which expands recurring events, and
+// keeps the events tagged with a Clockify request ID.
+func (b *OutlookCalendarBackend) ListEvents(
+	ctx context.Context,
+	userEmail, calendarID string,
+	timeMin, timeMax time.Time,
+) ([]CalendarEvent, error) {
+	query := expandClockifyProperty()
+	query.Set("startDateTime", timeMin.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", timeMax.UTC().Format(time.RFC3339))
+
+	return b.listEvents(ctx, calendarPath(userEmail, calendarID)+"/calendarView", query)
+}
+
+// listEvents pages through the events at path, keeping those tagged with a
+// Clockify request ID that aren't cancelled.
+func (b *OutlookCalendarBackend) listEvents(ctx context.Context, path string, query url.Values) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+
+	for path != "" {
+		var page graphEventList
+		if err := b.do(ctx, http.MethodGet, path, query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Value {
+			event := fromGraphEvent(item)
+			if event.ClockifyRequestID != "" && !item.IsCancelled {
+				events = append(events, event)
+			}
+		}
+
+		// The next link carries the query itself.
+		path, query = page.NextLink, nil
+	}
+
+	return events, nil
+}
+
+// do sends a Graph request to path, relative to the endpoint unless it is a
+// next link, and decodes the response into out. 404s and 410s are returned
+// as ErrEventNotFound.
+func (b *OutlookCalendarBackend) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	in, out any,
+) error {
+	target := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		target = b.endpoint + path
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode graph request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Event times come back in UTC rather than each event's own zone.
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var payload struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+
+		graphErr := &GraphError{
+			StatusCode: resp.StatusCode,
+			Code:       payload.Error.Code,
+			Message:    payload.Error.Message,
+		}
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			return fmt.Errorf("%w: %v", ErrEventNotFound, graphErr)
+		}
+		return graphErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode graph response: %w", err)
+	}
+	return nil
+}
+
+// calendarPath addresses the user's default calendar for "primary" and one
+// of their other calendars by ID otherwise.
+func calendarPath(userEmail, calendarID string) string {
+	user := "/users/" + url.PathEscape(userEmail)
+	if calendarID == "primary" || calendarID == userEmail {
+		return user + "/calendar"
+	}
+	return user + "/calendars/" + url.PathEscape(calendarID)
+}
+
+func eventPath(userEmail, eventID string) string {
+	return "/users/" + url.PathEscape(userEmail) + "/events/" + url.PathEscape(eventID)
+}
+
+func expandClockifyProperty() url.Values {
+	return url.Values{
+		"$expand": {fmt.Sprintf("singleValueExtendedProperties($filter=id eq '%s')", outlookClockifyPropertyID)},
+	}
+}
+
+func toGraphEvent(ev OOOEvent) graphEvent {
+	// Mirrors the Google event's type, status and transparency, in that
+	// order of precedence, so that matches holds for events read back.
+	showAs := "busy"
+	switch {
+	case ev.OutOfOffice != nil:
+		showAs = "oof"
+	case ev.Tentative:
+		showAs = "tentative"
+	case ev.Transparent:
+		showAs = "free"
+	}
+
+	sensitivity := "normal"
+	switch ev.Visibility {
+	case "private", "confidential":
+		sensitivity = ev.Visibility
+	}
+
+	return graphEvent{
+		Subject:     ev.Summary,
+		Body:        &graphItemBody{ContentType: "text", Content: ev.Description},
+		Start:       toGraphDateTime(ev.Start),
+		End:         toGraphDateTime(ev.End),
+		IsAllDay:    ev.AllDay,
+		ShowAs:      showAs,
+		Sensitivity: sensitivity,
+		Properties: []graphProperty{
+			{ID: outlookClockifyPropertyID, Value: ev.ClockifyRequestID},
+		},
+	}
+}
+
+// toGraphDateTime writes t as a local time in its own zone. All-day events
+// must start and end at midnight in the zone they name.
+func toGraphDateTime(t time.Time) *graphDateTime {
+	return &graphDateTime{
+		DateTime: t.Format(graphDateTimeLayout),
+		TimeZone: t.Location().String(),
+	}
+}
+
+func fromGraphEvent(e graphEvent) CalendarEvent {
+	found := CalendarEvent{
+		ID:          e.ID,
+		AllDay:      e.IsAllDay,
+		OutOfOffice: e.ShowAs == "oof",
+	}
+
+	for _, p := range e.Properties {
+		if strings.EqualFold(p.ID, outlookClockifyPropertyID) {
+			found.ClockifyRequestID = p.Value
+		}
+	}
+
+	found.Start = parseGraphDateTime(e.Start, e.IsAllDay)
+	found.End = parseGraphDateTime(e.End, e.IsAllDay)
+
+	return found
+}
+
+// parseGraphDateTime returns the zero time for missing or unparseable
+// values, which never match a planned event. All-day events only keep their
+// date: the midnight nearest the time in UTC, which is the event's date
+// whether Graph reports the local midnight or its UTC equivalent.
+func parseGraphDateTime(t *graphDateTime, allDay bool) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	// Graph writes seven fractional digits, which the layout accepts.
+	parsed, err := time.ParseInLocation(graphDateTimeLayout, t.DateTime, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	if allDay {
+		y, m, d := parsed.UTC().Add(12 * time.Hour).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return parsed
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutlookCalendarBackend_SyncOOORequest(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGraph(t)
+	backend := fake.backend()
+
+	req := makeRequest("request-123", "America/New_York", "2025-12-10T05:00:00Z", "2025-12-11T04:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	inserted, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: req}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, inserted, 1)
+
+	stored := fake.event(inserted[0].EventID)
+	require.NotNil(t, stored)
+	assert.Equal(t, "OOO — Vacation", stored["subject"])
+	assert.Equal(t, true, stored["isAllDay"])
+	assert.Equal(t, "oof", stored["showAs"])
+	assert.Equal(t, "normal", stored["sensitivity"])
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-10T00:00:00",
+		"timeZone": "America/New_York",
+	}, stored["start"])
+	assert.Equal(t, map[string]any{
+		"dateTime": "2025-12-11T00:00:00",
+		"timeZone": "America/New_York",
+	}, stored["end"])
+	assert.Equal(t, []any{map[string]any{
+		"id":    outlookClockifyPropertyID,
+		"value": "request-123",
+	}}, stored["singleValueExtendedProperties"])
+
+	// Re-inserting finds the existing event instead of duplicating it.
+	found, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, found)
+	assert.Equal(t, 1, fake.count(req.UserEmail, "default"))
+
+	// Moving the request patches the recorded event in place.
+	existing := &SyncedClockifyRequest{ClockifyRequestID: req.ID, GoogleCalendarEvents: inserted}
+	moved := req
+	moved.TimeOffPeriod.Period.Start = "2025-12-15T05:00:00Z"
+	moved.TimeOffPeriod.Period.End = "2025-12-17T04:59:59Z"
+
+	updated, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: moved, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, inserted, updated)
+
+	stored = fake.event(inserted[0].EventID)
+	assert.Equal(t, "2025-12-15T00:00:00", stored["start"].(map[string]any)["dateTime"])
+	assert.Equal(t, "2025-12-17T00:00:00", stored["end"].(map[string]any)["dateTime"])
+
+	// Rejecting it deletes the event; deleting it again is not an error.
+	rejected := moved
+	rejected.Status.StatusType = ClockifyStatusRejected
+	existing.GoogleCalendarEvents = updated
+
+	remaining, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: rejected, ExistingRecord: existing}, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Zero(t, fake.count(req.UserEmail, "default"))
+
+	require.NoError(t, DeleteOOOEvents(ctx, backend, req.UserEmail, updated))
+}
+
+func TestOutlookCalendarBackend_ListAndGetEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGraph(t)
+	backend := fake.backend()
+	userEmail := "user@contractor.example"
+
+	var ids []string
+	for i, day := range []int{1, 2, 3, 20} {
+		ev := OOOEvent{
+			ClockifyRequestID: "request-" + string(rune('a'+i)),
+			Summary:           "OOO",
+			Visibility:        "private",
+			Start:             time.Date(2025, 12, day, 0, 0, 0, 0, time.UTC),
+			End:               time.Date(2025, 12, day+1, 0, 0, 0, 0, time.UTC),
+			AllDay:            true,
+			Transparent:       true,
+		}
+		id, err := backend.InsertEvent(ctx, userEmail, "team-calendar", ev)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.Equal(t, "private", fake.event(ids[0])["sensitivity"])
+	assert.Equal(t, "free", fake.event(ids[0])["showAs"])
+	assert.Equal(t, 4, fake.count(userEmail, "team-calendar"))
+
+	// The first three events span two pages of the calendar view.
+	events, err := backend.ListEvents(
+		ctx,
+		userEmail, "team-calendar",
+		time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for _, event := range events {
+		assert.True(t, event.AllDay)
+		assert.False(t, event.OutOfOffice)
+	}
+
+	got, err := backend.GetEvent(ctx, userEmail, "team-calendar", ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, CalendarEvent{
+		ID:                ids[1],
+		ClockifyRequestID: "request-b",
+		Start:             time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC),
+		End:               time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC),
+		AllDay:            true,
+	}, got)
+
+	_, err = backend.GetEvent(ctx, userEmail, "team-calendar", "missing")
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+	err = backend.DeleteEvent(ctx, userEmail, "team-calendar", "missing")
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+}
+
+func TestOutlookCalendarBackend_FindEventsFiltersOnTheServer(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGraph(t)
+	backend := fake.backend()
+	userEmail := "user@contractor.example"
+
+	ids := make(map[string]string)
+	for _, requestID := range []string{"request-a", "request-b", "o'brien"} {
+		ev := OOOEvent{
+			ClockifyRequestID: requestID,
+			Summary:           "OOO",
+			Start:             time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+			End:               time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC),
+			AllDay:            true,
+		}
+		id, err := backend.InsertEvent(ctx, userEmail, "team-calendar", ev)
+		require.NoError(t, err)
+		ids[requestID] = id
+	}
+
+	// The fake only lists events through the property filter, so these are
+	// found without reading the calendar.
+	for requestID, id := range ids {
+		events, err := backend.FindEvents(
+			ctx,
+			userEmail, "team-calendar", requestID,
+			time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC),
+		)
+		require.NoError(t, err)
+		require.Len(t, events, 1, requestID)
+		assert.Equal(t, id, events[0].ID)
+		assert.Equal(t, requestID, events[0].ClockifyRequestID)
+	}
+
+	events, err := backend.FindEvents(ctx, userEmail, "default", "request-a", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, events, "other calendars aren't searched")
+}
+
+func TestCalendarBackends_RoutesByUserAndDomain(t *testing.T) {
+	ctx := context.Background()
+	google := newFakeGoogleCalendar(t)
+	outlook := newFakeGraph(t)
+
+	backend := &CalendarBackends{
+		Default: google.backend(),
+		Domains: map[string]CalendarBackend{"contractor.example": outlook.backend()},
+		Users:   map[string]CalendarBackend{"m365@example.com": outlook.backend()},
+	}
+
+	for _, email := range []string{"employee@example.com", "Someone@Contractor.example", "M365@example.com"} {
+		req := makeRequest("request-"+email, "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+		req.UserEmail = email
+		req.Status.StatusType = ClockifyStatusApproved
+
+		_, err := SyncOOORequest(ctx, backend, RequestToProcess{Request: req}, UserCalendars("primary"), SyncOptions{})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, google.count("primary"))
+	assert.Equal(t, 1, outlook.count("Someone@Contractor.example", "default"))
+	assert.Equal(t, 1, outlook.count("M365@example.com", "default"))
+}
@@ -8,22 +8,66 @@ import (
 type GoogleCalendarEvent struct {
 	CalendarID string `json:"calendarId" dynamodbav:"CalendarId"`
 	EventID    string `json:"eventId" dynamodbav:"EventId"`
+	// Owner is the account the event was written as when it is on a shared
+	// calendar rather than the user's own.
+	Owner string `json:"owner,omitempty" dynamodbav:"Owner,omitempty"`
 }
 
+// target returns the calendar the event is on.
+func (e GoogleCalendarEvent) target() CalendarTarget {
+	return CalendarTarget{CalendarID: e.CalendarID, Owner: e.Owner}
+}
+
+// SyncState values.
+const (
+	SyncStatePending = "pending"
+	SyncStateSynced  = "synced"
+	// SyncStateFailed requests are retried once their NextRetryAt passes.
+	SyncStateFailed = "failed"
+	// SyncStateDeadLetter requests used up their attempts and are left
+	// alone until they change in Clockify.
+	SyncStateDeadLetter = "dead_letter"
+)
+
 type SyncedClockifyRequest struct {
 	ClockifyRequestID string `json:"clockifyRequestId" dynamodbav:"ClockifyRequestId"`
 	UserID            string `json:"userId" dynamodbav:"UserId"`
 	UserEmail         string `json:"userEmail" dynamodbav:"UserEmail"`
 	Status            string `json:"status" dynamodbav:"Status"`
+	// StatusChangedAt is when Clockify says Status was set, used to ignore
+	// webhooks delivered out of order.
+	StatusChangedAt string `json:"statusChangedAt,omitempty" dynamodbav:"StatusChangedAt,omitempty"`
 
 	PeriodStart string `json:"periodStart" dynamodbav:"PeriodStart"`
 	PeriodEnd   string `json:"periodEnd" dynamodbav:"PeriodEnd"`
+	PolicyName  string `json:"policyName" dynamodbav:"PolicyName"`
+
+	// The rest of what planning an event needs, so that the events a record
+	// should have can be worked out again without Clockify.
+	UserTimeZone  string `json:"userTimeZone,omitempty" dynamodbav:"UserTimeZone,omitempty"`
+	TimeUnit      string `json:"timeUnit,omitempty" dynamodbav:"TimeUnit,omitempty"`
+	HalfDay       bool   `json:"halfDay,omitempty" dynamodbav:"HalfDay,omitempty"`
+	HalfDayPeriod string `json:"halfDayPeriod,omitempty" dynamodbav:"HalfDayPeriod,omitempty"`
+	HalfDayStart  string `json:"halfDayStart,omitempty" dynamodbav:"HalfDayStart,omitempty"`
+	HalfDayEnd    string `json:"halfDayEnd,omitempty" dynamodbav:"HalfDayEnd,omitempty"`
 
 	CreatedAt  string `json:"createdAt" dynamodbav:"CreatedAt"`
 	LastSeenAt string `json:"lastSeenAt" dynamodbav:"LastSeenAt"`
 	SyncState  string `json:"syncState" dynamodbav:"SyncState"`
 
+	// Attempts counts the failed attempts to sync the request as it now is,
+	// LastError is the latest failure, and NextRetryAt is when a failed
+	// request is retried, RFC 3339.
+	Attempts    int    `json:"attempts,omitempty" dynamodbav:"Attempts,omitempty"`
+	LastError   string `json:"lastError,omitempty" dynamodbav:"LastError,omitempty"`
+	NextRetryAt string `json:"nextRetryAt,omitempty" dynamodbav:"NextRetryAt,omitempty"`
+
 	GoogleCalendarEvents []GoogleCalendarEvent `json:"googleCalendarEvents,omitempty" dynamodbav:"GoogleCalendarEvents,omitempty"`
+	// SlackStatus is the Slack status set for the request, while it is set.
+	SlackStatus *SlackStatus `json:"slackStatus,omitempty" dynamodbav:"SlackStatus,omitempty"`
+
+	// Version counts the writes to the record; see PutSyncedRequest.
+	Version int64 `json:"version" dynamodbav:"Version"`
 }
 
 // ToDynamoItem converts a Clockify request into the persistence model
@@ -40,11 +84,20 @@ func (r *ClockifyRequest) ToDynamoItem(options ...func(*SyncedClockifyRequest))
 	item := &SyncedClockifyRequest{
 		ClockifyRequestID: r.ID,
 		Status:            r.Status.StatusType,
-		SyncState:         "pending",
+		StatusChangedAt:   r.Status.ChangedAt,
+		SyncState:         SyncStatePending,
 		PeriodStart:       r.TimeOffPeriod.Period.Start,
 		PeriodEnd:         r.TimeOffPeriod.Period.End,
+		PolicyName:        r.PolicyName,
 		CreatedAt:         r.CreatedAt,
+		UserID:            r.UserID,
 		UserEmail:         r.UserEmail,
+		UserTimeZone:      r.UserTimeZone,
+		TimeUnit:          r.TimeUnit,
+		HalfDay:           r.TimeOffPeriod.HalfDay,
+		HalfDayPeriod:     r.TimeOffPeriod.HalfDayPeriod,
+		HalfDayStart:      r.TimeOffPeriod.HalfDayHours.Start,
+		HalfDayEnd:        r.TimeOffPeriod.HalfDayHours.End,
 	}
 
 	for _, opt := range options {
@@ -58,6 +111,77 @@ func (r *ClockifyRequest) ToDynamoItem(options ...func(*SyncedClockifyRequest))
 	return item, nil
 }
 
+// plannable reports whether the record holds what planning its events needs.
+// Records from before the time zone was stored don't, and planning them
+// would silently place their days in UTC.
+func (s *SyncedClockifyRequest) plannable() bool {
+	return s.UserTimeZone != "" && s.PeriodStart != "" && s.PeriodEnd != ""
+}
+
+// clockifyRequest rebuilds the request a record was synced from, as far as
+// the record knows it.
+func (s *SyncedClockifyRequest) clockifyRequest() ClockifyRequest {
+	var r ClockifyRequest
+
+	r.ID = s.ClockifyRequestID
+	r.CreatedAt = s.CreatedAt
+	r.PolicyName = s.PolicyName
+	r.UserID = s.UserID
+	r.UserEmail = s.UserEmail
+	r.UserTimeZone = s.UserTimeZone
+	r.TimeUnit = s.TimeUnit
+
+	r.TimeOffPeriod.Period.Start = s.PeriodStart
+	r.TimeOffPeriod.Period.End = s.PeriodEnd
+	r.TimeOffPeriod.HalfDay = s.HalfDay
+	r.TimeOffPeriod.HalfDayPeriod = s.HalfDayPeriod
+	r.TimeOffPeriod.HalfDayHours.Start = s.HalfDayStart
+	r.TimeOffPeriod.HalfDayHours.End = s.HalfDayEnd
+
+	r.Status.StatusType = s.Status
+	r.Status.ChangedAt = s.StatusChangedAt
+
+	return r
+}
+
+// statusNewerThan reports whether the synced status was set after r's, so
+// that r is a stale copy of the request. Unknown times are never newer.
+func (s *SyncedClockifyRequest) statusNewerThan(r ClockifyRequest) bool {
+	synced, err := ParseTimeAny(s.StatusChangedAt)
+	if err != nil {
+		return false
+	}
+	changed, err := ParseTimeAny(r.Status.ChangedAt)
+	if err != nil {
+		return false
+	}
+	return synced.After(changed)
+}
+
+// DetailsChanged reports whether the request's time-off period or policy
+// differs from what was last synced, meaning its calendar events need to be
+// moved or reworded even though the status is unchanged. Records written
+// before the policy was stored are only compared on their period.
+func (s *SyncedClockifyRequest) DetailsChanged(r ClockifyRequest) bool {
+	if !sameTimestamp(s.PeriodStart, r.TimeOffPeriod.Period.Start) ||
+		!sameTimestamp(s.PeriodEnd, r.TimeOffPeriod.Period.End) {
+		return true
+	}
+
+	return s.PolicyName != "" && s.PolicyName != r.PolicyName
+}
+
+// sameTimestamp compares two timestamps by instant when both parse, so that
+// formatting differences alone are not treated as a change.
+func sameTimestamp(a, b string) bool {
+	ta, errA := ParseTimeAny(a)
+	tb, errB := ParseTimeAny(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ta.Equal(tb)
+}
+
 func WithLastSeenAt(now time.Time) func(*SyncedClockifyRequest) {
 	return func(item *SyncedClockifyRequest) {
 		item.LastSeenAt = now.UTC().Format(time.RFC3339)
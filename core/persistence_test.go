@@ -34,9 +34,17 @@ func TestToDynamoItem(t *testing.T) {
 	assert.Equal(t, ClockifyStatusApproved, item.Status)
 	assert.Equal(t, "2026-06-10T00:00:00Z", item.PeriodStart)
 	assert.Equal(t, "2026-06-12T00:00:00Z", item.PeriodEnd)
+	assert.Equal(t, "Vacation", item.PolicyName)
 	assert.Equal(t, "2026-06-08T10:00:00Z", item.CreatedAt)
 	assert.Equal(t, "2026-06-08T12:00:00Z", item.LastSeenAt)
 	assert.Equal(t, "pending", item.SyncState)
+	assert.Equal(t, "America/New_York", item.UserTimeZone)
+
+	rebuilt := item.clockifyRequest()
+	assert.Equal(t, req.ID, rebuilt.ID)
+	assert.Equal(t, req.UserTimeZone, rebuilt.UserTimeZone)
+	assert.Equal(t, req.TimeOffPeriod, rebuilt.TimeOffPeriod)
+	assert.Equal(t, req.Status.StatusType, rebuilt.Status.StatusType)
 }
 
 func TestToDynamoItemReturnsErrorWhenRequestIDMissing(t *testing.T) {
@@ -50,3 +58,28 @@ func TestToDynamoItemReturnsErrorWhenRequestIDMissing(t *testing.T) {
 	assert.Nil(t, item)
 	assert.Contains(t, err.Error(), "missing Clockify request ID")
 }
+
+func TestDetailsChanged(t *testing.T) {
+	req := makeRequest("request-123", "America/New_York", "2026-06-10T00:00:00Z", "2026-06-12T23:59:59Z")
+
+	item, err := req.ToDynamoItem()
+	require.NoError(t, err)
+
+	assert.False(t, item.DetailsChanged(req))
+
+	reformatted := req
+	reformatted.TimeOffPeriod.Period.Start = "2026-06-10T00:00:00.000000Z"
+	assert.False(t, item.DetailsChanged(reformatted))
+
+	moved := req
+	moved.TimeOffPeriod.Period.End = "2026-06-13T23:59:59Z"
+	assert.True(t, item.DetailsChanged(moved))
+
+	repoliced := req
+	repoliced.PolicyName = "Sick leave"
+	assert.True(t, item.DetailsChanged(repoliced))
+
+	legacy := *item
+	legacy.PolicyName = ""
+	assert.False(t, legacy.DetailsChanged(repoliced))
+}
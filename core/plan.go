@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CalendarChange is one write a dry run would have made to a calendar.
+type CalendarChange struct {
+	Action     SyncAction `json:"action"`
+	CalendarID string     `json:"calendarId"`
+	EventID    string     `json:"eventId,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+	Start      time.Time  `json:"start,omitzero"`
+	End        time.Time  `json:"end,omitzero"`
+}
+
+// DryRunSink plans requests like BackendCalendarSink, looking up existing
+// events through Backend, but records the inserts, updates and deletes it
+// would make instead of making them.
+type DryRunSink struct {
+	Backend   CalendarBackend
+	Calendars CalendarRouting
+
+	mu      sync.Mutex
+	changes map[string][]CalendarChange
+}
+
+func (s *DryRunSink) SyncOOORequest(
+	ctx context.Context,
+	req RequestToProcess,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	recorder := &recordingBackend{CalendarBackend: s.Backend}
+
+	events, err := SyncOOORequest(ctx, recorder, req, s.Calendars.targetsFor(req.Request), opts)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changes == nil {
+		s.changes = make(map[string][]CalendarChange)
+	}
+	s.changes[req.Request.ID] = recorder.changes
+
+	return events, err
+}
+
+// Changes returns the changes planned for a request.
+func (s *DryRunSink) Changes(clockifyRequestID string) []CalendarChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changes[clockifyRequestID]
+}
+
+// recordingBackend passes lookups through to the wrapped backend and records
+// writes. Patches look the event up first and fail like the wrapped backend
+// would for events that are gone or can't change type in place, so the plan
+// shows the re-creations and replacements a real sync would make. It is used
+// for one request at a time.
+type recordingBackend struct {
+	CalendarBackend
+	changes []CalendarChange
+}
+
+func (b *recordingBackend) InsertEvent(ctx context.Context, userEmail, calendarID string, ev OOOEvent) (string, error) {
+	b.changes = append(b.changes, CalendarChange{
+		Action:     SyncActionInsert,
+		CalendarID: calendarID,
+		Summary:    ev.Summary,
+		Start:      ev.Start,
+		End:        ev.End,
+	})
+	return "", nil
+}
+
+func (b *recordingBackend) PatchEvent(ctx context.Context, userEmail, calendarID, eventID string, ev OOOEvent) error {
+	current, err := b.CalendarBackend.GetEvent(ctx, userEmail, calendarID, eventID)
+	if err != nil {
+		return err
+	}
+	if patcher, ok := b.CalendarBackend.(inPlacePatcher); ok && !patcher.patchesInPlace(userEmail, current, ev) {
+		return fmt.Errorf("%w: event %s", ErrEventTypeMismatch, eventID)
+	}
+
+	b.changes = append(b.changes, CalendarChange{
+		Action:     SyncActionUpdate,
+		CalendarID: calendarID,
+		EventID:    eventID,
+		Summary:    ev.Summary,
+		Start:      ev.Start,
+		End:        ev.End,
+	})
+	return nil
+}
+
+func (b *recordingBackend) DeleteEvent(ctx context.Context, userEmail, calendarID, eventID string) error {
+	b.changes = append(b.changes, CalendarChange{
+		Action:     SyncActionDelete,
+		CalendarID: calendarID,
+		EventID:    eventID,
+	})
+	return nil
+}
+
+// WritePlan writes a dry run's report in human-readable form, one line per
+// request followed by its calendar changes.
+func (r Report) WritePlan(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("Plan: %d requests to sync, %d to skip, %d failed to plan\n", r.Queued, r.Skipped, r.Failed)
+
+	for _, result := range r.Results {
+		ew.printf("%s %s (%s, %s)", result.Action, result.RequestID, result.UserEmail, result.Status)
+		switch {
+		case result.Error != "":
+			ew.printf(": error: %s", result.Error)
+		case result.Reason != "":
+			ew.printf(": %s", result.Reason)
+		}
+		ew.printf("\n")
+
+		for _, change := range result.Changes {
+			ew.printf("    %s %s", change.Action, change.CalendarID)
+			if change.EventID != "" {
+				ew.printf(" event %s", change.EventID)
+			}
+			if change.Summary != "" {
+				ew.printf(" %q", change.Summary)
+			}
+			if !change.Start.IsZero() {
+				ew.printf(" %s → %s", change.Start.Format(time.RFC3339), change.End.Format(time.RFC3339))
+			}
+			ew.printf("\n")
+		}
+	}
+
+	return ew.err
+}
+
+// errWriter keeps the first write error so a run of prints can be checked
+// once.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncer_DryRunPlansWithoutWriting(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 0, 0, 0, 0, time.UTC)
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+
+	// "moved" was synced before and its event still exists.
+	moved := makeStatusRequest("moved", ClockifyStatusApproved, inWindow)
+	movedEvents, err := InsertOOOEvents(ctx, backend, moved, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+	movedItem, err := moved.ToDynamoItem()
+	require.NoError(t, err)
+	movedItem.GoogleCalendarEvents = movedEvents
+	require.NoError(t, store.PutSyncedRequest(ctx, movedItem))
+	moved.TimeOffPeriod.Period.Start = "2025-12-09T00:00:00Z"
+
+	unchanged := makeStatusRequest("unchanged", ClockifyStatusApproved, inWindow)
+	unchangedItem, err := unchanged.ToDynamoItem()
+	require.NoError(t, err)
+	require.NoError(t, store.PutSyncedRequest(ctx, unchangedItem))
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("new", ClockifyStatusApproved, inWindow),
+		moved,
+		unchanged,
+	}}
+	sink := &DryRunSink{Backend: backend}
+	syncer := NewSyncer("ws", source, sink, store, WithClock(func() time.Time { return now }), WithDryRun())
+
+	report, err := syncer.SyncSinceWatermark(ctx, SyncWindow{})
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Queued)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 1, report.Updated)
+
+	require.Len(t, report.Results, 3)
+	assert.Equal(t, SyncActionSkip, report.Results[0].Action)
+	assert.Equal(t, "unchanged", report.Results[0].RequestID)
+
+	assert.Equal(t, SyncActionInsert, report.Results[1].Action)
+	require.Len(t, report.Results[1].Changes, 1)
+	assert.Equal(t, SyncActionInsert, report.Results[1].Changes[0].Action)
+	assert.Equal(t, "primary", report.Results[1].Changes[0].CalendarID)
+
+	assert.Equal(t, SyncActionUpdate, report.Results[2].Action)
+	require.Len(t, report.Results[2].Changes, 1)
+	assert.Equal(t, movedEvents[0].EventID, report.Results[2].Changes[0].EventID)
+
+	// Nothing was written anywhere.
+	assert.Equal(t, 1, fake.count("primary"))
+	assert.Equal(t, "2025-12-10T00:00:00Z", mustGetSyncedRequest(t, store, "moved").PeriodStart)
+	assert.Nil(t, mustGetSyncedRequest(t, store, "new"))
+	mark, err := store.GetWatermark(ctx, "activity/ws")
+	require.NoError(t, err)
+	assert.True(t, mark.IsZero())
+
+	var plan strings.Builder
+	require.NoError(t, report.WritePlan(&plan))
+	assert.Contains(t, plan.String(), "Plan: 2 requests to sync, 1 to skip, 0 failed to plan")
+	assert.Contains(t, plan.String(), "skip unchanged (fixture@example.com, APPROVED): status APPROVED has already been processed")
+	assert.Contains(t, plan.String(), "    insert primary \"OOO — Vacation\"")
+	assert.Contains(t, plan.String(), "    update primary event "+movedEvents[0].EventID)
+}
+
+func TestSyncer_DryRunPlansReplacementsAndRecreations(t *testing.T) {
+	ctx := context.Background()
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+	opts := SyncOptions{PendingPlaceholders: true}
+
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+
+	record := func(r ClockifyRequest) []GoogleCalendarEvent {
+		events, err := InsertOOOEvents(ctx, backend, r, UserCalendars("primary"), opts)
+		require.NoError(t, err)
+		item, err := r.ToDynamoItem()
+		require.NoError(t, err)
+		item.GoogleCalendarEvents = events
+		require.NoError(t, store.PutSyncedRequest(ctx, item))
+		return events
+	}
+
+	// A tentative placeholder can't become an out-of-office event in place.
+	approved := makeStatusRequest("approved", ClockifyStatusPending, inWindow)
+	placeholder := record(approved)
+	approved.Status.StatusType = ClockifyStatusApproved
+	approved.Status.ChangedAt = "2025-12-03T00:00:00Z"
+
+	// An event deleted by hand is recreated.
+	moved := makeStatusRequest("moved", ClockifyStatusApproved, inWindow)
+	gone := record(moved)
+	require.NoError(t, backend.DeleteEvent(ctx, moved.UserEmail, "primary", gone[0].EventID))
+	moved.TimeOffPeriod.Period.Start = "2025-12-09T00:00:00Z"
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{approved, moved}}
+	sink := &DryRunSink{Backend: backend}
+	syncer := NewSyncer("ws", source, sink, store, WithSyncOptions(opts), WithDryRun())
+
+	report, err := syncer.Sync(ctx, SyncWindow{})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+
+	changes := map[string][]CalendarChange{}
+	for _, result := range report.Results {
+		changes[result.RequestID] = result.Changes
+	}
+
+	require.Len(t, changes["approved"], 2)
+	assert.Equal(t, SyncActionDelete, changes["approved"][0].Action)
+	assert.Equal(t, placeholder[0].EventID, changes["approved"][0].EventID)
+	assert.Equal(t, SyncActionInsert, changes["approved"][1].Action)
+
+	require.Len(t, changes["moved"], 1)
+	assert.Equal(t, SyncActionInsert, changes["moved"][0].Action)
+
+	// Nothing was written.
+	assert.NotNil(t, fake.event("primary", placeholder[0].EventID))
+	assert.Equal(t, 1, fake.count("primary"))
+}
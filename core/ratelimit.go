@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out calls to at most one per interval on average,
+// allowing bursts of up to burst calls. It is safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	// tat is the theoretical arrival time of the next call were every call
+	// spaced exactly interval apart.
+	tat time.Time
+}
+
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    burst,
+	}
+}
+
+// Wait blocks until a call is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	now := time.Now()
+
+	l.mu.Lock()
+	if l.tat.Before(now) {
+		l.tat = now
+	}
+	allowAt := l.tat.Add(-time.Duration(l.burst-1) * l.interval)
+	l.tat = l.tat.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := allowAt.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedTransport waits on a shared limiter before every request.
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// rateLimitedClient returns a copy of client whose requests wait on limiter,
+// or client itself when limiter is nil.
+func rateLimitedClient(client *http.Client, limiter *RateLimiter) *http.Client {
+	if limiter == nil {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	limited := *client
+	limited.Transport = &rateLimitedTransport{base: base, limiter: limiter}
+	return &limited
+}
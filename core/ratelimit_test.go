@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_AllowsBurstThenSpacesCalls(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewRateLimiter(50, 3) // one call per 20ms
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, limiter.Wait(ctx))
+	}
+	assert.Less(t, time.Since(start), 15*time.Millisecond)
+
+	for range 3 {
+		require.NoError(t, limiter.Wait(ctx))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond)
+}
+
+func TestRateLimiter_WaitReturnsWhenContextIsDone(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1) // one call per 10s
+	require.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := limiter.Wait(ctx)
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGoogleCalendarBackend_SharesRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := NewGoogleCalendarBackend(
+		fake.backend().jwtCfg,
+		WithGoogleCalendarEndpoint(fake.URL+"/", fake.Client()),
+		WithGoogleRateLimiter(NewRateLimiter(50, 1)),
+	)
+
+	start := time.Now()
+	for range 4 {
+		_, err := backend.ListEvents(ctx, "person@example.com", "primary", time.Time{}, time.Now())
+		require.NoError(t, err)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond)
+}
@@ -0,0 +1,490 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DriftKind classifies a difference between the sync records and the
+// calendars.
+type DriftKind string
+
+const (
+	// DriftMissingEvent is an event a record should have that is gone from
+	// its calendar, or was never recorded for one of the sync's calendars.
+	DriftMissingEvent DriftKind = "missing_event"
+	// DriftWrongEvent is a recorded event whose dates or type no longer match
+	// the record.
+	DriftWrongEvent DriftKind = "wrong_event"
+	// DriftStaleEvent is a recorded event that still exists although the
+	// request's status means it should have been deleted, or on a calendar
+	// the request is no longer routed to.
+	DriftStaleEvent DriftKind = "stale_event"
+	// DriftOrphanedEvent is an event tagged with a Clockify request ID that
+	// no record refers to. Repairing adopts it into a new record when its
+	// request still wants it, e.g. because storing the record failed after
+	// the insert, and deletes it otherwise.
+	DriftOrphanedEvent DriftKind = "orphaned_event"
+)
+
+// Drift is one difference found by a Reconciler.
+type Drift struct {
+	Kind       DriftKind `json:"kind"`
+	RequestID  string    `json:"requestId"`
+	UserEmail  string    `json:"userEmail"`
+	CalendarID string    `json:"calendarId"`
+	Owner      string    `json:"owner,omitempty"`
+	EventID    string    `json:"eventId,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+
+	Repaired bool   `json:"repaired,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReconcileReport summarizes a reconcile run.
+type ReconcileReport struct {
+	Records  int     `json:"records"`
+	Drifted  int     `json:"drifted"`
+	Repaired int     `json:"repaired"`
+	Failed   int     `json:"failed"`
+	Drift    []Drift `json:"drift,omitempty"`
+}
+
+// Reconciler compares the sync records with the calendars they describe and,
+// when Repair is set, brings both sides back in line.
+type Reconciler struct {
+	Backend   CalendarBackend
+	Store     SyncStateStore
+	Calendars CalendarRouting
+	Options   SyncOptions
+
+	// Users are searched for orphaned events in addition to every user with
+	// a sync record.
+	Users  []string
+	Repair bool
+
+	// Source looks up the Clockify requests of orphaned events before they
+	// are repaired. Without it orphans are only reported.
+	Source      ClockifySource
+	WorkspaceID string
+}
+
+func NewReconciler(
+	backend CalendarBackend,
+	store SyncStateStore,
+	calendars CalendarRouting,
+	opts ...func(*Reconciler),
+) *Reconciler {
+	r := &Reconciler{
+		Backend:   backend,
+		Store:     store,
+		Calendars: calendars,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func WithRepair() func(*Reconciler) {
+	return func(r *Reconciler) {
+		r.Repair = true
+	}
+}
+
+func WithReconcileUsers(userEmails ...string) func(*Reconciler) {
+	return func(r *Reconciler) {
+		r.Users = userEmails
+	}
+}
+
+func WithReconcileSource(workspaceID string, source ClockifySource) func(*Reconciler) {
+	return func(r *Reconciler) {
+		r.WorkspaceID = workspaceID
+		r.Source = source
+	}
+}
+
+func WithReconcileOptions(opts SyncOptions) func(*Reconciler) {
+	return func(r *Reconciler) {
+		r.Options = opts
+	}
+}
+
+// Reconcile checks every sync record against the calendars, then looks for
+// orphaned events overlapping [timeMin, timeMax). Records are checked and
+// repaired before the orphan search so that events a repair adopts are not
+// reported as orphans.
+func (r *Reconciler) Reconcile(ctx context.Context, timeMin, timeMax time.Time) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	records, err := r.Store.ListSyncedRequests(ctx)
+	if err != nil {
+		return report, fmt.Errorf("list synced requests: %w", err)
+	}
+	report.Records = len(records)
+
+	var errs []error
+
+	for _, rec := range records {
+		drift, err := r.reconcileRecord(ctx, rec)
+		report.add(drift)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	drift, err := r.findOrphans(ctx, records, timeMin, timeMax)
+	report.add(drift)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return report, errors.Join(errs...)
+}
+
+func (r *ReconcileReport) add(drift []Drift) {
+	for _, d := range drift {
+		r.Drifted++
+		if d.Repaired {
+			r.Repaired++
+		}
+		if d.Error != "" {
+			r.Failed++
+		}
+	}
+	r.Drift = append(r.Drift, drift...)
+}
+
+// reconcileRecord checks the events of one record and repairs the record and
+// its events together.
+func (r *Reconciler) reconcileRecord(ctx context.Context, rec *SyncedClockifyRequest) ([]Drift, error) {
+	req := rec.clockifyRequest()
+
+	action, err := PlanSyncAction(RequestToProcess{Request: req}, r.Options)
+	if err != nil {
+		return nil, fmt.Errorf("plan record %s: %w", rec.ClockifyRequestID, err)
+	}
+	wantEvents := action == SyncActionInsert
+
+	// Records from before the time zone was stored can only be checked for
+	// existence, and aren't repaired while they want events: they would be
+	// planned in UTC.
+	plannable := rec.plannable()
+	planned, planErr := planOOOEvent(req, r.Options.Events)
+	checkDates := plannable && planErr == nil
+
+	var drift []Drift
+	newDrift := func(kind DriftKind, target CalendarTarget, eventID, detail string) {
+		drift = append(drift, Drift{
+			Kind:       kind,
+			RequestID:  rec.ClockifyRequestID,
+			UserEmail:  rec.UserEmail,
+			CalendarID: target.CalendarID,
+			Owner:      target.Owner,
+			EventID:    eventID,
+			Detail:     detail,
+		})
+	}
+
+	routed := make(map[CalendarTarget]bool)
+	for _, target := range r.Calendars.targetsFor(req) {
+		routed[target] = true
+	}
+	recorded := make(map[CalendarTarget]bool)
+
+	for _, event := range rec.GoogleCalendarEvents {
+		target := event.target()
+		recorded[target] = true
+
+		found, err := r.Backend.GetEvent(ctx, target.account(rec.UserEmail), event.CalendarID, event.EventID)
+		switch {
+		case errors.Is(err, ErrEventNotFound):
+			if wantEvents {
+				newDrift(DriftMissingEvent, target, event.EventID, "recorded event no longer exists")
+			}
+		case err != nil:
+			return drift, fmt.Errorf("get event %s for record %s: %w", event.EventID, rec.ClockifyRequestID, err)
+		case !wantEvents:
+			newDrift(DriftStaleEvent, target, event.EventID, fmt.Sprintf("event still exists for a %s request", rec.Status))
+		case !routed[target]:
+			newDrift(DriftStaleEvent, target, event.EventID, "the request is no longer routed to this calendar")
+		case checkDates && !planned.forTarget(rec.UserEmail, target).matches(found):
+			newDrift(DriftWrongEvent, target, event.EventID, fmt.Sprintf(
+				"event covers %s → %s, record wants %s → %s",
+				found.Start.Format(time.RFC3339),
+				found.End.Format(time.RFC3339),
+				planned.Start.Format(time.RFC3339),
+				planned.End.Format(time.RFC3339),
+			))
+		}
+	}
+
+	if wantEvents {
+		for _, target := range r.Calendars.targetsFor(req) {
+			if !recorded[target] {
+				newDrift(DriftMissingEvent, target, "", "no event recorded for this calendar")
+			}
+		}
+	}
+
+	if len(drift) == 0 || !r.Repair {
+		return drift, nil
+	}
+	if wantEvents && !plannable {
+		log.Printf("Not repairing Clockify request %s: its record has no time zone to plan events in", rec.ClockifyRequestID)
+		for i := range drift {
+			drift[i].Detail += "; not repaired: the record has no time zone to plan events in"
+		}
+		return drift, nil
+	}
+
+	err = r.repairRecord(ctx, rec, req, wantEvents)
+	for i := range drift {
+		if err != nil {
+			drift[i].Error = err.Error()
+		} else {
+			drift[i].Repaired = true
+		}
+	}
+	if err != nil {
+		return drift, fmt.Errorf("repair record %s: %w", rec.ClockifyRequestID, err)
+	}
+
+	log.Printf("Repaired %d drifted events for Clockify request %s", len(drift), rec.ClockifyRequestID)
+
+	return drift, nil
+}
+
+// repairRecord resyncs a record's events the way a sync would and stores the
+// events that represent it afterwards.
+func (r *Reconciler) repairRecord(
+	ctx context.Context,
+	rec *SyncedClockifyRequest,
+	req ClockifyRequest,
+	wantEvents bool,
+) error {
+	var events []GoogleCalendarEvent
+	var err error
+
+	if wantEvents {
+		// Rerouting recreates missing events, moves wrong ones and deletes
+		// unrouted ones; its insert adopts any existing event before
+		// creating one.
+		events, err = rerouteOOOEvents(ctx, r.Backend, req, rec.GoogleCalendarEvents, r.Calendars.targetsFor(req), r.Options)
+	} else {
+		err = DeleteOOOEvents(ctx, r.Backend, rec.UserEmail, rec.GoogleCalendarEvents)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	repaired := *rec
+	repaired.GoogleCalendarEvents = events
+	if err := r.Store.PutSyncedRequest(ctx, &repaired); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	*rec = repaired
+
+	return nil
+}
+
+// findOrphans lists the tagged events on each user's calendars, and on the
+// shared calendars, and reports those no record refers to. Repairing hands
+// them to repairOrphans.
+func (r *Reconciler) findOrphans(
+	ctx context.Context,
+	records []*SyncedClockifyRequest,
+	timeMin, timeMax time.Time,
+) ([]Drift, error) {
+	known := make(map[string]bool)
+	recorded := make(map[string]bool)
+	var users []string
+	seenUsers := make(map[string]bool)
+
+	addUser := func(userEmail string) {
+		if userEmail != "" && !seenUsers[userEmail] {
+			seenUsers[userEmail] = true
+			users = append(users, userEmail)
+		}
+	}
+
+	for _, rec := range records {
+		recorded[rec.ClockifyRequestID] = true
+		addUser(rec.UserEmail)
+		for _, event := range rec.GoogleCalendarEvents {
+			known[event.target().account(rec.UserEmail)+"/"+event.CalendarID+"/"+event.EventID] = true
+		}
+	}
+	for _, userEmail := range r.Users {
+		addUser(userEmail)
+	}
+
+	// Each user's own calendars are searched as that user; a shared calendar
+	// is searched once, as its owner.
+	type search struct {
+		userEmail string
+		target    CalendarTarget
+	}
+	var searches []search
+	for _, target := range r.Calendars.targets() {
+		if target.Owner != "" {
+			searches = append(searches, search{target.Owner, target})
+			continue
+		}
+		for _, userEmail := range users {
+			searches = append(searches, search{userEmail, target})
+		}
+	}
+
+	var drift []Drift
+	var errs []error
+
+	for _, s := range searches {
+		userEmail, calID := s.userEmail, s.target.CalendarID
+
+		events, err := r.Backend.ListEvents(ctx, userEmail, calID, timeMin, timeMax)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("list events user=%s cal=%s: %w", userEmail, calID, err))
+			continue
+		}
+
+		for _, event := range events {
+			if known[userEmail+"/"+calID+"/"+event.ID] {
+				continue
+			}
+
+			drift = append(drift, Drift{
+				Kind:       DriftOrphanedEvent,
+				RequestID:  event.ClockifyRequestID,
+				UserEmail:  userEmail,
+				CalendarID: calID,
+				Owner:      s.target.Owner,
+				EventID:    event.ID,
+				Detail:     "no sync record refers to this event",
+			})
+		}
+	}
+
+	if r.Repair && len(drift) > 0 {
+		if err := r.repairOrphans(ctx, drift, recorded, timeMin, timeMax); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return drift, errors.Join(errs...)
+}
+
+// repairOrphans looks the orphans' requests up in Clockify. Events of
+// requests that still want them and have no record are adopted into a new
+// record, as deleting them would only have the next sync create them again;
+// the rest are deleted. Without a Source nothing is repaired.
+func (r *Reconciler) repairOrphans(
+	ctx context.Context,
+	drift []Drift,
+	recorded map[string]bool,
+	timeMin, timeMax time.Time,
+) error {
+	if r.Source == nil {
+		log.Printf("Not repairing %d orphaned events: no Clockify source to look their requests up", len(drift))
+		for i := range drift {
+			drift[i].Detail += "; not repaired: Clockify is needed to check its request"
+		}
+		return nil
+	}
+
+	start, end := formatClockify(timeMin), formatClockify(timeMax)
+	fetched, err := r.Source.ListAllTimeOffRequests(ctx, r.WorkspaceID, ClockifyRequestPayload{
+		Start:    &start,
+		End:      &end,
+		Statuses: ClockifyStatuses,
+	})
+	if err != nil {
+		for i := range drift {
+			drift[i].Error = err.Error()
+		}
+		return fmt.Errorf("look up orphaned events' requests: %w", err)
+	}
+	requests := make(map[string]ClockifyRequest, len(fetched.Requests))
+	for _, req := range fetched.Requests {
+		requests[req.ID] = req
+	}
+
+	var errs []error
+	adopt := make(map[string][]int)
+	var adopting []string
+
+	for i := range drift {
+		d := &drift[i]
+
+		if req, ok := requests[d.RequestID]; ok && !recorded[d.RequestID] {
+			action, err := PlanSyncAction(RequestToProcess{Request: req}, r.Options)
+			if err == nil && action == SyncActionInsert {
+				if _, ok := adopt[d.RequestID]; !ok {
+					adopting = append(adopting, d.RequestID)
+				}
+				adopt[d.RequestID] = append(adopt[d.RequestID], i)
+				continue
+			}
+		}
+
+		err := r.Backend.DeleteEvent(ctx, d.UserEmail, d.CalendarID, d.EventID)
+		if err != nil && !errors.Is(err, ErrEventNotFound) {
+			d.Error = err.Error()
+			errs = append(errs, fmt.Errorf("delete orphaned event %s user=%s cal=%s: %w", d.EventID, d.UserEmail, d.CalendarID, err))
+			continue
+		}
+		d.Repaired = true
+		log.Printf("DELETED orphaned OOO event user=%s cal=%s eventId=%s", d.UserEmail, d.CalendarID, d.EventID)
+	}
+
+	for _, id := range adopting {
+		if err := r.adoptOrphans(ctx, requests[id], drift, adopt[id]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// adoptOrphans stores a record for req owning the orphaned events at
+// indexes in drift.
+func (r *Reconciler) adoptOrphans(ctx context.Context, req ClockifyRequest, drift []Drift, indexes []int) error {
+	item, err := req.ToDynamoItem()
+	if err == nil {
+		item.SyncState = SyncStateSynced
+		for _, i := range indexes {
+			d := drift[i]
+			// The sync writes a user's own events as the account it
+			// resolved the user to, which is the record's UserEmail.
+			if d.Owner == "" {
+				item.UserEmail = d.UserEmail
+			}
+			item.GoogleCalendarEvents = append(item.GoogleCalendarEvents, GoogleCalendarEvent{
+				CalendarID: d.CalendarID,
+				EventID:    d.EventID,
+				Owner:      d.Owner,
+			})
+		}
+		err = r.Store.PutSyncedRequest(ctx, item)
+	}
+
+	for _, i := range indexes {
+		if err != nil {
+			drift[i].Error = err.Error()
+		} else {
+			drift[i].Repaired = true
+			drift[i].Detail += "; adopted into a new sync record"
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("adopt orphaned events of request %s: %w", req.ID, err)
+	}
+
+	log.Printf("ADOPTED %d orphaned OOO events into a record for Clockify request %s", len(indexes), req.ID)
+	return nil
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler_FindsAndRepairsDrift(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+	targets := UserCalendars("primary")
+	changedAt := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	// sync inserts a request's events and stores its record, as a sync would.
+	sync := func(id, status string) *SyncedClockifyRequest {
+		req := makeStatusRequest(id, ClockifyStatusApproved, changedAt)
+		events, err := InsertOOOEvents(ctx, backend, req, targets, SyncOptions{})
+		require.NoError(t, err)
+
+		item, err := req.ToDynamoItem()
+		require.NoError(t, err)
+		item.Status = status
+		item.GoogleCalendarEvents = events
+		require.NoError(t, store.PutSyncedRequest(ctx, item))
+		return item
+	}
+
+	sync("in-sync", ClockifyStatusApproved)
+
+	deleted := sync("deleted-by-hand", ClockifyStatusApproved)
+	require.NoError(t, backend.DeleteEvent(ctx, deleted.UserEmail, "primary", deleted.GoogleCalendarEvents[0].EventID))
+
+	moved := sync("moved-by-hand", ClockifyStatusApproved)
+	movedReq := moved.clockifyRequest()
+	movedReq.TimeOffPeriod.Period.Start = "2025-12-20T00:00:00Z"
+	movedReq.TimeOffPeriod.Period.End = "2025-12-20T23:59:59Z"
+	movedEv, err := planOOOEvent(movedReq, EventConfig{})
+	require.NoError(t, err)
+	require.NoError(t, backend.PatchEvent(ctx, moved.UserEmail, "primary", moved.GoogleCalendarEvents[0].EventID, movedEv))
+
+	sync("rejected-not-deleted", ClockifyStatusRejected)
+
+	orphan := makeStatusRequest("orphan", ClockifyStatusApproved, changedAt)
+	_, err = InsertOOOEvents(ctx, backend, orphan, targets, SyncOptions{})
+	require.NoError(t, err)
+
+	timeMin := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Without repair, drift is only reported.
+	report, err := NewReconciler(backend, store, CalendarRouting{}).Reconcile(ctx, timeMin, timeMax)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.Records)
+	assert.Equal(t, 4, report.Drifted)
+	assert.Equal(t, 0, report.Repaired)
+
+	kinds := make(map[string]DriftKind)
+	for _, d := range report.Drift {
+		kinds[d.RequestID] = d.Kind
+	}
+	assert.Equal(t, map[string]DriftKind{
+		"deleted-by-hand":      DriftMissingEvent,
+		"moved-by-hand":        DriftWrongEvent,
+		"rejected-not-deleted": DriftStaleEvent,
+		"orphan":               DriftOrphanedEvent,
+	}, kinds)
+	assert.Equal(t, 4, fake.count("primary"))
+
+	// Repairing fixes both sides. The orphan's request was rejected since,
+	// so its event is deleted.
+	orphan.Status.StatusType = ClockifyStatusRejected
+	source := &fakeClockifySource{requests: []ClockifyRequest{orphan}}
+	report, err = NewReconciler(
+		backend, store, CalendarRouting{},
+		WithRepair(),
+		WithReconcileSource("ws", source),
+	).Reconcile(ctx, timeMin, timeMax)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Drifted)
+	assert.Equal(t, 4, report.Repaired)
+	assert.Equal(t, 0, report.Failed)
+
+	recreated := mustGetSyncedRequest(t, store, "deleted-by-hand")
+	require.Len(t, recreated.GoogleCalendarEvents, 1)
+	assert.NotEqual(t, deleted.GoogleCalendarEvents[0].EventID, recreated.GoogleCalendarEvents[0].EventID)
+	assert.NotNil(t, fake.event("primary", recreated.GoogleCalendarEvents[0].EventID))
+
+	assert.Empty(t, mustGetSyncedRequest(t, store, "rejected-not-deleted").GoogleCalendarEvents)
+	assert.Equal(t, 3, fake.count("primary"))
+
+	report, err = NewReconciler(backend, store, CalendarRouting{}).Reconcile(ctx, timeMin, timeMax)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Drifted)
+}
+
+func TestReconciler_ReportsCalendarsWithoutEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+
+	// The insert succeeded on one calendar before the record was last
+	// stored without it.
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, time.Now())
+	events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+
+	item, err := req.ToDynamoItem()
+	require.NoError(t, err)
+	require.NoError(t, store.PutSyncedRequest(ctx, item))
+
+	routing := CalendarRouting{Calendars: []CalendarRoute{
+		{CalendarTarget: CalendarTarget{CalendarID: "team@example.com"}},
+	}}
+	reconciler := NewReconciler(backend, store, routing, WithRepair())
+	report, err := reconciler.Reconcile(ctx, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	require.Len(t, report.Drift, 1)
+	assert.Equal(t, DriftMissingEvent, report.Drift[0].Kind)
+	assert.True(t, report.Drift[0].Repaired)
+
+	// The existing event is adopted rather than duplicated or deleted.
+	assert.Equal(t, events, mustGetSyncedRequest(t, store, "request-123").GoogleCalendarEvents)
+	assert.Equal(t, 1, fake.count("team@example.com"))
+}
+
+func TestReconciler_AdoptsOrphansOfRequestsStillApproved(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+	timeMin := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The insert succeeded but storing the record failed.
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, timeMin)
+	events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), SyncOptions{})
+	require.NoError(t, err)
+
+	// Without Clockify to check the request, the orphan is left alone.
+	users := WithReconcileUsers(req.UserEmail)
+	report, err := NewReconciler(backend, store, CalendarRouting{}, WithRepair(), users).Reconcile(ctx, timeMin, timeMax)
+	require.NoError(t, err)
+	require.Len(t, report.Drift, 1)
+	assert.False(t, report.Drift[0].Repaired)
+	assert.Equal(t, 1, fake.count("primary"))
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{req}}
+	report, err = NewReconciler(
+		backend, store, CalendarRouting{},
+		WithRepair(),
+		WithReconcileSource("ws", source),
+		users,
+	).Reconcile(ctx, timeMin, timeMax)
+	require.NoError(t, err)
+	require.Len(t, report.Drift, 1)
+	assert.Equal(t, DriftOrphanedEvent, report.Drift[0].Kind)
+	assert.True(t, report.Drift[0].Repaired)
+
+	// The event is kept and a record now owns it, so the next sync skips
+	// the request instead of creating the event again.
+	assert.Equal(t, 1, fake.count("primary"))
+	adopted := mustGetSyncedRequest(t, store, "request-123")
+	require.NotNil(t, adopted)
+	assert.Equal(t, events, adopted.GoogleCalendarEvents)
+	needsSync, _ := NeedsSync(adopted, req)
+	assert.False(t, needsSync)
+}
+
+func TestReconciler_DoesNotReplanRecordsWithoutTimeZone(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+
+	// A record from before the time zone was stored, for a New York day
+	// that would be a day off if planned in UTC.
+	legacy := func(id string) *SyncedClockifyRequest {
+		req := makeRequest(id, "America/New_York", "2025-12-10T05:00:00Z", "2025-12-11T04:59:59Z")
+		req.Status.StatusType = ClockifyStatusApproved
+		events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary"), SyncOptions{})
+		require.NoError(t, err)
+
+		item, err := req.ToDynamoItem()
+		require.NoError(t, err)
+		item.UserTimeZone = ""
+		item.GoogleCalendarEvents = events
+		require.NoError(t, store.PutSyncedRequest(ctx, item))
+		return item
+	}
+
+	legacy("in-sync")
+	deleted := legacy("deleted-by-hand")
+	require.NoError(t, backend.DeleteEvent(ctx, deleted.UserEmail, "primary", deleted.GoogleCalendarEvents[0].EventID))
+
+	report, err := NewReconciler(backend, store, CalendarRouting{}, WithRepair()).Reconcile(
+		ctx,
+		time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+
+	// The dates of the remaining event aren't second-guessed, and the
+	// missing one is reported rather than recreated on the wrong day.
+	require.Len(t, report.Drift, 1)
+	assert.Equal(t, DriftMissingEvent, report.Drift[0].Kind)
+	assert.Equal(t, "deleted-by-hand", report.Drift[0].RequestID)
+	assert.False(t, report.Drift[0].Repaired)
+	assert.Equal(t, 1, fake.count("primary"))
+}
+
+func TestReconciler_DeletesEventsOnCalendarsNoLongerRouted(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	backend := fake.backend()
+	store := NewMemoryStore()
+
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, time.Now())
+	events, err := InsertOOOEvents(ctx, backend, req, UserCalendars("primary", "team@example.com"), SyncOptions{})
+	require.NoError(t, err)
+	item, err := req.ToDynamoItem()
+	require.NoError(t, err)
+	item.GoogleCalendarEvents = events
+	require.NoError(t, store.PutSyncedRequest(ctx, item))
+
+	// The team calendar was dropped from the routing since.
+	reconciler := NewReconciler(backend, store, CalendarRouting{}, WithRepair())
+	report, err := reconciler.Reconcile(ctx, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	require.Len(t, report.Drift, 1)
+	assert.Equal(t, DriftStaleEvent, report.Drift[0].Kind)
+	assert.Equal(t, "team@example.com", report.Drift[0].CalendarID)
+	assert.True(t, report.Drift[0].Repaired)
+
+	assert.Equal(t, events[:1], mustGetSyncedRequest(t, store, "request-123").GoogleCalendarEvents)
+	assert.Equal(t, 0, fake.count("team@example.com"))
+	assert.Equal(t, 1, fake.count("primary"))
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// RetryPolicy spaces out the retries of requests that failed to sync.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is tried before it is
+	// dead-lettered.
+	MaxAttempts int
+	// Backoff is the wait after the first failure. It doubles after each
+	// further failure, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     5 * time.Minute,
+	MaxBackoff:  6 * time.Hour,
+}
+
+// delay is the wait before retrying after the given number of failed
+// attempts.
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// needsSync is NeedsSync for a Syncer: on top of requests that changed, it
+// queues failed requests whose retry is due. Dead-lettered requests wait
+// until they change in Clockify.
+func (s *Syncer) needsSync(existing *SyncedClockifyRequest, req ClockifyRequest) (bool, string) {
+	needsSync, reason := NeedsSync(existing, req)
+	if existing == nil || needsSync {
+		return needsSync, reason
+	}
+
+	switch existing.SyncState {
+	case SyncStateFailed:
+		if !existing.retryDue(s.Clock()) {
+			return false, fmt.Sprintf("retrying at %s after %d failed attempts", existing.NextRetryAt, existing.Attempts)
+		}
+		return true, fmt.Sprintf("retrying after %d failed attempts", existing.Attempts)
+
+	case SyncStateDeadLetter:
+		return false, fmt.Sprintf("dead-lettered after %d failed attempts: %s", existing.Attempts, existing.LastError)
+	}
+
+	return needsSync, reason
+}
+
+// retryDue reports whether a failed record's retry is due at now. Records
+// without a retry time are always due.
+func (s *SyncedClockifyRequest) retryDue(now time.Time) bool {
+	next, err := ParseTimeAny(s.NextRetryAt)
+	return err != nil || !now.Before(next)
+}
+
+// recordFailure stores that req failed to sync with cause, so that it is
+// retried after a backoff, or dead-letters it once it has used up its
+// attempts. The record keeps the events and Slack status already synced,
+// which are still what is on the calendars, with the request as it now is.
+//
+// It must be called under the request's claim. A failure to store is only
+// logged: the request is then retried like before records were kept, when
+// it is next fetched.
+func (s *Syncer) recordFailure(ctx context.Context, req RequestToProcess, result *RequestResult, cause error) {
+	now := s.Clock()
+
+	item, err := req.Request.ToDynamoItem(WithLastSeenAt(now))
+	if err != nil {
+		log.Printf("Failed to convert Clockify request %s to a sync record: %v", req.Request.ID, err)
+		return
+	}
+
+	item.Attempts = 1
+	if existing := req.ExistingRecord; existing != nil {
+		item.Version = existing.Version
+		item.GoogleCalendarEvents = existing.GoogleCalendarEvents
+		item.SlackStatus = existing.SlackStatus
+
+		// Attempts count failures of the same request; a change in Clockify
+		// starts over.
+		failing := existing.SyncState == SyncStateFailed || existing.SyncState == SyncStateDeadLetter
+		if changed, _ := NeedsSync(existing, req.Request); failing && !changed {
+			item.Attempts = existing.Attempts + 1
+		}
+	}
+	item.LastError = cause.Error()
+
+	if item.Attempts >= s.Retry.MaxAttempts {
+		item.SyncState = SyncStateDeadLetter
+		result.DeadLettered = true
+		log.Printf("Dead-lettering Clockify request %s after %d failed attempts", req.Request.ID, item.Attempts)
+	} else {
+		item.SyncState = SyncStateFailed
+		item.NextRetryAt = now.Add(s.Retry.delay(item.Attempts)).UTC().Format(time.RFC3339)
+		log.Printf("Retrying Clockify request %s at %s (attempt %d failed)", req.Request.ID, item.NextRetryAt, item.Attempts)
+	}
+	result.Attempts = item.Attempts
+
+	if err := s.Store.PutSyncedRequest(ctx, item); err != nil {
+		log.Printf("Failed to record the failure of Clockify request %s: %v", req.Request.ID, err)
+	}
+}
+
+// dueRetries returns the failed requests whose retry is due, whatever
+// window they are in, leaving out those already in requests. Each is taken
+// from fetched when it is there, as Clockify's copy is the freshest, and
+// otherwise rebuilt from its record. The requests in the dead-letter state
+// are listed in report.
+func (s *Syncer) dueRetries(
+	ctx context.Context,
+	requests, fetched []ClockifyRequest,
+	report *Report,
+) ([]ClockifyRequest, error) {
+	deadLetters, err := s.Store.ListDeadLetters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list dead-lettered requests: %w", err)
+	}
+	for _, rec := range deadLetters {
+		if len(s.Users) == 0 || slices.Contains(s.Users, rec.UserID) {
+			report.deadLetter(rec.ClockifyRequestID)
+		}
+	}
+
+	now := s.Clock()
+	due, err := s.Store.ListDueRetries(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("list due retries: %w", err)
+	}
+
+	queued := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		queued[req.ID] = true
+	}
+	latest := make(map[string]ClockifyRequest, len(fetched))
+	for _, req := range fetched {
+		latest[req.ID] = req
+	}
+
+	var retries []ClockifyRequest
+	for _, rec := range due {
+		if len(s.Users) > 0 && !slices.Contains(s.Users, rec.UserID) {
+			continue
+		}
+		if queued[rec.ClockifyRequestID] {
+			continue
+		}
+
+		req, ok := latest[rec.ClockifyRequestID]
+		if !ok {
+			req = rec.clockifyRequest()
+		}
+		log.Printf("Retrying failed Clockify request %s outside the window", rec.ClockifyRequestID)
+		retries = append(retries, req)
+	}
+
+	return retries, nil
+}
+
+func (r *Report) deadLetter(clockifyRequestID string) {
+	if !slices.Contains(r.DeadLetters, clockifyRequestID) {
+		r.DeadLetters = append(r.DeadLetters, clockifyRequestID)
+	}
+}
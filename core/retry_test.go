@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncer_RetriesFailuresWithBackoffThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("flaky", ClockifyStatusApproved, time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)),
+	}}
+	sink := &fakeCalendarSink{fail: map[string]error{"flaky": errors.New("calendar unavailable")}}
+	store := NewMemoryStore()
+
+	syncer := NewSyncer(
+		"ws", source, sink, store,
+		WithClock(func() time.Time { return now }),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Minute, MaxBackoff: time.Hour}),
+	)
+	inWindow := SyncWindow{}
+	// Later runs only look at recent activity, which the request isn't.
+	outsideWindow := func() SyncWindow { return SyncWindow{ActivityStart: now.Add(-time.Minute)} }
+
+	report, err := syncer.Sync(ctx, inWindow)
+	require.Error(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 1, report.Results[0].Attempts)
+
+	rec := mustGetSyncedRequest(t, store, "flaky")
+	require.NotNil(t, rec)
+	assert.Equal(t, SyncStateFailed, rec.SyncState)
+	assert.Equal(t, 1, rec.Attempts)
+	assert.Equal(t, "sync request flaky: calendar unavailable", rec.LastError)
+	assert.Equal(t, "2025-12-05T12:10:00Z", rec.NextRetryAt)
+
+	// Before its backoff is up the request waits, even in the window.
+	now = now.Add(5 * time.Minute)
+	report, err = syncer.Sync(ctx, inWindow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 0, report.Retried)
+
+	// Once it is due the retry pass picks it up outside the window, and the
+	// backoff doubles.
+	now = now.Add(5 * time.Minute)
+	report, err = syncer.Sync(ctx, outsideWindow())
+	require.Error(t, err)
+	assert.Equal(t, 0, report.Fetched)
+	assert.Equal(t, 1, report.Retried)
+	assert.Equal(t, 1, report.Failed)
+
+	rec = mustGetSyncedRequest(t, store, "flaky")
+	assert.Equal(t, 2, rec.Attempts)
+	assert.Equal(t, "2025-12-05T12:30:00Z", rec.NextRetryAt)
+
+	// The last attempt dead-letters it.
+	now = now.Add(20 * time.Minute)
+	report, err = syncer.Sync(ctx, outsideWindow())
+	require.Error(t, err)
+	assert.Equal(t, 1, report.Retried)
+	assert.Equal(t, 1, report.DeadLettered)
+	assert.Equal(t, []string{"flaky"}, report.DeadLetters)
+	assert.True(t, report.Results[0].DeadLettered)
+
+	rec = mustGetSyncedRequest(t, store, "flaky")
+	assert.Equal(t, SyncStateDeadLetter, rec.SyncState)
+	assert.Equal(t, 3, rec.Attempts)
+	assert.Empty(t, rec.NextRetryAt)
+
+	// Dead letters are reported but no longer tried, in the window or not.
+	now = now.Add(24 * time.Hour)
+	report, err = syncer.Sync(ctx, outsideWindow())
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Retried)
+	assert.Equal(t, 0, report.DeadLettered)
+	assert.Equal(t, []string{"flaky"}, report.DeadLetters)
+
+	report, err = syncer.Sync(ctx, inWindow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Len(t, sink.synced, 0)
+}
+
+func TestSyncer_RetrySuccessClearsFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("flaky", ClockifyStatusApproved, time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)),
+	}}
+	sink := &fakeCalendarSink{fail: map[string]error{"flaky": errors.New("calendar unavailable")}}
+	store := NewMemoryStore()
+	syncer := NewSyncer("ws", source, sink, store, WithClock(func() time.Time { return now }))
+
+	_, err := syncer.Sync(ctx, SyncWindow{})
+	require.Error(t, err)
+
+	sink.fail = nil
+	now = now.Add(time.Hour)
+	report, err := syncer.Sync(ctx, SyncWindow{ActivityStart: now})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Retried)
+	assert.Equal(t, 1, report.Inserted)
+
+	rec := mustGetSyncedRequest(t, store, "flaky")
+	assert.Equal(t, SyncStateSynced, rec.SyncState)
+	assert.Zero(t, rec.Attempts)
+	assert.Empty(t, rec.LastError)
+	assert.Empty(t, rec.NextRetryAt)
+	require.Len(t, rec.GoogleCalendarEvents, 1)
+}
+
+// retryIndexlessStore is a store whose table lacks the retry index.
+type retryIndexlessStore struct {
+	*MemoryStore
+}
+
+func (retryIndexlessStore) ListDeadLetters(context.Context) ([]*SyncedClockifyRequest, error) {
+	return nil, errors.New("the table does not have the specified index")
+}
+
+func TestSyncer_SyncsTheWindowWhenTheRetryPassFails(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("request-1", ClockifyStatusApproved, time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)),
+	}}
+	sink := &fakeCalendarSink{}
+	syncer := NewSyncer(
+		"ws", source, sink, retryIndexlessStore{NewMemoryStore()},
+		WithClock(func() time.Time { return now }),
+	)
+
+	report, err := syncer.Sync(ctx, SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, []string{
+		"retry pass: list dead-lettered requests: the table does not have the specified index",
+	}, report.Warnings)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, Backoff: time.Minute, MaxBackoff: 5 * time.Minute}
+
+	assert.Equal(t, time.Minute, p.delay(1))
+	assert.Equal(t, 2*time.Minute, p.delay(2))
+	assert.Equal(t, 4*time.Minute, p.delay(3))
+	assert.Equal(t, 5*time.Minute, p.delay(4))
+	assert.Equal(t, 5*time.Minute, p.delay(20))
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// CalendarTarget is a calendar an OOO event is written to.
+type CalendarTarget struct {
+	CalendarID string `json:"calendarId"`
+	// Owner is the account impersonated to write a shared calendar, such as
+	// a team calendar. Empty means the request's own user.
+	Owner string `json:"owner,omitempty"`
+}
+
+// UserCalendars targets the given calendars of each request's own user.
+func UserCalendars(calendarIDs ...string) []CalendarTarget {
+	targets := make([]CalendarTarget, 0, len(calendarIDs))
+	for _, calID := range calendarIDs {
+		targets = append(targets, CalendarTarget{CalendarID: calID})
+	}
+	return targets
+}
+
+// account returns the account that writes t for userEmail's requests.
+func (t CalendarTarget) account(userEmail string) string {
+	if t.Owner != "" {
+		return t.Owner
+	}
+	return userEmail
+}
+
+// shared reports whether t is someone else's calendar rather than one of
+// userEmail's own.
+func (t CalendarTarget) shared(userEmail string) bool {
+	return t.Owner != "" && t.Owner != userEmail
+}
+
+// CalendarRouting decides which calendars each request is written to. It is
+// loaded from JSON, e.g.
+//
+//	{
+//	  "calendars": [
+//	    {"calendarId": "primary"},
+//	    {
+//	      "calendarId": "c_team@group.calendar.google.com",
+//	      "owner": "calendar-admin@example.com",
+//	      "policies": ["Vacation", "Sick leave"]
+//	    }
+//	  ]
+//	}
+//
+// The zero CalendarRouting writes to each user's primary calendar only.
+type CalendarRouting struct {
+	Calendars []CalendarRoute `json:"calendars"`
+}
+
+// CalendarRoute writes requests under Policies, or every request when
+// Policies is empty, to a calendar.
+type CalendarRoute struct {
+	CalendarTarget
+	Policies []string `json:"policies,omitempty"`
+}
+
+var defaultCalendarRoutes = []CalendarRoute{
+	{CalendarTarget: CalendarTarget{CalendarID: "primary"}},
+}
+
+func (c CalendarRouting) routes() []CalendarRoute {
+	if len(c.Calendars) == 0 {
+		return defaultCalendarRoutes
+	}
+	return c.Calendars
+}
+
+// targetsFor returns the calendars r is routed to.
+func (c CalendarRouting) targetsFor(r ClockifyRequest) []CalendarTarget {
+	var targets []CalendarTarget
+	for _, route := range c.routes() {
+		if len(route.Policies) == 0 || slices.Contains(route.Policies, r.PolicyName) {
+			targets = append(targets, route.CalendarTarget)
+		}
+	}
+	return targets
+}
+
+// targets returns every calendar some request may be routed to.
+func (c CalendarRouting) targets() []CalendarTarget {
+	targets := make([]CalendarTarget, 0, len(c.routes()))
+	for _, route := range c.routes() {
+		targets = append(targets, route.CalendarTarget)
+	}
+	return targets
+}
+
+// LoadCalendarRouting reads a CalendarRouting from a JSON file.
+func LoadCalendarRouting(path string) (CalendarRouting, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return CalendarRouting{}, fmt.Errorf("read calendar routing: %w", err)
+	}
+	return ParseCalendarRouting(b)
+}
+
+// ParseCalendarRouting decodes and validates a CalendarRouting. Unknown
+// fields are rejected, as are calendars listed twice for the same owner.
+func ParseCalendarRouting(b []byte) (CalendarRouting, error) {
+	var routing CalendarRouting
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&routing); err != nil {
+		return CalendarRouting{}, fmt.Errorf("parse calendar routing: %w", err)
+	}
+
+	seen := make(map[CalendarTarget]bool)
+	for i, route := range routing.Calendars {
+		if route.CalendarID == "" {
+			return CalendarRouting{}, fmt.Errorf("parse calendar routing: calendar %d has no calendarId", i)
+		}
+		if seen[route.CalendarTarget] {
+			return CalendarRouting{}, fmt.Errorf("parse calendar routing: calendar %q is listed twice", route.CalendarID)
+		}
+		seen[route.CalendarTarget] = true
+	}
+
+	return routing, nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCalendarRouting(t *testing.T) {
+	routing, err := ParseCalendarRouting([]byte(`{
+		"calendars": [
+			{"calendarId": "primary"},
+			{"calendarId": "team@example.com", "owner": "calendar-admin@example.com", "policies": ["Vacation"]}
+		]
+	}`))
+	require.NoError(t, err)
+
+	team := CalendarTarget{CalendarID: "team@example.com", Owner: "calendar-admin@example.com"}
+
+	vacation := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	assert.Equal(t, []CalendarTarget{{CalendarID: "primary"}, team}, routing.targetsFor(vacation))
+
+	sick := vacation
+	sick.PolicyName = "Sick leave"
+	assert.Equal(t, UserCalendars("primary"), routing.targetsFor(sick))
+
+	assert.Equal(t, UserCalendars("primary"), CalendarRouting{}.targetsFor(vacation))
+
+	for name, raw := range map[string]string{
+		"unknown field":      `{"calendars": [{"calendarId": "primary", "color": "1"}]}`,
+		"missing calendarId": `{"calendars": [{"owner": "calendar-admin@example.com"}]}`,
+		"duplicate":          `{"calendars": [{"calendarId": "primary"}, {"calendarId": "primary"}]}`,
+	} {
+		_, err := ParseCalendarRouting([]byte(raw))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestBackendCalendarSink_WritesAndDeletesSharedCalendarEvents(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	sink := &BackendCalendarSink{
+		Backend: fake.backend(),
+		Calendars: CalendarRouting{Calendars: []CalendarRoute{
+			{CalendarTarget: CalendarTarget{CalendarID: "primary"}},
+			{CalendarTarget: CalendarTarget{CalendarID: "team@example.com", Owner: "calendar-admin@example.com"}},
+		}},
+	}
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	events, err := sink.SyncOOORequest(ctx, RequestToProcess{Request: req}, SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Empty(t, events[0].Owner)
+	assert.Equal(t, "calendar-admin@example.com", events[1].Owner)
+
+	primary := fake.event("primary", events[0].EventID)
+	assert.Equal(t, "OOO — Vacation", primary["summary"])
+	assert.Equal(t, "outOfOffice", primary["eventType"])
+
+	team := fake.event("team@example.com", events[1].EventID)
+	assert.Equal(t, "fixture@example.com: OOO — Vacation", team["summary"])
+	assert.Nil(t, team["eventType"])
+
+	withdrawn := req
+	withdrawn.Status.StatusType = ClockifyStatusWithdrawn
+	existing := &SyncedClockifyRequest{ClockifyRequestID: req.ID, GoogleCalendarEvents: events}
+
+	remaining, err := sink.SyncOOORequest(ctx, RequestToProcess{Request: withdrawn, ExistingRecord: existing}, SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Equal(t, 0, fake.count("primary"))
+	assert.Equal(t, 0, fake.count("team@example.com"))
+}
+
+func TestBackendCalendarSink_ReroutesEventsWhenThePolicyChanges(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeGoogleCalendar(t)
+	team := CalendarTarget{CalendarID: "team@example.com", Owner: "calendar-admin@example.com"}
+	sink := &BackendCalendarSink{
+		Backend: fake.backend(),
+		Calendars: CalendarRouting{Calendars: []CalendarRoute{
+			{CalendarTarget: CalendarTarget{CalendarID: "primary"}, Policies: []string{"Sick leave"}},
+			{CalendarTarget: team, Policies: []string{"Vacation"}},
+		}},
+	}
+
+	req := makeRequest("request-123", "UTC", "2025-12-10T00:00:00Z", "2025-12-10T23:59:59Z")
+	req.Status.StatusType = ClockifyStatusApproved
+
+	events, err := sink.SyncOOORequest(ctx, RequestToProcess{Request: req}, SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, team, events[0].target())
+
+	item, err := req.ToDynamoItem()
+	require.NoError(t, err)
+	item.GoogleCalendarEvents = events
+
+	// A policy routed nowhere leaves no event behind on the team calendar.
+	parental := req
+	parental.PolicyName = "Parental leave"
+
+	rerouted, err := sink.SyncOOORequest(ctx, RequestToProcess{Request: parental, ExistingRecord: item}, SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, rerouted)
+	assert.Equal(t, 0, fake.count("team@example.com"))
+
+	// Sick leave moves the event to the user's own calendar.
+	events, err = sink.SyncOOORequest(ctx, RequestToProcess{Request: req}, SyncOptions{})
+	require.NoError(t, err)
+	item.GoogleCalendarEvents = events
+	sick := req
+	sick.PolicyName = "Sick leave"
+
+	rerouted, err = sink.SyncOOORequest(ctx, RequestToProcess{Request: sick, ExistingRecord: item}, SyncOptions{})
+	require.NoError(t, err)
+	require.Len(t, rerouted, 1)
+	assert.Equal(t, CalendarTarget{CalendarID: "primary"}, rerouted[0].target())
+	assert.Equal(t, 0, fake.count("team@example.com"))
+	assert.Equal(t, 1, fake.count("primary"))
+}
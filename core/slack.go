@@ -0,0 +1,291 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultSlackEndpoint = "https://slack.com/api"
+
+// SlackStatusUpdater sets a user's Slack status while their approved time off
+// is under way, with Slack expiring it when the time off ends in the user's
+// time zone, and clears it early if the request is rejected, withdrawn or
+// moved off today.
+//
+// The token must be a workspace admin's user token with the users:read,
+// users:read.email and users.profile:write scopes: only admins can set other
+// members' statuses. Slack has no way to snooze someone else's
+// notifications, so Do Not Disturb is left to the user.
+type SlackStatusUpdater struct {
+	// Text and Emoji make up the status, e.g. "Out of office" and
+	// ":palm_tree:".
+	Text  string
+	Emoji string
+
+	token      string
+	endpoint   string
+	httpClient *http.Client
+	limiter    *RateLimiter
+}
+
+func NewSlackStatusUpdater(token string, opts ...func(*SlackStatusUpdater)) *SlackStatusUpdater {
+	u := &SlackStatusUpdater{
+		Text:       "Out of office",
+		Emoji:      ":palm_tree:",
+		token:      token,
+		endpoint:   defaultSlackEndpoint,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	u.httpClient = rateLimitedClient(u.httpClient, u.limiter)
+	return u
+}
+
+// For testing: talk to endpoint with httpClient rather than the real Slack
+// API.
+func WithSlackEndpoint(endpoint string, httpClient *http.Client) func(*SlackStatusUpdater) {
+	return func(u *SlackStatusUpdater) {
+		u.endpoint = strings.TrimSuffix(endpoint, "/")
+		u.httpClient = httpClient
+	}
+}
+
+// WithSlackStatusText sets the status text and emoji.
+func WithSlackStatusText(text, emoji string) func(*SlackStatusUpdater) {
+	return func(u *SlackStatusUpdater) {
+		u.Text = text
+		u.Emoji = emoji
+	}
+}
+
+// WithSlackRateLimiter makes every Slack API call wait on limiter.
+func WithSlackRateLimiter(limiter *RateLimiter) func(*SlackStatusUpdater) {
+	return func(u *SlackStatusUpdater) {
+		u.limiter = limiter
+	}
+}
+
+// SlackStatus is a status SlackStatusUpdater set for a request, kept on its
+// sync record so that it can be cleared again.
+type SlackStatus struct {
+	UserID string `json:"userId" dynamodbav:"UserId"`
+	Text   string `json:"text" dynamodbav:"Text"`
+	Emoji  string `json:"emoji" dynamodbav:"Emoji"`
+	// ExpiresAt is when Slack clears the status by itself, RFC 3339.
+	ExpiresAt string `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+// expired reports whether Slack has already cleared the status.
+func (s *SlackStatus) expired(now time.Time) bool {
+	expiresAt, err := ParseTimeAny(s.ExpiresAt)
+	return err == nil && !now.Before(expiresAt)
+}
+
+// SlackError is an error response from the Slack Web API.
+type SlackError struct {
+	Method string
+	Code   string
+}
+
+func (e *SlackError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// SyncStatus brings r's user's Slack status in line with r at now, given
+// the status last set for it, and returns the status now set for it: a
+// status while approved time off is under way, otherwise nil. Approved time
+// off that hasn't started yet gets its status from a later call.
+func (u *SlackStatusUpdater) SyncStatus(
+	ctx context.Context,
+	r ClockifyRequest,
+	current *SlackStatus,
+	now time.Time,
+) (*SlackStatus, error) {
+	end, active, err := activeTimeOff(r, now)
+	if err != nil {
+		return current, err
+	}
+	if active {
+		return u.set(ctx, r.UserEmail, current, end)
+	}
+
+	if current == nil || current.expired(now) {
+		return nil, nil
+	}
+	if err := u.clear(ctx, current); err != nil {
+		return current, err
+	}
+	return nil, nil
+}
+
+// activeTimeOff reports whether r is approved time off under way at now,
+// and when it ends: the end of its last day in the user's time zone, or of
+// its hours.
+func activeTimeOff(r ClockifyRequest, now time.Time) (end time.Time, active bool, err error) {
+	if r.Status.StatusType != ClockifyStatusApproved {
+		return time.Time{}, false, nil
+	}
+
+	ev, err := planOOOEvent(r, EventConfig{})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return ev.End, !now.Before(ev.Start) && now.Before(ev.End), nil
+}
+
+// set sets the status until expiresAt, which Slack stores to the second.
+func (u *SlackStatusUpdater) set(
+	ctx context.Context,
+	userEmail string,
+	current *SlackStatus,
+	expiresAt time.Time,
+) (*SlackStatus, error) {
+	status := &SlackStatus{
+		Text:      u.Text,
+		Emoji:     u.Emoji,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	}
+
+	if current != nil {
+		status.UserID = current.UserID
+	} else {
+		var resp struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		err := u.call(ctx, http.MethodGet, "users.lookupByEmail", url.Values{"email": {userEmail}}, nil, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("look up Slack user %s: %w", userEmail, err)
+		}
+		status.UserID = resp.User.ID
+	}
+
+	if err := u.setProfileStatus(ctx, status.UserID, status.Text, status.Emoji, expiresAt.Unix()); err != nil {
+		return current, fmt.Errorf("set Slack status for %s: %w", userEmail, err)
+	}
+
+	log.Printf("Set Slack status %q for %s until %s", status.Text, userEmail, status.ExpiresAt)
+	return status, nil
+}
+
+// clear removes a status the updater set, unless the user has since set one
+// of their own.
+func (u *SlackStatusUpdater) clear(ctx context.Context, current *SlackStatus) error {
+	var resp struct {
+		Profile slackProfileStatus `json:"profile"`
+	}
+	err := u.call(ctx, http.MethodGet, "users.profile.get", url.Values{"user": {current.UserID}}, nil, &resp)
+	if err != nil {
+		return fmt.Errorf("get Slack status for %s: %w", current.UserID, err)
+	}
+
+	if resp.Profile.StatusText != current.Text || resp.Profile.StatusEmoji != current.Emoji {
+		log.Printf("Leaving Slack status of %s: changed since it was set", current.UserID)
+		return nil
+	}
+
+	if err := u.setProfileStatus(ctx, current.UserID, "", "", 0); err != nil {
+		return fmt.Errorf("clear Slack status for %s: %w", current.UserID, err)
+	}
+
+	log.Printf("Cleared Slack status for %s", current.UserID)
+	return nil
+}
+
+type slackProfileStatus struct {
+	StatusText       string `json:"status_text"`
+	StatusEmoji      string `json:"status_emoji"`
+	StatusExpiration int64  `json:"status_expiration"`
+}
+
+func (u *SlackStatusUpdater) setProfileStatus(ctx context.Context, userID, text, emoji string, expiration int64) error {
+	body := struct {
+		User    string             `json:"user"`
+		Profile slackProfileStatus `json:"profile"`
+	}{
+		User: userID,
+		Profile: slackProfileStatus{
+			StatusText:       text,
+			StatusEmoji:      emoji,
+			StatusExpiration: expiration,
+		},
+	}
+	return u.call(ctx, http.MethodPost, "users.profile.set", nil, body, nil)
+}
+
+// call invokes a Web API method and decodes its response into out. Slack
+// reports failures as "ok": false with an error code.
+func (u *SlackStatusUpdater) call(
+	ctx context.Context,
+	httpMethod, method string,
+	query url.Values,
+	in, out any,
+) error {
+	target := u.endpoint + "/" + method
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode slack %s: %w", method, err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+u.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &SlackError{Method: method, Code: resp.Status}
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("decode slack %s: %w", method, err)
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("decode slack %s: %w", method, err)
+	}
+	if !status.OK {
+		return &SlackError{Method: method, Code: status.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode slack %s: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncer_SetsAndClearsSlackStatus(t *testing.T) {
+	ctx := context.Background()
+	slack := newFakeSlack(t, map[string]string{"fixture@example.com": "U123"})
+	store := NewMemoryStore()
+
+	now := time.Date(2025, 12, 10, 15, 0, 0, 0, time.UTC)
+	syncer := NewSyncer(
+		"ws", nil, &fakeCalendarSink{}, store,
+		WithClock(func() time.Time { return now }),
+		WithSlackStatuses(slack.updater()),
+	)
+
+	// Approved time off under way sets the status until the end of its last
+	// day in the user's time zone.
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, now.Add(-time.Hour))
+	req.UserTimeZone = "America/New_York"
+	req.TimeOffPeriod.Period.Start = "2025-12-10T05:00:00Z"
+	req.TimeOffPeriod.Period.End = "2025-12-12T04:59:59Z"
+
+	report, err := syncer.SyncRequests(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.SlackStatuses)
+	assert.Equal(t, "set", report.Results[0].SlackStatus)
+
+	expiresAt := time.Date(2025, 12, 12, 5, 0, 0, 0, time.UTC)
+	assert.Equal(t, slackProfileStatus{
+		StatusText:       "Out of office",
+		StatusEmoji:      ":palm_tree:",
+		StatusExpiration: expiresAt.Unix(),
+	}, slack.profile("U123"))
+	assert.Equal(t, &SlackStatus{
+		UserID:    "U123",
+		Text:      "Out of office",
+		Emoji:     ":palm_tree:",
+		ExpiresAt: "2025-12-12T05:00:00Z",
+	}, mustGetSyncedRequest(t, store, "request-123").SlackStatus)
+
+	// Withdrawing it clears the status.
+	withdrawn := req
+	withdrawn.Status.StatusType = ClockifyStatusWithdrawn
+	withdrawn.Status.ChangedAt = now.Format(time.RFC3339)
+
+	report, err = syncer.SyncRequests(ctx, withdrawn)
+	require.NoError(t, err)
+	assert.Equal(t, "cleared", report.Results[0].SlackStatus)
+	assert.Equal(t, slackProfileStatus{}, slack.profile("U123"))
+	assert.Nil(t, mustGetSyncedRequest(t, store, "request-123").SlackStatus)
+}
+
+func TestSyncer_SetsSlackStatusWhenTimeOffStarts(t *testing.T) {
+	ctx := context.Background()
+	slack := newFakeSlack(t, map[string]string{"fixture@example.com": "U123"})
+	store := NewMemoryStore()
+
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	syncer := NewSyncer(
+		"ws", nil, &fakeCalendarSink{}, store,
+		WithClock(func() time.Time { return now }),
+		WithSlackStatuses(slack.updater()),
+	)
+
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, now)
+	pending := makeStatusRequest("pending", ClockifyStatusPending, now)
+
+	report, err := syncer.SyncRequests(ctx, req, pending)
+	require.NoError(t, err)
+	assert.Zero(t, report.SlackStatuses)
+	assert.Zero(t, slack.sets)
+
+	set, err := syncer.SyncSlackStatuses(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, set)
+
+	// Once the day starts, the next pass sets it, and only once.
+	now = time.Date(2025, 12, 10, 9, 0, 0, 0, time.UTC)
+
+	set, err = syncer.SyncSlackStatuses(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, set)
+	assert.Equal(t, "Out of office", slack.profile("U123").StatusText)
+	assert.Equal(t, "2025-12-11T00:00:00Z", mustGetSyncedRequest(t, store, "request-123").SlackStatus.ExpiresAt)
+
+	set, err = syncer.SyncSlackStatuses(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, set)
+	assert.Equal(t, 1, slack.sets)
+
+	// A status the user has changed since is left alone when the request
+	// is rejected.
+	slack.setProfile("U123", slackProfileStatus{StatusText: "Back early", StatusEmoji: ":wave:"})
+	rejected := req
+	rejected.Status.StatusType = ClockifyStatusRejected
+	rejected.Status.ChangedAt = now.Format(time.RFC3339)
+
+	_, err = syncer.SyncRequests(ctx, rejected)
+	require.NoError(t, err)
+	assert.Equal(t, "Back early", slack.profile("U123").StatusText)
+	assert.Nil(t, mustGetSyncedRequest(t, store, "request-123").SlackStatus)
+}
+
+func TestSlackStatusUpdater_ReportsSlackErrors(t *testing.T) {
+	slack := newFakeSlack(t, nil)
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, time.Now())
+
+	_, err := slack.updater().SyncStatus(context.Background(), req, nil, time.Date(2025, 12, 10, 12, 0, 0, 0, time.UTC))
+
+	var slackErr *SlackError
+	require.True(t, errors.As(err, &slackErr))
+	assert.Equal(t, "users_not_found", slackErr.Code)
+}
+
+// periodEndIndexlessStore is a store whose table lacks the period-end index.
+type periodEndIndexlessStore struct {
+	*MemoryStore
+}
+
+func (periodEndIndexlessStore) ListSyncedRequestsEndingAfter(context.Context, string, time.Time) ([]*SyncedClockifyRequest, error) {
+	return nil, errors.New("the table does not have the specified index")
+}
+
+func TestSyncer_ReportsSlackStatusPassFailures(t *testing.T) {
+	ctx := context.Background()
+	slack := newFakeSlack(t, map[string]string{"fixture@example.com": "U123"})
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("request-123", ClockifyStatusApproved, now.Add(-time.Hour)),
+	}}
+	syncer := NewSyncer(
+		"ws", source, &fakeCalendarSink{}, periodEndIndexlessStore{NewMemoryStore()},
+		WithClock(func() time.Time { return now }),
+		WithSlackStatuses(slack.updater()),
+	)
+
+	report, err := syncer.Sync(ctx, SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, []string{
+		"Slack statuses: list approved requests: the table does not have the specified index",
+	}, report.Warnings)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SyncStateStore records which Clockify requests have been synced and the
+// calendar events that represent them. *DynamoStore and *MemoryStore
+// implement it.
+type SyncStateStore interface {
+	// GetSyncedRequest returns nil, nil when nothing has been stored for the
+	// request.
+	GetSyncedRequest(ctx context.Context, clockifyRequestID string) (*SyncedClockifyRequest, error)
+	// PutSyncedRequest creates or replaces the record for item's request.
+	// item.Version must be the version of the stored record it replaces, or
+	// zero to create one; otherwise nothing is written and a *ConflictError
+	// is returned. On success item.Version is the new stored version.
+	PutSyncedRequest(ctx context.Context, item *SyncedClockifyRequest) error
+	// DeleteSyncedRequest removes a record. Deleting a missing record is not
+	// an error.
+	DeleteSyncedRequest(ctx context.Context, clockifyRequestID string) error
+	// ListSyncedRequests returns every stored record, in no particular order.
+	ListSyncedRequests(ctx context.Context) ([]*SyncedClockifyRequest, error)
+	// ListSyncedRequestsEndingAfter returns the records with the given
+	// Clockify status whose period ends after after, in no particular order.
+	// Unlike ListSyncedRequests it doesn't read the whole store.
+	ListSyncedRequestsEndingAfter(ctx context.Context, status string, after time.Time) ([]*SyncedClockifyRequest, error)
+	// ListDueRetries returns the failed records whose retry is due at now,
+	// and ListDeadLetters the dead-lettered ones, in no particular order.
+	// Neither reads the whole store.
+	ListDueRetries(ctx context.Context, now time.Time) ([]*SyncedClockifyRequest, error)
+	ListDeadLetters(ctx context.Context) ([]*SyncedClockifyRequest, error)
+
+	// GetWatermark returns the named high-water mark, or the zero time when
+	// none has been stored.
+	GetWatermark(ctx context.Context, name string) (time.Time, error)
+	// PutWatermark creates or replaces the named high-water mark.
+	PutWatermark(ctx context.Context, name string, t time.Time) error
+
+	// ClaimSyncedRequest leases a request to owner until until, so that
+	// overlapping runs don't process it at once. Claiming a request owner
+	// already holds extends the lease. It returns a *ConflictError while
+	// another owner holds an unexpired lease.
+	ClaimSyncedRequest(ctx context.Context, clockifyRequestID, owner string, now, until time.Time) error
+	// ReleaseSyncedRequest ends owner's lease early. Releasing a lease owner
+	// doesn't hold is not an error.
+	ReleaseSyncedRequest(ctx context.Context, clockifyRequestID, owner string) error
+}
+
+// ConflictError is returned when a sync loses a race with another: the
+// record changed since it was read, or another run holds the request's
+// lease. The loser should skip the request and leave it to the winner.
+type ConflictError struct {
+	ClockifyRequestID string
+	Reason            string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict on Clockify request %s: %s", e.ClockifyRequestID, e.Reason)
+}
+
+var (
+	_ SyncStateStore = (*DynamoStore)(nil)
+	_ SyncStateStore = (*MemoryStore)(nil)
+)
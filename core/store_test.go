@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSyncStateStore is the behaviour every SyncStateStore backend must
+// share. newStore must return an empty store each time it is called.
+func testSyncStateStore(t *testing.T, newStore func(t *testing.T) SyncStateStore) {
+	ctx := context.Background()
+
+	item := func(id string) *SyncedClockifyRequest {
+		return &SyncedClockifyRequest{
+			ClockifyRequestID: id,
+			UserEmail:         "person@example.com",
+			Status:            ClockifyStatusApproved,
+			PeriodStart:       "2026-06-10T00:00:00Z",
+			PeriodEnd:         "2026-06-12T00:00:00Z",
+			PolicyName:        "Vacation",
+			CreatedAt:         "2026-06-08T10:00:00Z",
+			LastSeenAt:        "2026-06-08T12:00:00Z",
+			SyncState:         "synced",
+			GoogleCalendarEvents: []GoogleCalendarEvent{
+				{CalendarID: "primary", EventID: "event-" + id},
+			},
+		}
+	}
+
+	t.Run("get missing returns nil", func(t *testing.T) {
+		store := newStore(t)
+
+		got, err := store.GetSyncedRequest(ctx, "missing")
+
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("put then get round trips", func(t *testing.T) {
+		store := newStore(t)
+		want := item("request-1")
+
+		require.NoError(t, store.PutSyncedRequest(ctx, want))
+		assert.Equal(t, int64(1), want.Version)
+		got, err := store.GetSyncedRequest(ctx, "request-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("put replaces the version it read", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, store.PutSyncedRequest(ctx, item("request-1")))
+
+		replacement, err := store.GetSyncedRequest(ctx, "request-1")
+		require.NoError(t, err)
+		replacement.Status = ClockifyStatusRejected
+		replacement.GoogleCalendarEvents = nil
+		require.NoError(t, store.PutSyncedRequest(ctx, replacement))
+		assert.Equal(t, int64(2), replacement.Version)
+
+		got, err := store.GetSyncedRequest(ctx, "request-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, ClockifyStatusRejected, got.Status)
+		assert.Empty(t, got.GoogleCalendarEvents)
+		assert.Equal(t, int64(2), got.Version)
+	})
+
+	t.Run("put of a stale version conflicts", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, store.PutSyncedRequest(ctx, item("request-1")))
+
+		// A second insert, and a write based on an outdated read, both lose.
+		var conflict *ConflictError
+		require.ErrorAs(t, store.PutSyncedRequest(ctx, item("request-1")), &conflict)
+		assert.Equal(t, "request-1", conflict.ClockifyRequestID)
+
+		first, err := store.GetSyncedRequest(ctx, "request-1")
+		require.NoError(t, err)
+		second, err := store.GetSyncedRequest(ctx, "request-1")
+		require.NoError(t, err)
+
+		first.Status = ClockifyStatusRejected
+		require.NoError(t, store.PutSyncedRequest(ctx, first))
+		second.Status = ClockifyStatusWithdrawn
+		require.ErrorAs(t, store.PutSyncedRequest(ctx, second), &conflict)
+		assert.Equal(t, int64(1), second.Version, "a failed put leaves the version alone")
+
+		got, err := store.GetSyncedRequest(ctx, "request-1")
+		require.NoError(t, err)
+		assert.Equal(t, ClockifyStatusRejected, got.Status)
+	})
+
+	t.Run("leases exclude other owners until released or expired", func(t *testing.T) {
+		store := newStore(t)
+		now := time.Date(2026, 6, 8, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, store.ClaimSyncedRequest(ctx, "request-1", "run-a", now, now.Add(time.Minute)))
+		require.NoError(t, store.ClaimSyncedRequest(ctx, "request-1", "run-a", now, now.Add(2*time.Minute)))
+		require.NoError(t, store.ClaimSyncedRequest(ctx, "request-2", "run-b", now, now.Add(time.Minute)))
+
+		var conflict *ConflictError
+		require.ErrorAs(t, store.ClaimSyncedRequest(ctx, "request-1", "run-b", now, now.Add(time.Minute)), &conflict)
+
+		// Releasing someone else's lease does nothing.
+		require.NoError(t, store.ReleaseSyncedRequest(ctx, "request-1", "run-b"))
+		require.ErrorAs(t, store.ClaimSyncedRequest(ctx, "request-1", "run-b", now, now.Add(time.Minute)), &conflict)
+
+		require.NoError(t, store.ReleaseSyncedRequest(ctx, "request-1", "run-a"))
+		require.NoError(t, store.ClaimSyncedRequest(ctx, "request-1", "run-b", now, now.Add(time.Minute)))
+
+		// An expired lease can be taken over.
+		later := now.Add(time.Hour)
+		require.NoError(t, store.ClaimSyncedRequest(ctx, "request-1", "run-a", later, later.Add(time.Minute)))
+
+		items, err := store.ListSyncedRequests(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, items, "leases stay out of the list")
+	})
+
+	t.Run("delete removes and tolerates missing", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, store.PutSyncedRequest(ctx, item("request-1")))
+
+		require.NoError(t, store.DeleteSyncedRequest(ctx, "request-1"))
+		require.NoError(t, store.DeleteSyncedRequest(ctx, "request-1"))
+
+		got, err := store.GetSyncedRequest(ctx, "request-1")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("list returns every record", func(t *testing.T) {
+		store := newStore(t)
+
+		got, err := store.ListSyncedRequests(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		first, second := item("request-1"), item("request-2")
+		require.NoError(t, store.PutSyncedRequest(ctx, first))
+		require.NoError(t, store.PutSyncedRequest(ctx, second))
+
+		got, err = store.ListSyncedRequests(ctx)
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*SyncedClockifyRequest{first, second}, got)
+	})
+
+	t.Run("list ending after queries by status and period end", func(t *testing.T) {
+		store := newStore(t)
+
+		ended, current, rejected := item("request-1"), item("request-2"), item("request-3")
+		ended.PeriodEnd = "2026-06-09T00:00:00Z"
+		rejected.Status = ClockifyStatusRejected
+		for _, rec := range []*SyncedClockifyRequest{ended, current, rejected} {
+			require.NoError(t, store.PutSyncedRequest(ctx, rec))
+		}
+		require.NoError(t, store.PutWatermark(ctx, "activity/ws", time.Now()))
+
+		got, err := store.ListSyncedRequestsEndingAfter(ctx, ClockifyStatusApproved, time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC))
+
+		require.NoError(t, err)
+		assert.Equal(t, []*SyncedClockifyRequest{current}, got)
+	})
+
+	t.Run("retries and dead letters are queried by state", func(t *testing.T) {
+		store := newStore(t)
+		now := time.Date(2026, 6, 8, 12, 0, 0, 0, time.UTC)
+
+		due, waiting, dead, synced := item("request-1"), item("request-2"), item("request-3"), item("request-4")
+		due.SyncState, due.Attempts, due.NextRetryAt = SyncStateFailed, 1, "2026-06-08T11:55:00Z"
+		waiting.SyncState, waiting.Attempts, waiting.NextRetryAt = SyncStateFailed, 2, "2026-06-08T12:05:00Z"
+		dead.SyncState, dead.Attempts = SyncStateDeadLetter, 5
+		for _, rec := range []*SyncedClockifyRequest{due, waiting, dead, synced} {
+			require.NoError(t, store.PutSyncedRequest(ctx, rec))
+		}
+
+		got, err := store.ListDueRetries(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, []*SyncedClockifyRequest{due}, got)
+
+		got, err = store.ListDeadLetters(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*SyncedClockifyRequest{dead}, got)
+
+		// A record that syncs leaves both.
+		due.SyncState, due.Attempts, due.NextRetryAt = SyncStateSynced, 0, ""
+		require.NoError(t, store.PutSyncedRequest(ctx, due))
+
+		got, err = store.ListDueRetries(ctx, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []*SyncedClockifyRequest{waiting}, got)
+	})
+
+	t.Run("watermarks round trip and stay out of the list", func(t *testing.T) {
+		store := newStore(t)
+
+		got, err := store.GetWatermark(ctx, "activity/ws")
+		require.NoError(t, err)
+		assert.True(t, got.IsZero())
+
+		mark := time.Date(2026, 6, 8, 12, 30, 0, 123000000, time.UTC)
+		require.NoError(t, store.PutWatermark(ctx, "activity/ws", mark))
+		require.NoError(t, store.PutWatermark(ctx, "activity/other", mark.Add(-time.Hour)))
+		require.NoError(t, store.PutSyncedRequest(ctx, item("request-1")))
+
+		got, err = store.GetWatermark(ctx, "activity/ws")
+		require.NoError(t, err)
+		assert.True(t, mark.Equal(got), "got %s", got)
+
+		items, err := store.ListSyncedRequests(ctx)
+		require.NoError(t, err)
+		assert.Len(t, items, 1)
+
+		require.Error(t, store.PutWatermark(ctx, "", mark))
+	})
+
+	t.Run("missing ID is rejected", func(t *testing.T) {
+		store := newStore(t)
+
+		require.Error(t, store.PutSyncedRequest(ctx, &SyncedClockifyRequest{}))
+		require.Error(t, store.DeleteSyncedRequest(ctx, ""))
+		_, err := store.GetSyncedRequest(ctx, "")
+		require.Error(t, err)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testSyncStateStore(t, func(t *testing.T) SyncStateStore {
+		return NewMemoryStore()
+	})
+}
+
+func TestMemoryStore_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	item := &SyncedClockifyRequest{
+		ClockifyRequestID:    "request-1",
+		GoogleCalendarEvents: []GoogleCalendarEvent{{CalendarID: "primary", EventID: "event-1"}},
+	}
+	require.NoError(t, store.PutSyncedRequest(ctx, item))
+
+	item.GoogleCalendarEvents[0].EventID = "mutated"
+	got, err := store.GetSyncedRequest(ctx, "request-1")
+	require.NoError(t, err)
+	got.Status = "mutated"
+
+	again, err := store.GetSyncedRequest(ctx, "request-1")
+	require.NoError(t, err)
+	assert.Equal(t, "event-1", again.GoogleCalendarEvents[0].EventID)
+	assert.Empty(t, again.Status)
+}
+
+// TestDynamoStore runs the conformance suite against DynamoDB Local when
+// DYNAMODB_LOCAL_ENDPOINT is set, e.g. http://localhost:8000.
+func TestDynamoStore(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT not set")
+	}
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "local", SecretAccessKey: "local"}, nil
+		}),
+	})
+
+	testSyncStateStore(t, func(t *testing.T) SyncStateStore {
+		ctx := context.Background()
+		tableName := fmt.Sprintf("ooo-sync-test-%d", time.Now().UnixNano())
+
+		_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+			TableName: aws.String(tableName),
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String("ClockifyRequestId"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("Status"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("PeriodEnd"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("RetryState"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("ClockifyRequestId"), KeyType: types.KeyTypeHash},
+			},
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String(dynamoPeriodEndIndex),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("Status"), KeyType: types.KeyTypeHash},
+						{AttributeName: aws.String("PeriodEnd"), KeyType: types.KeyTypeRange},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+				{
+					IndexName: aws.String(dynamoRetryIndex),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("RetryState"), KeyType: types.KeyTypeHash},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+			BillingMode: types.BillingModePayPerRequest,
+		})
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			_, _ = client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+		})
+
+		return NewDynamoStore(client, tableName)
+	})
+}
@@ -0,0 +1,947 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClockifySource lists time-off requests. *ClockifyClient implements it.
+type ClockifySource interface {
+	ListAllTimeOffRequests(
+		ctx context.Context,
+		workspaceID string,
+		payload ClockifyRequestPayload,
+	) (ClockifyEnvelope, error)
+}
+
+// CalendarSink applies a queued request to the calendars it belongs on and
+// returns the events that now represent it.
+type CalendarSink interface {
+	SyncOOORequest(
+		ctx context.Context,
+		req RequestToProcess,
+		opts SyncOptions,
+	) ([]GoogleCalendarEvent, error)
+}
+
+// BackendCalendarSink syncs requests to the calendars Calendars routes them
+// to through a CalendarBackend.
+type BackendCalendarSink struct {
+	Backend   CalendarBackend
+	Calendars CalendarRouting
+}
+
+func (s *BackendCalendarSink) SyncOOORequest(
+	ctx context.Context,
+	req RequestToProcess,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	return SyncOOORequest(ctx, s.Backend, req, s.Calendars.targetsFor(req.Request), opts)
+}
+
+// SyncWindow selects the requests a sync looks at.
+type SyncWindow struct {
+	// PeriodStart and PeriodEnd bound the time-off period Clockify is asked
+	// for. A zero value leaves that side unbounded.
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+
+	// ActivityStart and ActivityEnd select requests created or whose status
+	// changed in [ActivityStart, ActivityEnd). A zero ActivityStart has no
+	// lower bound and a zero ActivityEnd means now.
+	ActivityStart time.Time
+	ActivityEnd   time.Time
+}
+
+// Report summarizes a sync run.
+type Report struct {
+	Fetched int `json:"fetched"`
+	Queued  int `json:"queued"`
+	Skipped int `json:"skipped"`
+	Synced  int `json:"synced"`
+	Failed  int `json:"failed"`
+
+	// Conflicts are requests another run was processing at the same time;
+	// that run syncs them.
+	Conflicts int `json:"conflicts"`
+
+	// Unmapped requests belong to Clockify users with no known Google
+	// account. They are left unsynced without failing the run.
+	Unmapped      int      `json:"unmapped"`
+	UnmappedUsers []string `json:"unmappedUsers,omitempty"`
+
+	// Calendar actions taken by the requests that synced successfully.
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+
+	// Retried are failed requests retried because their retry was due,
+	// whatever the window.
+	Retried int `json:"retried"`
+	// DeadLettered counts the requests that failed for the last time in
+	// this run. DeadLetters lists every dead-lettered request the run knows
+	// of; they are not retried until they change in Clockify.
+	DeadLettered int      `json:"deadLettered"`
+	DeadLetters  []string `json:"deadLetters,omitempty"`
+
+	// SlackStatuses counts the Slack statuses set or cleared.
+	SlackStatuses int `json:"slackStatuses,omitempty"`
+
+	// Warnings are failures of a pass over the store, such as the retry
+	// pass or the Slack statuses, that didn't stop the run syncing its
+	// requests.
+	Warnings []string `json:"warnings,omitempty"`
+
+	Results []RequestResult `json:"results,omitempty"`
+
+	// DryRun reports that nothing was written: Results describe what a real
+	// run would have done.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RequestResult is the outcome for one queued request.
+type RequestResult struct {
+	RequestID string     `json:"requestId"`
+	UserEmail string     `json:"userEmail"`
+	Status    string     `json:"status"`
+	Action    SyncAction `json:"action"`
+	Reason    string     `json:"reason,omitempty"`
+	Error     string     `json:"error,omitempty"`
+
+	// GoogleUser is the account synced to, when it differs from UserEmail.
+	GoogleUser string `json:"googleUser,omitempty"`
+	// SlackStatus is "set" or "cleared" when the user's Slack status
+	// changed.
+	SlackStatus string `json:"slackStatus,omitempty"`
+
+	// Attempts is how many times a failed request has now failed, and
+	// DeadLettered whether it was given up on.
+	Attempts     int  `json:"attempts,omitempty"`
+	DeadLettered bool `json:"deadLettered,omitempty"`
+
+	// Changes are the calendar writes a dry run planned for the request.
+	Changes []CalendarChange `json:"changes,omitempty"`
+}
+
+// PartialSyncError is returned by Syncer.Sync when some requests could not
+// be synced. Every other request in the run was still processed.
+type PartialSyncError struct {
+	Failed int
+	Err    error
+}
+
+func (e *PartialSyncError) Error() string {
+	return fmt.Sprintf("sync completed with %d failed requests: %v", e.Failed, e.Err)
+}
+
+func (e *PartialSyncError) Unwrap() error {
+	return e.Err
+}
+
+// Syncer fetches Clockify requests, decides which need work, applies them to
+// calendars and records the result.
+type Syncer struct {
+	WorkspaceID string
+	Source      ClockifySource
+	Calendar    CalendarSink
+	Store       SyncStateStore
+	Clock       func() time.Time
+
+	// PageSize is the Clockify page size; zero uses the client default.
+	PageSize int
+	// Users restricts the sync to these Clockify user IDs when set.
+	Users []string
+	// Statuses are the request statuses fetched; nil means ClockifyStatuses.
+	Statuses []string
+	Options  SyncOptions
+
+	// Resolver maps Clockify users to the Google accounts to sync to; nil
+	// uses the Clockify email as is.
+	Resolver UserResolver
+
+	// Slack, when set, mirrors approved time off in the user's Slack status.
+	Slack *SlackStatusUpdater
+
+	// Concurrency is how many users' requests are processed at once. Each
+	// user's requests are still processed one at a time, in order.
+	Concurrency int
+
+	// DryRun plans each request without writing calendars, sync records or
+	// the watermark. Calendar should be a *DryRunSink so that lookups still
+	// happen and the planned changes are reported.
+	DryRun bool
+
+	// WatermarkOverlap is how far before the stored watermark
+	// SyncSinceWatermark starts, to catch activity Clockify recorded late.
+	WatermarkOverlap time.Duration
+
+	// LeaseOwner identifies this Syncer when it claims a request in the
+	// store, and LeaseDuration is how long a claim lasts if it isn't
+	// released, e.g. because the process died.
+	LeaseOwner    string
+	LeaseDuration time.Duration
+
+	// Retry decides when requests that failed to sync are retried, and when
+	// they are given up on.
+	Retry RetryPolicy
+}
+
+const (
+	defaultWatermarkOverlap = 15 * time.Minute
+	defaultLeaseDuration    = 5 * time.Minute
+)
+
+func NewSyncer(
+	workspaceID string,
+	source ClockifySource,
+	calendar CalendarSink,
+	store SyncStateStore,
+	opts ...func(*Syncer),
+) *Syncer {
+	s := &Syncer{
+		WorkspaceID: workspaceID,
+		Source:      source,
+		Calendar:    calendar,
+		Store:       store,
+		Clock:       time.Now,
+		Concurrency: 1,
+
+		WatermarkOverlap: defaultWatermarkOverlap,
+		LeaseOwner:       newLeaseOwner(),
+		LeaseDuration:    defaultLeaseDuration,
+		Retry:            defaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func WithClock(now func() time.Time) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Clock = now
+	}
+}
+
+func WithPageSize(pageSize int) func(*Syncer) {
+	return func(s *Syncer) {
+		s.PageSize = pageSize
+	}
+}
+
+func WithUsers(userIDs ...string) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Users = userIDs
+	}
+}
+
+func WithStatuses(statuses ...string) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Statuses = statuses
+	}
+}
+
+func WithUserResolver(resolver UserResolver) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Resolver = resolver
+	}
+}
+
+func WithSlackStatuses(slack *SlackStatusUpdater) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Slack = slack
+	}
+}
+
+func WithSyncOptions(opts SyncOptions) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Options = opts
+	}
+}
+
+func WithConcurrency(n int) func(*Syncer) {
+	return func(s *Syncer) {
+		s.Concurrency = n
+	}
+}
+
+func WithDryRun() func(*Syncer) {
+	return func(s *Syncer) {
+		s.DryRun = true
+	}
+}
+
+func WithWatermarkOverlap(overlap time.Duration) func(*Syncer) {
+	return func(s *Syncer) {
+		s.WatermarkOverlap = overlap
+	}
+}
+
+// WithRetryPolicy sets when failed requests are retried. Zero fields keep
+// their defaults.
+func WithRetryPolicy(policy RetryPolicy) func(*Syncer) {
+	return func(s *Syncer) {
+		if policy.MaxAttempts > 0 {
+			s.Retry.MaxAttempts = policy.MaxAttempts
+		}
+		if policy.Backoff > 0 {
+			s.Retry.Backoff = policy.Backoff
+		}
+		if policy.MaxBackoff > 0 {
+			s.Retry.MaxBackoff = policy.MaxBackoff
+		}
+	}
+}
+
+func WithLeaseDuration(d time.Duration) func(*Syncer) {
+	return func(s *Syncer) {
+		s.LeaseDuration = d
+	}
+}
+
+// newLeaseOwner returns an ID unique to this process and Syncer.
+func newLeaseOwner() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d/%x", host, os.Getpid(), b)
+}
+
+// SyncSinceWatermark syncs the activity since the last fully successful run:
+// window's activity bounds are replaced with [watermark - overlap, now). The
+// first run, with no watermark stored, has no lower bound. The watermark only
+// advances to now when every request synced, so failed requests are picked
+// up again by the next run.
+func (s *Syncer) SyncSinceWatermark(ctx context.Context, window SyncWindow) (Report, error) {
+	name := s.watermarkName()
+
+	watermark, err := s.Store.GetWatermark(ctx, name)
+	if err != nil {
+		return Report{}, fmt.Errorf("get watermark %s: %w", name, err)
+	}
+
+	window.ActivityStart = time.Time{}
+	if !watermark.IsZero() {
+		window.ActivityStart = watermark.Add(-s.WatermarkOverlap)
+	}
+	window.ActivityEnd = s.Clock()
+
+	log.Printf(
+		"Syncing activity since watermark %s: [%s, %s)",
+		name,
+		window.ActivityStart.Format(time.RFC3339),
+		window.ActivityEnd.Format(time.RFC3339),
+	)
+
+	report, err := s.Sync(ctx, window)
+	if err != nil {
+		log.Printf("Not advancing watermark %s: %v", name, err)
+		return report, err
+	}
+	if s.DryRun {
+		return report, nil
+	}
+
+	if err := s.Store.PutWatermark(ctx, name, window.ActivityEnd); err != nil {
+		return report, fmt.Errorf("advance watermark %s: %w", name, err)
+	}
+
+	log.Printf("Advanced watermark %s to %s", name, window.ActivityEnd.Format(time.RFC3339))
+
+	return report, nil
+}
+
+// watermarkName keys the watermark by workspace so that workspaces sharing a
+// store don't advance each other's.
+func (s *Syncer) watermarkName() string {
+	return "activity/" + s.WorkspaceID
+}
+
+// Sync runs one pass over the requests active in window. Failures for
+// individual requests are collected in the report and returned together as a
+// *PartialSyncError; failures that prevent the run as a whole abort it.
+func (s *Syncer) Sync(ctx context.Context, window SyncWindow) (Report, error) {
+	report := Report{DryRun: s.DryRun}
+
+	if window.ActivityEnd.IsZero() {
+		window.ActivityEnd = s.Clock()
+	}
+
+	fetched, err := s.Fetch(ctx, window)
+	if err != nil {
+		return report, err
+	}
+
+	requests := FilterRequestsByActivity(fetched.Requests, window.ActivityStart, window.ActivityEnd)
+	report.Fetched = len(requests)
+
+	// The requests in the window sync even when the retry pass can't run.
+	retries, err := s.dueRetries(ctx, requests, fetched.Requests, &report)
+	if err != nil {
+		log.Printf("Skipping due retries: %v", err)
+		report.Warnings = append(report.Warnings, fmt.Sprintf("retry pass: %v", err))
+	}
+	report.Retried = len(retries)
+	requests = append(requests, retries...)
+
+	report, err = s.syncRequests(ctx, report, requests)
+
+	if s.Slack != nil && !s.DryRun {
+		set, slackErr := s.SyncSlackStatuses(ctx)
+		report.SlackStatuses += set
+		if slackErr != nil {
+			log.Printf("Failed to set some Slack statuses: %v", slackErr)
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Slack statuses: %v", slackErr))
+		}
+	}
+
+	return report, err
+}
+
+// SyncRequests syncs requests received from elsewhere than a fetch, such as
+// a webhook, the same way Sync does. Requests from users or with statuses the
+// Syncer doesn't fetch are ignored.
+func (s *Syncer) SyncRequests(ctx context.Context, requests ...ClockifyRequest) (Report, error) {
+	report := Report{DryRun: s.DryRun}
+
+	statuses := s.Statuses
+	if statuses == nil {
+		statuses = ClockifyStatuses
+	}
+
+	var selected []ClockifyRequest
+	for _, req := range requests {
+		if len(s.Users) > 0 && !slices.Contains(s.Users, req.UserID) {
+			log.Printf("Ignoring Clockify request %s from unselected user %s", req.ID, req.UserID)
+			continue
+		}
+		if !slices.Contains(statuses, req.Status.StatusType) {
+			log.Printf("Ignoring Clockify request %s with unselected status %s", req.ID, req.Status.StatusType)
+			continue
+		}
+		selected = append(selected, req)
+	}
+	report.Fetched = len(selected)
+
+	return s.syncRequests(ctx, report, selected)
+}
+
+// syncRequests queues and processes requests, adding to report.
+func (s *Syncer) syncRequests(ctx context.Context, report Report, requests []ClockifyRequest) (Report, error) {
+	queue, skipped, err := s.queue(ctx, requests)
+	if err != nil {
+		return report, err
+	}
+	report.Queued = len(queue)
+	report.Skipped = len(skipped)
+
+	if s.DryRun {
+		report.Results = append(report.Results, skipped...)
+	}
+
+	var syncErrs []error
+
+	for _, outcome := range s.processAll(ctx, queue) {
+		report.Results = append(report.Results, outcome.result)
+
+		if errors.Is(outcome.err, ErrUserUnmapped) {
+			report.unmapped(outcome.result.UserEmail)
+			continue
+		}
+		var conflict *ConflictError
+		if errors.As(outcome.err, &conflict) {
+			report.Conflicts++
+			continue
+		}
+		if outcome.err == nil && outcome.result.Action == SyncActionSkip {
+			report.Skipped++
+			continue
+		}
+		if outcome.err != nil {
+			report.Failed++
+			if outcome.result.DeadLettered {
+				report.DeadLettered++
+				report.deadLetter(outcome.result.RequestID)
+			}
+			syncErrs = append(syncErrs, outcome.err)
+			continue
+		}
+		report.record(outcome.result.Action)
+		if outcome.result.SlackStatus != "" {
+			report.SlackStatuses++
+		}
+	}
+
+	if len(syncErrs) > 0 {
+		return report, &PartialSyncError{Failed: report.Failed, Err: errors.Join(syncErrs...)}
+	}
+
+	return report, nil
+}
+
+// record counts a successfully synced request.
+func (r *Report) record(action SyncAction) {
+	r.Synced++
+
+	switch action {
+	case SyncActionInsert:
+		r.Inserted++
+	case SyncActionUpdate:
+		r.Updated++
+	case SyncActionDelete:
+		r.Deleted++
+	}
+}
+
+func (r *Report) unmapped(userEmail string) {
+	r.Unmapped++
+	if !slices.Contains(r.UnmappedUsers, userEmail) {
+		r.UnmappedUsers = append(r.UnmappedUsers, userEmail)
+	}
+}
+
+// Fetch returns every request in the window's time-off period, before any
+// activity filtering. It only needs Source to be set.
+func (s *Syncer) Fetch(ctx context.Context, window SyncWindow) (ClockifyEnvelope, error) {
+	fetched, err := s.Source.ListAllTimeOffRequests(ctx, s.WorkspaceID, s.payload(window))
+	if err != nil {
+		return ClockifyEnvelope{}, fmt.Errorf("fetch clockify: %w", err)
+	}
+	return fetched, nil
+}
+
+func (s *Syncer) payload(window SyncWindow) ClockifyRequestPayload {
+	payload := ClockifyRequestPayload{
+		PageSize: s.PageSize,
+		Statuses: ClockifyStatuses,
+		Users:    s.Users,
+	}
+	if s.Statuses != nil {
+		payload.Statuses = s.Statuses
+	}
+	if !window.PeriodStart.IsZero() {
+		start := formatClockify(window.PeriodStart)
+		payload.Start = &start
+	}
+	if !window.PeriodEnd.IsZero() {
+		end := formatClockify(window.PeriodEnd)
+		payload.End = &end
+	}
+	return payload
+}
+
+// queue pairs each request with its synced record and keeps the ones whose
+// calendar state may be out of date. The rest are returned as skipped.
+func (s *Syncer) queue(ctx context.Context, requests []ClockifyRequest) ([]RequestToProcess, []RequestResult, error) {
+	var requestsToProcess []RequestToProcess
+	var skipped []RequestResult
+
+	for _, req := range requests {
+		existing, err := s.Store.GetSyncedRequest(ctx, req.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get synced request %s: %w", req.ID, err)
+		}
+
+		needsSync, reason := s.needsSync(existing, req)
+		if !needsSync {
+			log.Printf("Skipping Clockify request %s: %s", req.ID, reason)
+			skipped = append(skipped, RequestResult{
+				RequestID: req.ID,
+				UserEmail: req.UserEmail,
+				Status:    req.Status.StatusType,
+				Action:    SyncActionSkip,
+				Reason:    reason,
+			})
+			continue
+		}
+
+		log.Printf("Queueing Clockify request %s: %s", req.ID, reason)
+
+		requestsToProcess = append(requestsToProcess, RequestToProcess{
+			Request:        req,
+			ExistingRecord: existing,
+		})
+	}
+
+	return requestsToProcess, skipped, nil
+}
+
+// NeedsSync reports whether req has to be (re)applied to calendars given
+// what was last synced for it, and why.
+func NeedsSync(existing *SyncedClockifyRequest, req ClockifyRequest) (bool, string) {
+	currentStatus := req.Status.StatusType
+
+	if existing == nil {
+		return true, fmt.Sprintf("new request with status %s", currentStatus)
+	}
+
+	if existing.Status != currentStatus {
+		if existing.statusNewerThan(req) {
+			return false, fmt.Sprintf(
+				"status %s from %s is older than the synced %s from %s",
+				currentStatus,
+				req.Status.ChangedAt,
+				existing.Status,
+				existing.StatusChangedAt,
+			)
+		}
+		return true, fmt.Sprintf("status changed from %s to %s", existing.Status, currentStatus)
+	}
+
+	if existing.DetailsChanged(req) {
+		return true, fmt.Sprintf(
+			"period or policy changed (%s → %s)",
+			existing.PeriodStart,
+			req.TimeOffPeriod.Period.Start,
+		)
+	}
+
+	return false, fmt.Sprintf("status %s has already been processed", currentStatus)
+}
+
+type processOutcome struct {
+	result RequestResult
+	err    error
+}
+
+// processAll processes the queue with up to Concurrency workers, handing
+// each worker all of one account's requests so that no two of them race on
+// the same calendars. Users are resolved first, as several Clockify users
+// may map to one account. Outcomes are returned in queue order.
+func (s *Syncer) processAll(ctx context.Context, queue []RequestToProcess) []processOutcome {
+	outcomes := make([]processOutcome, len(queue))
+
+	accounts := s.resolveUsers(ctx, queue)
+	var users []string
+	byUser := make(map[string][]int)
+	for i, req := range queue {
+		// Requests whose user didn't resolve write nothing; keeping them
+		// with their Clockify email is as good as any group.
+		user := strings.ToLower(req.Request.UserEmail)
+		if accounts[i].err == nil {
+			user = strings.ToLower(accounts[i].email)
+		}
+		if _, ok := byUser[user]; !ok {
+			users = append(users, user)
+		}
+		byUser[user] = append(byUser[user], i)
+	}
+
+	workers := min(max(s.Concurrency, 1), len(users))
+	work := make(chan []int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for indexes := range work {
+				for _, i := range indexes {
+					result, err := s.process(ctx, queue[i], accounts[i])
+					outcomes[i] = processOutcome{result: result, err: err}
+				}
+			}
+		}()
+	}
+
+	for _, user := range users {
+		work <- byUser[user]
+	}
+	close(work)
+	wg.Wait()
+
+	return outcomes
+}
+
+// resolveUsers resolves the account of each queued request. Each Clockify
+// user is looked up once, up to Concurrency of them at a time, since lookups
+// can go over the network.
+func (s *Syncer) resolveUsers(ctx context.Context, queue []RequestToProcess) []resolvedUser {
+	type clockifyUser struct {
+		id, email string
+	}
+
+	var users []clockifyUser
+	byUser := make(map[clockifyUser][]int)
+	for i, req := range queue {
+		user := clockifyUser{id: req.Request.UserID, email: strings.ToLower(req.Request.UserEmail)}
+		if _, ok := byUser[user]; !ok {
+			users = append(users, user)
+		}
+		byUser[user] = append(byUser[user], i)
+	}
+
+	accounts := make([]resolvedUser, len(queue))
+	workers := min(max(s.Concurrency, 1), len(users))
+	work := make(chan []int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for indexes := range work {
+				account := s.resolveUser(ctx, queue[indexes[0]].Request)
+				for _, i := range indexes {
+					accounts[i] = account
+				}
+			}
+		}()
+	}
+
+	for _, user := range users {
+		work <- byUser[user]
+	}
+	close(work)
+	wg.Wait()
+
+	return accounts
+}
+
+// resolvedUser is the account a request's events are written as, or why
+// there is none.
+type resolvedUser struct {
+	email string
+	err   error
+}
+
+func (s *Syncer) resolveUser(ctx context.Context, r ClockifyRequest) resolvedUser {
+	if s.Resolver == nil {
+		return resolvedUser{email: r.UserEmail}
+	}
+	email, err := s.Resolver.ResolveUser(ctx, r.UserID, r.UserEmail)
+	return resolvedUser{email: email, err: err}
+}
+
+// process syncs one request to calendars, as account, and records the
+// outcome.
+func (s *Syncer) process(ctx context.Context, req RequestToProcess, account resolvedUser) (RequestResult, error) {
+	result := RequestResult{
+		RequestID: req.Request.ID,
+		UserEmail: req.Request.UserEmail,
+		Status:    req.Request.Status.StatusType,
+	}
+
+	fail := func(err error) (RequestResult, error) {
+		result.Error = err.Error()
+		return result, err
+	}
+	// failSync fails a claimed request, recording the failure so that the
+	// request is retried.
+	failSync := func(err error) (RequestResult, error) {
+		if !s.DryRun {
+			s.recordFailure(ctx, req, &result, err)
+		}
+		return fail(err)
+	}
+
+	if !s.DryRun {
+		existing, err := s.claim(ctx, req.Request.ID)
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			log.Printf("Skipping Clockify request %s: %v", req.Request.ID, err)
+			result.Action = SyncActionSkip
+			result.Reason = conflict.Reason
+			return result, err
+		}
+		if err != nil {
+			log.Printf("Failed to claim Clockify request %s: %v", req.Request.ID, err)
+			return fail(fmt.Errorf("claim request %s: %w", req.Request.ID, err))
+		}
+		defer s.release(ctx, req.Request.ID)
+
+		// Another run may have synced the request since it was queued.
+		if needsSync, reason := s.needsSync(existing, req.Request); !needsSync {
+			log.Printf("Skipping Clockify request %s: %s", req.Request.ID, reason)
+			result.Action = SyncActionSkip
+			result.Reason = reason
+			return result, nil
+		}
+		req.ExistingRecord = existing
+	}
+
+	switch err := account.err; {
+	case errors.Is(err, ErrUserUnmapped):
+		log.Printf("Not syncing Clockify request %s: %v", req.Request.ID, err)
+		result.Action = SyncActionNone
+		result.Reason = err.Error()
+		return result, err
+	case err != nil:
+		log.Printf("Failed to resolve the Google account for Clockify request %s: %v", req.Request.ID, err)
+		return failSync(fmt.Errorf("resolve user for request %s: %w", req.Request.ID, err))
+	}
+
+	if account.email != req.Request.UserEmail {
+		result.GoogleUser = account.email
+	}
+	// Events, and the record's UserEmail, belong to the Google account.
+	req.Request.UserEmail = account.email
+
+	action, err := PlanSyncAction(req, s.Options)
+	if err != nil {
+		log.Printf("Failed to plan Clockify request %s: %v", req.Request.ID, err)
+		return failSync(fmt.Errorf("plan request %s: %w", req.Request.ID, err))
+	}
+	result.Action = action
+
+	calendarEvents, err := s.Calendar.SyncOOORequest(ctx, req, s.Options)
+
+	if planner, ok := s.Calendar.(*DryRunSink); ok {
+		result.Changes = planner.Changes(req.Request.ID)
+	}
+
+	if err != nil {
+		log.Printf("Failed to sync Clockify request %s: %v", req.Request.ID, err)
+		return failSync(fmt.Errorf("sync request %s: %w", req.Request.ID, err))
+	}
+
+	if s.DryRun {
+		log.Printf("Planned Clockify request %s (%s); dry run, not storing", req.Request.ID, action)
+		return result, nil
+	}
+
+	log.Printf("Successfully synced Clockify request %s to calendar (%s)", req.Request.ID, action)
+
+	item, err := req.Request.ToDynamoItem(WithLastSeenAt(s.Clock()))
+	if err != nil {
+		log.Printf("Failed to convert Clockify request %s to a sync record: %v", req.Request.ID, err)
+		return fail(fmt.Errorf("convert request %s to sync record: %w", req.Request.ID, err))
+	}
+
+	item.SyncState = SyncStateSynced
+	item.GoogleCalendarEvents = calendarEvents
+	if req.ExistingRecord != nil {
+		item.Version = req.ExistingRecord.Version
+		item.SlackStatus = req.ExistingRecord.SlackStatus
+	}
+
+	if s.Slack != nil {
+		s.syncSlackStatus(ctx, item, &result)
+	}
+
+	if err := s.Store.PutSyncedRequest(ctx, item); err != nil {
+		log.Printf("Failed to store Clockify request %s: %v", req.Request.ID, err)
+		return fail(fmt.Errorf("store request %s: %w", req.Request.ID, err))
+	}
+
+	log.Printf("Successfully stored Clockify request %s", req.Request.ID)
+
+	return result, nil
+}
+
+// syncSlackStatus updates the Slack status for a request being stored. A
+// failure is only logged: the calendars are already in sync, and a status
+// that couldn't be set is retried by SyncSlackStatuses.
+func (s *Syncer) syncSlackStatus(ctx context.Context, item *SyncedClockifyRequest, result *RequestResult) {
+	before := item.SlackStatus
+
+	status, err := s.Slack.SyncStatus(ctx, item.clockifyRequest(), before, s.Clock())
+	if err != nil {
+		log.Printf("Failed to update the Slack status for Clockify request %s: %v", item.ClockifyRequestID, err)
+	}
+	item.SlackStatus = status
+
+	switch {
+	case status != nil && (before == nil || *status != *before):
+		result.SlackStatus = "set"
+	case status == nil && before != nil:
+		result.SlackStatus = "cleared"
+	}
+}
+
+// SyncSlackStatuses sets the Slack status of users whose approved time off
+// has started since it was synced, and returns how many it set. Sync calls
+// it after every run that has Slack set.
+func (s *Syncer) SyncSlackStatuses(ctx context.Context) (int, error) {
+	now := s.Clock()
+
+	// Only time off that hasn't ended can need a status. Full days end at
+	// midnight in the user's time zone, which can be up to a day after the
+	// period's UTC end.
+	records, err := s.Store.ListSyncedRequestsEndingAfter(ctx, ClockifyStatusApproved, now.Add(-24*time.Hour))
+	if err != nil {
+		return 0, fmt.Errorf("list approved requests: %w", err)
+	}
+
+	set := 0
+	var errs []error
+
+	for _, rec := range records {
+		if rec.SlackStatus != nil {
+			continue
+		}
+		if _, active, err := activeTimeOff(rec.clockifyRequest(), now); err != nil || !active {
+			continue
+		}
+
+		ok, err := s.startSlackStatus(ctx, rec.ClockifyRequestID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			set++
+		}
+	}
+
+	return set, errors.Join(errs...)
+}
+
+// startSlackStatus sets the Slack status for a request under a claim, so
+// that it can't race a sync of the same request, and records it.
+func (s *Syncer) startSlackStatus(ctx context.Context, clockifyRequestID string) (bool, error) {
+	existing, err := s.claim(ctx, clockifyRequestID)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		// The run holding the claim sets the status.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim request %s: %w", clockifyRequestID, err)
+	}
+	defer s.release(ctx, clockifyRequestID)
+
+	if existing == nil || existing.SlackStatus != nil {
+		return false, nil
+	}
+
+	status, err := s.Slack.SyncStatus(ctx, existing.clockifyRequest(), nil, s.Clock())
+	if err != nil {
+		return false, fmt.Errorf("request %s: %w", clockifyRequestID, err)
+	}
+	if status == nil {
+		return false, nil
+	}
+
+	existing.SlackStatus = status
+	if err := s.Store.PutSyncedRequest(ctx, existing); err != nil {
+		return true, fmt.Errorf("store request %s: %w", clockifyRequestID, err)
+	}
+	return true, nil
+}
+
+// claim leases a request for processing and returns its record as of the
+// claim.
+func (s *Syncer) claim(ctx context.Context, clockifyRequestID string) (*SyncedClockifyRequest, error) {
+	now := s.Clock()
+	if err := s.Store.ClaimSyncedRequest(ctx, clockifyRequestID, s.LeaseOwner, now, now.Add(s.LeaseDuration)); err != nil {
+		return nil, err
+	}
+	return s.Store.GetSyncedRequest(ctx, clockifyRequestID)
+}
+
+// release gives up a claim, even when ctx has been cancelled. A failure only
+// delays other runs until the lease expires.
+func (s *Syncer) release(ctx context.Context, clockifyRequestID string) {
+	if err := s.Store.ReleaseSyncedRequest(context.WithoutCancel(ctx), clockifyRequestID, s.LeaseOwner); err != nil {
+		log.Printf("Failed to release Clockify request %s: %v", clockifyRequestID, err)
+	}
+}
@@ -0,0 +1,455 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClockifySource struct {
+	requests    []ClockifyRequest
+	lastPayload ClockifyRequestPayload
+}
+
+func (f *fakeClockifySource) ListAllTimeOffRequests(
+	ctx context.Context,
+	workspaceID string,
+	payload ClockifyRequestPayload,
+) (ClockifyEnvelope, error) {
+	f.lastPayload = payload
+	return ClockifyEnvelope{Count: len(f.requests), Requests: f.requests}, nil
+}
+
+type fakeCalendarSink struct {
+	mu     sync.Mutex
+	synced []RequestToProcess
+	fail   map[string]error
+}
+
+func (f *fakeCalendarSink) SyncOOORequest(
+	ctx context.Context,
+	req RequestToProcess,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	if err := f.fail[req.Request.ID]; err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.synced = append(f.synced, req)
+	f.mu.Unlock()
+
+	action, err := PlanSyncAction(req, opts)
+	if err != nil || action == SyncActionDelete || action == SyncActionNone {
+		return nil, err
+	}
+	return []GoogleCalendarEvent{{CalendarID: "primary", EventID: "event-" + req.Request.ID}}, nil
+}
+
+func mustGetSyncedRequest(t *testing.T, store SyncStateStore, id string) *SyncedClockifyRequest {
+	t.Helper()
+
+	item, err := store.GetSyncedRequest(context.Background(), id)
+	require.NoError(t, err)
+	return item
+}
+
+func makeStatusRequest(id, status string, changedAt time.Time) ClockifyRequest {
+	r := makeRequestWithActivityTimestamps(
+		id,
+		"UTC",
+		"2025-12-10T00:00:00Z",
+		"2025-12-10T23:59:59Z",
+		changedAt,
+		changedAt,
+	)
+	r.Status.StatusType = status
+	return r
+}
+
+func TestSyncer_SyncQueuesAndRecordsChangedRequests(t *testing.T) {
+	now := time.Date(2025, 12, 5, 0, 0, 0, 0, time.UTC)
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+	beforeWindow := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	alreadySynced := makeStatusRequest("already-synced", ClockifyStatusApproved, inWindow)
+	syncedItem, err := alreadySynced.ToDynamoItem()
+	require.NoError(t, err)
+
+	rejected := makeStatusRequest("rejected", ClockifyStatusRejected, inWindow)
+	approvedBefore := rejected
+	approvedBefore.Status.StatusType = ClockifyStatusApproved
+	rejectedItem, err := approvedBefore.ToDynamoItem()
+	require.NoError(t, err)
+	rejectedItem.GoogleCalendarEvents = []GoogleCalendarEvent{{CalendarID: "primary", EventID: "event-rejected"}}
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("new", ClockifyStatusApproved, inWindow),
+		alreadySynced,
+		rejected,
+		makeStatusRequest("outside-window", ClockifyStatusApproved, beforeWindow),
+	}}
+	sink := &fakeCalendarSink{}
+	store := NewMemoryStore()
+	require.NoError(t, store.PutSyncedRequest(context.Background(), syncedItem))
+	require.NoError(t, store.PutSyncedRequest(context.Background(), rejectedItem))
+
+	syncer := NewSyncer("ws", source, sink, store, WithClock(func() time.Time { return now }))
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{
+		ActivityStart: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Fetched)
+	assert.Equal(t, 2, report.Queued)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, report.Synced)
+	assert.Equal(t, 0, report.Failed)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 0, report.Updated)
+	assert.Equal(t, 1, report.Deleted)
+
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, SyncActionInsert, report.Results[0].Action)
+	assert.Equal(t, SyncActionDelete, report.Results[1].Action)
+
+	newItem := mustGetSyncedRequest(t, store, "new")
+	require.NotNil(t, newItem)
+	assert.Equal(t, "synced", newItem.SyncState)
+	assert.Equal(t, "2025-12-05T00:00:00Z", newItem.LastSeenAt)
+	assert.Equal(t, []GoogleCalendarEvent{{CalendarID: "primary", EventID: "event-new"}}, newItem.GoogleCalendarEvents)
+
+	rejectedNow := mustGetSyncedRequest(t, store, "rejected")
+	assert.Equal(t, ClockifyStatusRejected, rejectedNow.Status)
+	assert.Empty(t, rejectedNow.GoogleCalendarEvents)
+
+	assert.Equal(t, ClockifyStatuses, source.lastPayload.Statuses)
+}
+
+func TestSyncer_SyncReportsPerRequestFailures(t *testing.T) {
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("fails", ClockifyStatusApproved, inWindow),
+		makeStatusRequest("succeeds", ClockifyStatusApproved, inWindow),
+	}}
+	sink := &fakeCalendarSink{fail: map[string]error{"fails": errors.New("calendar unavailable")}}
+	store := NewMemoryStore()
+
+	syncer := NewSyncer("ws", source, sink, store)
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{})
+
+	var partial *PartialSyncError
+	require.ErrorAs(t, err, &partial)
+	assert.Equal(t, 1, partial.Failed)
+	assert.Contains(t, err.Error(), "calendar unavailable")
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, "fails", report.Results[0].RequestID)
+	assert.Contains(t, report.Results[0].Error, "calendar unavailable")
+
+	failed := mustGetSyncedRequest(t, store, "fails")
+	require.NotNil(t, failed)
+	assert.Equal(t, SyncStateFailed, failed.SyncState)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.NotNil(t, mustGetSyncedRequest(t, store, "succeeds"))
+}
+
+func TestSyncer_SyncResolvesUsersAndReportsUnmapped(t *testing.T) {
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	mapped := makeStatusRequest("mapped", ClockifyStatusApproved, inWindow)
+	mapped.UserID = "clockify-ada"
+	mapped.UserEmail = "ada.personal@gmail.com"
+	unmapped := makeStatusRequest("unmapped", ClockifyStatusApproved, inWindow)
+	unmapped.UserEmail = "stranger@gmail.com"
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{mapped, unmapped}}
+	sink := &fakeCalendarSink{}
+	store := NewMemoryStore()
+	users := &UserMap{Users: map[string]string{"clockify-ada": "ada@example.com"}}
+
+	syncer := NewSyncer("ws", source, sink, store, WithUserResolver(users))
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 0, report.Failed)
+	assert.Equal(t, 1, report.Unmapped)
+	assert.Equal(t, []string{"stranger@gmail.com"}, report.UnmappedUsers)
+
+	require.Len(t, sink.synced, 1)
+	assert.Equal(t, "ada@example.com", sink.synced[0].Request.UserEmail)
+	assert.Equal(t, "ada@example.com", report.Results[0].GoogleUser)
+	assert.Equal(t, "ada@example.com", mustGetSyncedRequest(t, store, "mapped").UserEmail)
+
+	assert.Contains(t, report.Results[1].Reason, "stranger@gmail.com")
+	assert.Nil(t, mustGetSyncedRequest(t, store, "unmapped"))
+}
+
+func TestSyncer_SyncSkipsRequestsClaimedByAnotherRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 0, 0, 0, 0, time.UTC)
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("claimed", ClockifyStatusApproved, inWindow),
+		makeStatusRequest("free", ClockifyStatusApproved, inWindow),
+	}}
+	sink := &fakeCalendarSink{}
+	store := NewMemoryStore()
+	require.NoError(t, store.ClaimSyncedRequest(ctx, "claimed", "other-run", now, now.Add(time.Minute)))
+
+	syncer := NewSyncer("ws", source, sink, store, WithClock(func() time.Time { return now }))
+
+	report, err := syncer.Sync(ctx, SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Conflicts)
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 0, report.Failed)
+	require.Len(t, sink.synced, 1)
+	assert.Equal(t, "free", sink.synced[0].Request.ID)
+	assert.Nil(t, mustGetSyncedRequest(t, store, "claimed"))
+
+	// The lease on the synced request was released.
+	require.NoError(t, store.ClaimSyncedRequest(ctx, "free", "other-run", now, now.Add(time.Minute)))
+}
+
+func TestSyncer_SyncSinceWatermark(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("old", ClockifyStatusApproved, time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)),
+		makeStatusRequest("recent", ClockifyStatusApproved, now.Add(-time.Hour)),
+	}}
+	sink := &fakeCalendarSink{}
+	store := NewMemoryStore()
+	syncer := NewSyncer("ws", source, sink, store, WithClock(clock), WithWatermarkOverlap(10*time.Minute))
+
+	// Without a watermark the first run has no lower bound.
+	report, err := syncer.SyncSinceWatermark(ctx, SyncWindow{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Fetched)
+
+	mark, err := store.GetWatermark(ctx, "activity/ws")
+	require.NoError(t, err)
+	assert.Equal(t, now, mark)
+
+	// The next run starts at the watermark less the overlap.
+	now = now.Add(time.Hour)
+	source.requests = append(source.requests,
+		makeStatusRequest("in-overlap", ClockifyStatusApproved, mark.Add(-5*time.Minute)),
+		makeStatusRequest("before-overlap", ClockifyStatusApproved, mark.Add(-15*time.Minute)),
+		makeStatusRequest("new", ClockifyStatusApproved, mark.Add(30*time.Minute)),
+	)
+
+	report, err = syncer.SyncSinceWatermark(ctx, SyncWindow{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Fetched)
+	assert.Equal(t, 2, report.Synced)
+
+	mark, err = store.GetWatermark(ctx, "activity/ws")
+	require.NoError(t, err)
+	assert.Equal(t, now, mark)
+}
+
+func TestSyncer_SyncSinceWatermarkHoldsWatermarkOnFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)
+	previous := now.Add(-time.Hour)
+
+	source := &fakeClockifySource{requests: []ClockifyRequest{
+		makeStatusRequest("fails", ClockifyStatusApproved, now.Add(-time.Minute)),
+	}}
+	sink := &fakeCalendarSink{fail: map[string]error{"fails": errors.New("calendar unavailable")}}
+	store := NewMemoryStore()
+	require.NoError(t, store.PutWatermark(ctx, "activity/ws", previous))
+
+	syncer := NewSyncer("ws", source, sink, store, WithClock(func() time.Time { return now }))
+
+	_, err := syncer.SyncSinceWatermark(ctx, SyncWindow{})
+
+	var partial *PartialSyncError
+	require.ErrorAs(t, err, &partial)
+
+	mark, err := store.GetWatermark(ctx, "activity/ws")
+	require.NoError(t, err)
+	assert.Equal(t, previous, mark)
+}
+
+// concurrencySink records how many requests it handles at once, overall and
+// per user.
+type concurrencySink struct {
+	mu         sync.Mutex
+	active     int
+	maxActive  int
+	activeUser map[string]int
+	overlapped bool
+	order      map[string][]string
+}
+
+func (f *concurrencySink) SyncOOORequest(
+	ctx context.Context,
+	req RequestToProcess,
+	opts SyncOptions,
+) ([]GoogleCalendarEvent, error) {
+	user := req.Request.UserEmail
+
+	f.mu.Lock()
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+	f.activeUser[user]++
+	if f.activeUser[user] > 1 {
+		f.overlapped = true
+	}
+	f.order[user] = append(f.order[user], req.Request.ID)
+	f.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	f.mu.Lock()
+	f.active--
+	f.activeUser[user]--
+	f.mu.Unlock()
+
+	return nil, nil
+}
+
+func TestSyncer_ProcessesUsersConcurrentlyAndEachUserInOrder(t *testing.T) {
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	var requests []ClockifyRequest
+	for i := range 12 {
+		req := makeStatusRequest(fmt.Sprintf("request-%02d", i), ClockifyStatusApproved, inWindow)
+		req.UserEmail = fmt.Sprintf("user-%d@example.com", i%4)
+		requests = append(requests, req)
+	}
+
+	source := &fakeClockifySource{requests: requests}
+	sink := &concurrencySink{activeUser: make(map[string]int), order: make(map[string][]string)}
+	syncer := NewSyncer("ws", source, sink, NewMemoryStore(), WithConcurrency(3))
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 12, report.Synced)
+	assert.LessOrEqual(t, sink.maxActive, 3)
+	assert.Greater(t, sink.maxActive, 1)
+	assert.False(t, sink.overlapped, "a user's requests were processed concurrently")
+	assert.Equal(t, []string{"request-01", "request-05", "request-09"}, sink.order["user-1@example.com"])
+
+	for i, result := range report.Results {
+		assert.Equal(t, fmt.Sprintf("request-%02d", i), result.RequestID)
+	}
+}
+
+func TestSyncer_ProcessesClockifyUsersOfOneAccountInOrder(t *testing.T) {
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	// Two Clockify users, with their own emails, are one Google account.
+	var requests []ClockifyRequest
+	for i := range 8 {
+		req := makeStatusRequest(fmt.Sprintf("request-%02d", i), ClockifyStatusApproved, inWindow)
+		req.UserID = fmt.Sprintf("clockify-%d", i%2)
+		req.UserEmail = fmt.Sprintf("ada-%d@gmail.com", i%2)
+		requests = append(requests, req)
+	}
+	users := &UserMap{Users: map[string]string{
+		"clockify-0": "ada@example.com",
+		"clockify-1": "ada@example.com",
+	}}
+
+	source := &fakeClockifySource{requests: requests}
+	sink := &concurrencySink{activeUser: make(map[string]int), order: make(map[string][]string)}
+	syncer := NewSyncer("ws", source, sink, NewMemoryStore(), WithConcurrency(4), WithUserResolver(users))
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 8, report.Synced)
+	assert.Equal(t, 1, sink.maxActive)
+	assert.False(t, sink.overlapped, "an account's requests were processed concurrently")
+	assert.Len(t, sink.order["ada@example.com"], 8)
+}
+
+func TestSyncer_ResolvesUsersConcurrently(t *testing.T) {
+	inWindow := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	var requests []ClockifyRequest
+	for i := range 4 {
+		req := makeStatusRequest(fmt.Sprintf("request-%d", i), ClockifyStatusApproved, inWindow)
+		req.UserID = fmt.Sprintf("clockify-%d", i%2)
+		req.UserEmail = fmt.Sprintf("user-%d@example.com", i%2)
+		requests = append(requests, req)
+	}
+
+	// Each lookup waits for the other user's to start, so resolving one
+	// user at a time would time out.
+	var mu sync.Mutex
+	lookups := make(map[string]int)
+	bothStarted := make(chan struct{})
+	resolver := resolverFunc(func(ctx context.Context, id, email string) (string, error) {
+		mu.Lock()
+		lookups[email]++
+		if len(lookups) == 2 {
+			close(bothStarted)
+		}
+		mu.Unlock()
+
+		select {
+		case <-bothStarted:
+			return email, nil
+		case <-time.After(5 * time.Second):
+			return "", errors.New("users were resolved one at a time")
+		}
+	})
+
+	source := &fakeClockifySource{requests: requests}
+	syncer := NewSyncer(
+		"ws", source, &fakeCalendarSink{}, NewMemoryStore(),
+		WithConcurrency(2),
+		WithUserResolver(resolver),
+	)
+
+	report, err := syncer.Sync(context.Background(), SyncWindow{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Synced)
+	assert.Equal(t, map[string]int{"user-0@example.com": 1, "user-1@example.com": 1}, lookups)
+}
+
+func TestNeedsSync(t *testing.T) {
+	req := makeStatusRequest("request-123", ClockifyStatusApproved, time.Now())
+
+	needs, _ := NeedsSync(nil, req)
+	assert.True(t, needs)
+
+	item, err := req.ToDynamoItem()
+	require.NoError(t, err)
+
+	needs, _ = NeedsSync(item, req)
+	assert.False(t, needs)
+
+	withdrawn := req
+	withdrawn.Status.StatusType = ClockifyStatusWithdrawn
+	needs, reason := NeedsSync(item, withdrawn)
+	assert.True(t, needs)
+	assert.Contains(t, reason, "WITHDRAWN")
+
+	moved := req
+	moved.TimeOffPeriod.Period.Start = "2025-12-09T00:00:00Z"
+	needs, _ = NeedsSync(item, moved)
+	assert.True(t, needs)
+}
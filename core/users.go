@@ -0,0 +1,281 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2/jwt"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// ErrUserUnmapped is returned by a UserResolver that can't find the Google
+// account for a Clockify user. The sync reports such requests rather than
+// failing them.
+var ErrUserUnmapped = errors.New("no Google account for Clockify user")
+
+// UserResolver finds the Google Workspace account to impersonate for a
+// Clockify user, who may use a personal or alias address in Clockify.
+type UserResolver interface {
+	ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error)
+}
+
+// UserMap resolves users from a static mapping, by Clockify user ID first
+// and then by email. It is loaded from JSON, e.g.
+//
+//	{
+//	  "users": {"5f1e...": "ada@example.com"},
+//	  "emails": {"ada.personal@gmail.com": "ada@example.com"}
+//	}
+type UserMap struct {
+	Users  map[string]string `json:"users"`
+	Emails map[string]string `json:"emails"`
+}
+
+// LoadUserMap reads a UserMap from a JSON file.
+func LoadUserMap(path string) (*UserMap, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read user map: %w", err)
+	}
+	return ParseUserMap(b)
+}
+
+// ParseUserMap decodes a UserMap. Emails are matched case-insensitively.
+func ParseUserMap(b []byte) (*UserMap, error) {
+	var m UserMap
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("parse user map: %w", err)
+	}
+
+	emails := make(map[string]string, len(m.Emails))
+	for from, to := range m.Emails {
+		emails[strings.ToLower(from)] = to
+	}
+	m.Emails = emails
+
+	return &m, nil
+}
+
+func (m *UserMap) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	if email, ok := m.Users[clockifyUserID]; ok && clockifyUserID != "" {
+		return email, nil
+	}
+	if email, ok := m.Emails[strings.ToLower(clockifyEmail)]; ok {
+		return email, nil
+	}
+	return "", fmt.Errorf("%w: %s (%s) is not in the user map", ErrUserUnmapped, clockifyEmail, clockifyUserID)
+}
+
+// DirectoryUserResolver looks Clockify emails up in the Google Workspace
+// directory, which knows users by their aliases as well as their primary
+// addresses. Lookups, including misses, are cached for the resolver's
+// lifetime. Different emails are looked up concurrently, and concurrent
+// lookups of the same email share one request.
+type DirectoryUserResolver struct {
+	jwtCfg jwt.Config
+
+	// endpoint and httpClient replace impersonation when set, for testing.
+	endpoint   string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	service  *admin.Service
+	cache    map[string]string
+	inFlight map[string]*directoryLookup
+}
+
+// directoryLookup is a lookup in progress; done is closed once email or err
+// is set.
+type directoryLookup struct {
+	done  chan struct{}
+	email string
+	err   error
+}
+
+// NewDirectoryUserResolver reads the directory as adminEmail, a Workspace
+// admin the service account can impersonate with the
+// admin.directory.user.readonly scope.
+func NewDirectoryUserResolver(
+	jwtCfg jwt.Config,
+	adminEmail string,
+	opts ...func(*DirectoryUserResolver),
+) *DirectoryUserResolver {
+	jwtCfg.Subject = adminEmail
+	jwtCfg.Scopes = []string{admin.AdminDirectoryUserReadonlyScope}
+
+	r := &DirectoryUserResolver{
+		jwtCfg:   jwtCfg,
+		cache:    make(map[string]string),
+		inFlight: make(map[string]*directoryLookup),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// For testing: talk to endpoint with httpClient rather than impersonating an
+// admin against the real API.
+func WithDirectoryEndpoint(endpoint string, httpClient *http.Client) func(*DirectoryUserResolver) {
+	return func(r *DirectoryUserResolver) {
+		r.endpoint = endpoint
+		r.httpClient = httpClient
+	}
+}
+
+func (r *DirectoryUserResolver) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	key := strings.ToLower(clockifyEmail)
+	if key == "" {
+		return "", fmt.Errorf("%w: %s has no email", ErrUserUnmapped, clockifyUserID)
+	}
+
+	r.mu.Lock()
+	if email, ok := r.cache[key]; ok {
+		r.mu.Unlock()
+		return r.found(email, clockifyEmail)
+	}
+	lookup, waiting := r.inFlight[key]
+	if !waiting {
+		lookup = &directoryLookup{done: make(chan struct{})}
+		r.inFlight[key] = lookup
+	}
+	r.mu.Unlock()
+
+	if waiting {
+		select {
+		case <-lookup.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	} else {
+		lookup.email, lookup.err = r.lookUp(ctx, key, clockifyEmail)
+
+		// Failed lookups aren't cached, so the next call tries again.
+		r.mu.Lock()
+		delete(r.inFlight, key)
+		if lookup.err == nil {
+			r.cache[key] = lookup.email
+		}
+		r.mu.Unlock()
+		close(lookup.done)
+	}
+
+	if lookup.err != nil {
+		return "", lookup.err
+	}
+	return r.found(lookup.email, clockifyEmail)
+}
+
+// lookUp asks the directory for the primary email of key, returning "" when
+// the directory has no such user.
+func (r *DirectoryUserResolver) lookUp(ctx context.Context, key, clockifyEmail string) (string, error) {
+	srv, err := r.directory(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := srv.Users.Get(key).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("look up %s in directory: %w", clockifyEmail, err)
+	default:
+		return user.PrimaryEmail, nil
+	}
+}
+
+// directory returns the directory service, creating it on first use.
+func (r *DirectoryUserResolver) directory(ctx context.Context) (*admin.Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.service != nil {
+		return r.service, nil
+	}
+
+	var opts []option.ClientOption
+	if r.httpClient != nil {
+		opts = append(opts, option.WithEndpoint(r.endpoint), option.WithHTTPClient(r.httpClient))
+	} else {
+		opts = append(opts, option.WithHTTPClient(r.jwtCfg.Client(context.Background())))
+	}
+
+	srv, err := admin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("directory service: %w", err)
+	}
+	r.service = srv
+	return srv, nil
+}
+
+func (r *DirectoryUserResolver) found(email, clockifyEmail string) (string, error) {
+	if email == "" {
+		return "", fmt.Errorf("%w: %s is not in the directory", ErrUserUnmapped, clockifyEmail)
+	}
+	return email, nil
+}
+
+// ChainUserResolver tries each resolver in turn, moving on when one reports
+// ErrUserUnmapped. Other errors stop the chain.
+type ChainUserResolver []UserResolver
+
+func (c ChainUserResolver) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	lastErr := fmt.Errorf("%w: %s (%s)", ErrUserUnmapped, clockifyEmail, clockifyUserID)
+
+	for _, resolver := range c {
+		email, err := resolver.ResolveUser(ctx, clockifyUserID, clockifyEmail)
+		if err == nil {
+			return email, nil
+		}
+		if !errors.Is(err, ErrUserUnmapped) {
+			return "", err
+		}
+		lastErr = err
+	}
+
+	return "", lastErr
+}
+
+// DomainUserResolver resolves users whose Clockify email is in one of
+// Domains with Resolver, and reports everyone else unmapped so that a chain
+// moves on. It keeps users whose calendars aren't on Google, such as those
+// CalendarBackends sends to Outlook, away from the Google directory.
+type DomainUserResolver struct {
+	// Domains holds lowercased email domains, e.g. "contractor.example".
+	Domains  map[string]bool
+	Resolver UserResolver
+}
+
+func (r *DomainUserResolver) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	_, domain, ok := strings.Cut(strings.ToLower(clockifyEmail), "@")
+	if !ok || !r.Domains[domain] {
+		return "", fmt.Errorf("%w: %s is not in a listed domain", ErrUserUnmapped, clockifyEmail)
+	}
+	return r.Resolver.ResolveUser(ctx, clockifyUserID, clockifyEmail)
+}
+
+// SameEmailResolver assumes the Clockify email is the Google account, which
+// was the only behaviour before mapping existed. It ends a chain when the
+// directory isn't consulted.
+type SameEmailResolver struct{}
+
+func (SameEmailResolver) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	if clockifyEmail == "" {
+		return "", fmt.Errorf("%w: %s has no email", ErrUserUnmapped, clockifyUserID)
+	}
+	return clockifyEmail, nil
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/jwt"
+)
+
+func TestUserMap_ResolvesByIDThenEmail(t *testing.T) {
+	ctx := context.Background()
+
+	users, err := ParseUserMap([]byte(`{
+		"users": {"clockify-ada": "ada@example.com"},
+		"emails": {"Grace.Personal@Gmail.com": "grace@example.com"}
+	}`))
+	require.NoError(t, err)
+
+	email, err := users.ResolveUser(ctx, "clockify-ada", "someone@else.com")
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", email)
+
+	email, err = users.ResolveUser(ctx, "clockify-grace", "grace.personal@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, "grace@example.com", email)
+
+	_, err = users.ResolveUser(ctx, "clockify-alan", "alan@gmail.com")
+	assert.ErrorIs(t, err, ErrUserUnmapped)
+
+	_, err = ParseUserMap([]byte(`{"aliases": {}}`))
+	assert.Error(t, err)
+}
+
+func TestDirectoryUserResolver_ResolvesAliasesAndCaches(t *testing.T) {
+	var lookups atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/directory/v1/users/{key}", func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		switch r.PathValue("key") {
+		case "ada.alias@example.com":
+			writeFakeJSON(w, http.StatusOK, map[string]any{"primaryEmail": "ada@example.com"})
+		case "broken@example.com":
+			writeFakeError(w, http.StatusInternalServerError, "backend error")
+		default:
+			writeFakeError(w, http.StatusNotFound, "Resource Not Found: userKey")
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	resolver := NewDirectoryUserResolver(
+		jwt.Config{},
+		"admin@example.com",
+		WithDirectoryEndpoint(server.URL+"/", server.Client()),
+	)
+	ctx := context.Background()
+
+	for range 2 {
+		email, err := resolver.ResolveUser(ctx, "clockify-ada", "Ada.Alias@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "ada@example.com", email)
+
+		_, err = resolver.ResolveUser(ctx, "clockify-ghost", "ghost@example.com")
+		assert.ErrorIs(t, err, ErrUserUnmapped)
+	}
+	assert.Equal(t, int32(2), lookups.Load(), "hits and misses are cached")
+
+	_, err := resolver.ResolveUser(ctx, "clockify-broken", "broken@example.com")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUserUnmapped)
+}
+
+func TestDirectoryUserResolver_LooksUpEmailsConcurrentlyAndOnce(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/directory/v1/users/{key}", func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		<-release
+		writeFakeJSON(w, http.StatusOK, map[string]any{"primaryEmail": r.PathValue("key")})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	resolver := NewDirectoryUserResolver(
+		jwt.Config{},
+		"admin@example.com",
+		WithDirectoryEndpoint(server.URL+"/", server.Client()),
+	)
+
+	emails := []string{"ada@example.com", "ada@example.com", "grace@example.com", "ada@example.com"}
+	got := make([]string, len(emails))
+	var wg sync.WaitGroup
+	for i, email := range emails {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], _ = resolver.ResolveUser(context.Background(), "clockify-user", email)
+		}()
+	}
+
+	// Both emails are looked up at once.
+	require.Eventually(t, func() bool { return lookups.Load() == 2 }, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, emails, got)
+	assert.Equal(t, int32(2), lookups.Load(), "each email is looked up once")
+}
+
+func TestChainUserResolver(t *testing.T) {
+	ctx := context.Background()
+	users := &UserMap{Emails: map[string]string{"ada.personal@gmail.com": "ada@example.com"}}
+
+	chain := ChainUserResolver{users, SameEmailResolver{}}
+
+	email, err := chain.ResolveUser(ctx, "clockify-ada", "ada.personal@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", email)
+
+	email, err = chain.ResolveUser(ctx, "clockify-grace", "grace@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "grace@example.com", email)
+
+	_, err = ChainUserResolver{users}.ResolveUser(ctx, "clockify-alan", "alan@gmail.com")
+	assert.ErrorIs(t, err, ErrUserUnmapped)
+
+	failing := resolverFunc(func(ctx context.Context, id, email string) (string, error) {
+		return "", errors.New("directory unavailable")
+	})
+	_, err = ChainUserResolver{failing, SameEmailResolver{}}.ResolveUser(ctx, "clockify-ada", "ada@example.com")
+	assert.ErrorContains(t, err, "directory unavailable")
+}
+
+func TestDomainUserResolver_OnlyResolvesListedDomains(t *testing.T) {
+	ctx := context.Background()
+	resolver := ChainUserResolver{
+		&DomainUserResolver{
+			Domains:  map[string]bool{"contractor.example": true},
+			Resolver: SameEmailResolver{},
+		},
+		resolverFunc(func(ctx context.Context, id, email string) (string, error) {
+			return "directory:" + email, nil
+		}),
+	}
+
+	email, err := resolver.ResolveUser(ctx, "clockify-bob", "Bob@Contractor.example")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob@Contractor.example", email)
+
+	email, err = resolver.ResolveUser(ctx, "clockify-ada", "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "directory:ada@example.com", email)
+}
+
+type resolverFunc func(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error)
+
+func (f resolverFunc) ResolveUser(ctx context.Context, clockifyUserID, clockifyEmail string) (string, error) {
+	return f(ctx, clockifyUserID, clockifyEmail)
+}
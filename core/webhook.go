@@ -0,0 +1,110 @@
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const (
+	// ClockifySignatureHeader carries the signing token Clockify generates for
+	// each webhook.
+	ClockifySignatureHeader = "Clockify-Signature"
+	// ClockifyEventTypeHeader names the event a webhook was sent for, e.g.
+	// TIME_OFF_REQUEST_APPROVED.
+	ClockifyEventTypeHeader = "Clockify-Webhook-Event-Type"
+
+	maxWebhookBody = 1 << 20
+)
+
+// WebhookHandler receives Clockify time-off webhooks (requested, approved,
+// rejected and withdrawn) and syncs the request each one carries through
+// Syncer.SyncRequests. It responds with the sync's Report as JSON, and with
+// a 5xx status when the request failed to sync so that Clockify retries it.
+type WebhookHandler struct {
+	Syncer *Syncer
+	// Tokens are the signing tokens of the webhooks allowed to call the
+	// handler. Clockify issues a different token for every webhook.
+	Tokens []string
+
+	// Deliveries are synced one at a time so that two webhooks for the same
+	// request can't race on its calendars and record.
+	mu sync.Mutex
+}
+
+func NewWebhookHandler(syncer *Syncer, tokens ...string) *WebhookHandler {
+	return &WebhookHandler{
+		Syncer: syncer,
+		Tokens: tokens,
+	}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !validToken(h.Tokens, r.Header.Get(ClockifySignatureHeader)) {
+		log.Printf("Rejecting Clockify webhook with an unknown signing token")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var req ClockifyRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "invalid payload: missing request id", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf(
+		"Received Clockify webhook %s for request %s (status %s)",
+		r.Header.Get(ClockifyEventTypeHeader),
+		req.ID,
+		req.Status.StatusType,
+	)
+
+	h.mu.Lock()
+	report, err := h.Syncer.SyncRequests(r.Context(), req)
+	h.mu.Unlock()
+
+	status := http.StatusOK
+	if err != nil {
+		log.Printf("Failed to sync Clockify request %s from webhook: %v", req.ID, err)
+
+		status = http.StatusInternalServerError
+		var partial *PartialSyncError
+		if !errors.As(err, &partial) {
+			// Not the request's fault, e.g. the store is unreachable.
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// validToken compares given to every token in constant time.
+func validToken(tokens []string, given string) bool {
+	if given == "" {
+		return false
+	}
+
+	ok := false
+	for _, token := range tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
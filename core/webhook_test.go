@@ -0,0 +1,87 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postWebhook(t *testing.T, h http.Handler, token string, payload any) (*httptest.ResponseRecorder, Report) {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/clockify", bytes.NewReader(body))
+	r.Header.Set(ClockifySignatureHeader, token)
+	r.Header.Set(ClockifyEventTypeHeader, "TIME_OFF_REQUEST_APPROVED")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var report Report
+	if w.Header().Get("Content-Type") == "application/json" {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	}
+	return w, report
+}
+
+func TestWebhookHandler_SyncsSignedDeliveries(t *testing.T) {
+	approvedAt := time.Date(2025, 12, 2, 12, 0, 0, 0, time.UTC)
+
+	sink := &fakeCalendarSink{}
+	store := NewMemoryStore()
+	syncer := NewSyncer("ws", nil, sink, store)
+	h := NewWebhookHandler(syncer, "approved-token", "requested-token")
+
+	approved := makeStatusRequest("request-123", ClockifyStatusApproved, approvedAt)
+
+	w, _ := postWebhook(t, h, "wrong-token", approved)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = postWebhook(t, h, "", approved)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, sink.synced)
+
+	w, report := postWebhook(t, h, "approved-token", approved)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, report.Synced)
+	assert.Equal(t, 1, report.Inserted)
+	require.Len(t, sink.synced, 1)
+	assert.Equal(t, ClockifyStatusApproved, mustGetSyncedRequest(t, store, "request-123").Status)
+
+	// A redelivery is skipped, as is the "requested" webhook arriving late.
+	_, report = postWebhook(t, h, "approved-token", approved)
+	assert.Equal(t, 1, report.Skipped)
+
+	pending := makeStatusRequest("request-123", ClockifyStatusPending, approvedAt.Add(-time.Hour))
+	_, report = postWebhook(t, h, "requested-token", pending)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Len(t, sink.synced, 1)
+	assert.Equal(t, ClockifyStatusApproved, mustGetSyncedRequest(t, store, "request-123").Status)
+}
+
+func TestWebhookHandler_RejectsBadDeliveries(t *testing.T) {
+	sink := &fakeCalendarSink{fail: map[string]error{"fails": errors.New("calendar unavailable")}}
+	syncer := NewSyncer("ws", nil, sink, NewMemoryStore())
+	h := NewWebhookHandler(syncer, "token")
+
+	r := httptest.NewRequest(http.MethodGet, "/webhooks/clockify", nil)
+	r.Header.Set(ClockifySignatureHeader, "token")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w, _ = postWebhook(t, h, "token", map[string]any{"policyName": "Vacation"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	failing := makeStatusRequest("fails", ClockifyStatusApproved, time.Now())
+	w, report := postWebhook(t, h, "token", failing)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 1, report.Failed)
+}